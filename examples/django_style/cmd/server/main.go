@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	// Import database drivers
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
@@ -16,6 +18,7 @@ import (
 	"github.com/baxromov/framego/pkg/config"
 	"github.com/baxromov/framego/pkg/graphql"
 	"github.com/baxromov/framego/pkg/middleware"
+	"github.com/baxromov/framego/pkg/migrations"
 	"github.com/baxromov/framego/pkg/orm"
 	"github.com/baxromov/framego/pkg/router"
 )
@@ -46,6 +49,7 @@ func main() {
 	r := router.New()
 
 	// Add middleware
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recovery)
 	r.Use(middleware.CORS)
@@ -66,6 +70,15 @@ func main() {
 	// Setup order API
 	orders.SetupOrderAPI(orm, r)
 
+	// Run management commands such as "makemigrations" or "migrate"
+	if len(os.Args) > 1 {
+		migrator := migrations.New(orm, "examples/django_style/migrations")
+		if err := migrator.RunCommand(context.Background(), os.Args[1:]); err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+		return
+	}
+
 	// Create tables
 	if err := orm.CreateTables(); err != nil {
 		log.Fatalf("Failed to create tables: %v", err)
@@ -1,7 +1,7 @@
 package orders
 
 import (
-	"reflect"
+	"log"
 	"time"
 
 	"github.com/baxromov/framego/pkg/models"
@@ -10,52 +10,55 @@ import (
 // Order represents an order model
 type Order struct {
 	models.Model
-	ID         int
-	UserID     int
-	TotalPrice float64
-	Status     string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID         int        `db:"id,pk,autoincrement"`
+	UserID     int        `db:"user_id,notnull,fk=users.id,ondelete=CASCADE,onupdate=CASCADE"`
+	TotalPrice float64    `db:"total_price,notnull"`
+	Status     string     `db:"status,notnull,default=pending"`
+	CreatedAt  time.Time  `db:"created_at,notnull,default=now,auto_now_add"`
+	UpdatedAt  time.Time  `db:"updated_at,notnull,default=now,auto_now"`
+	DeletedAt  *time.Time `db:"deleted_at,softdelete"`
 }
 
 // OrderItem represents an order item model
 type OrderItem struct {
 	models.Model
-	ID        int
-	OrderID   int
-	ProductID int
-	Quantity  int
-	Price     float64
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID        int       `db:"id,pk,autoincrement"`
+	OrderID   int       `db:"order_id,notnull,fk=orders.id,ondelete=CASCADE,onupdate=CASCADE"`
+	ProductID int       `db:"product_id,notnull,fk=products.id,ondelete=RESTRICT,onupdate=CASCADE"`
+	Quantity  int       `db:"quantity,notnull"`
+	Price     float64   `db:"price,notnull"`
+	CreatedAt time.Time `db:"created_at,notnull,default=now,auto_now_add"`
+	UpdatedAt time.Time `db:"updated_at,notnull,default=now,auto_now"`
 }
 
 // CreateOrderModel creates and returns an order model
 func CreateOrderModel() *models.Model {
-	orderModel := models.NewModel("orders")
-	orderModel.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
-	orderModel.AddField("user_id", reflect.TypeOf(0), models.WithNotNull(),
-		models.WithForeignKey("users", "id", "CASCADE", "CASCADE"))
-	orderModel.AddField("total_price", reflect.TypeOf(0.0), models.WithNotNull())
-	orderModel.AddField("status", reflect.TypeOf(""), models.WithNotNull(), models.WithDefault("pending"))
-	orderModel.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
-	orderModel.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
+	orderModel := models.MustFromStruct(&Order{})
+	if err := orderModel.BelongsTo("user", "users", "user_id"); err != nil {
+		log.Fatalf("Failed to add order relation: %v", err)
+	}
+	if err := orderModel.HasMany("items", "order_items", "order_id"); err != nil {
+		log.Fatalf("Failed to add order relation: %v", err)
+	}
 
 	return orderModel
 }
 
 // CreateOrderItemModel creates and returns an order item model
 func CreateOrderItemModel() *models.Model {
-	orderItemModel := models.NewModel("order_items")
-	orderItemModel.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
-	orderItemModel.AddField("order_id", reflect.TypeOf(0), models.WithNotNull(),
-		models.WithForeignKey("orders", "id", "CASCADE", "CASCADE"))
-	orderItemModel.AddField("product_id", reflect.TypeOf(0), models.WithNotNull(),
-		models.WithForeignKey("products", "id", "RESTRICT", "CASCADE"))
-	orderItemModel.AddField("quantity", reflect.TypeOf(0), models.WithNotNull())
-	orderItemModel.AddField("price", reflect.TypeOf(0.0), models.WithNotNull())
-	orderItemModel.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
-	orderItemModel.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
+	orderItemModel := models.MustFromStruct(&OrderItem{})
+	if err := orderItemModel.BelongsTo("order", "orders", "order_id"); err != nil {
+		log.Fatalf("Failed to add order item relation: %v", err)
+	}
+	if err := orderItemModel.BelongsTo("product", "products", "product_id"); err != nil {
+		log.Fatalf("Failed to add order item relation: %v", err)
+	}
+	if err := orderItemModel.AddIndex("order_items_order_product", []string{"order_id", "product_id"}, models.IndexOptions{Unique: true}); err != nil {
+		log.Fatalf("Failed to add order item index: %v", err)
+	}
+	if err := orderItemModel.AddCheck("order_items_quantity_positive", "quantity > 0"); err != nil {
+		log.Fatalf("Failed to add order item check: %v", err)
+	}
 
 	return orderItemModel
 }
@@ -1,17 +1,36 @@
 package orders
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
 	"github.com/baxromov/framego/pkg/serializer"
 )
 
-// CreateOrderSerializer creates and returns an order serializer
-func CreateOrderSerializer(orderModel *models.Model) *serializer.Serializer {
+// CreateOrderSerializer creates and returns an order serializer, which embeds
+// the order's items and a summary of its user. Orders are created and updated
+// together with their items.
+func CreateOrderSerializer(db *orm.ORM, orderModel, orderItemModel *models.Model) *serializer.Serializer {
 	orderSerializer := serializer.New(orderModel)
 
+	// The user must exist, and is shown as a summary
+	orderSerializer.AddRelated("user_id", serializer.PrimaryKeyRelatedField(db, "users"), serializer.WithRequired())
+	orderSerializer.AddNested("user", createUserSummarySerializer(), serializer.WithReadOnly())
+
+	// Items are written with the order
+	orderSerializer.AddNested("items", CreateOrderItemSerializer(db, orderItemModel), serializer.WithMany())
+
+	// Totals are shown with cents, and the number of items is computed
+	orderSerializer.AddField("total_price", reflect.TypeOf(0.0), serializer.WithRequired(), serializer.WithDecimalPlaces(2))
+	orderSerializer.AddMethod("item_count", func(ctx context.Context, order interface{}) (interface{}, error) {
+		items, _ := serializer.Value(order, "items")
+		rows, _ := items.([]map[string]interface{})
+		return len(rows), nil
+	})
+
 	// Add status validator
 	orderSerializer.AddField("status", reflect.TypeOf(""),
 		serializer.WithValidator(func(value interface{}) error {
@@ -25,19 +44,48 @@ func CreateOrderSerializer(orderModel *models.Model) *serializer.Serializer {
 					return nil
 				}
 			}
-			return fmt.Errorf("invalid status: must be one of %v", validStatuses)
+			return serializer.Invalid("invalid_choice", "invalid status: must be one of %v", validStatuses)
+		}),
+		serializer.WithErrorMessages(map[string]string{
+			serializer.CodeRequired: "please choose an order status",
 		}))
 
+	// Only orders with something to pay for go past pending
+	orderSerializer.AddValidator(func(data map[string]interface{}) error {
+		status, _ := data["status"].(string)
+		total, ok := data["total_price"].(float64)
+		if ok && total <= 0 && status != "" && status != "pending" && status != "cancelled" {
+			err := serializer.Invalid("invalid_status", "an order without a total price cannot be %s", status)
+			err.Field = "status"
+			return err
+		}
+		return nil
+	})
+
 	return orderSerializer
 }
 
 // CreateOrderItemSerializer creates and returns an order item serializer
-func CreateOrderItemSerializer(orderItemModel *models.Model) *serializer.Serializer {
+func CreateOrderItemSerializer(db *orm.ORM, orderItemModel *models.Model) *serializer.Serializer {
 	orderItemSerializer := serializer.New(orderItemModel)
 
+	// The order and product must exist
+	orderItemSerializer.AddRelated("order_id", serializer.PrimaryKeyRelatedField(db, "orders"), serializer.WithRequired())
+	orderItemSerializer.AddRelated("product_id", serializer.PrimaryKeyRelatedField(db, "products"), serializer.WithRequired())
+
 	// Add quantity validator (must be positive)
 	orderItemSerializer.AddField("quantity", reflect.TypeOf(0),
 		serializer.WithValidator(serializer.RangeValidator(1, 100)))
 
 	return orderItemSerializer
 }
+
+// createUserSummarySerializer creates the serializer of the user embedded in an order
+func createUserSummarySerializer() *serializer.Serializer {
+	userSummarySerializer := serializer.New(models.NewModel("users"))
+	userSummarySerializer.AddField("id", reflect.TypeOf(0))
+	userSummarySerializer.AddField("username", reflect.TypeOf(""))
+	userSummarySerializer.AddField("email", reflect.TypeOf(""))
+
+	return userSummarySerializer
+}
@@ -2,8 +2,10 @@ package orders
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/baxromov/framego/pkg/api"
+	"github.com/baxromov/framego/pkg/apierror"
 	"github.com/baxromov/framego/pkg/middleware"
 	"github.com/baxromov/framego/pkg/orm"
 	"github.com/baxromov/framego/pkg/router"
@@ -27,30 +29,59 @@ func SetupOrderAPI(orm *orm.ORM, r *router.Router) {
 
 	// Create order controller
 	orderController := api.NewController(orm, orderModel, "/api/orders")
-	orderSerializer := CreateOrderSerializer(orderModel)
+	orderSerializer := CreateOrderSerializer(orm, orderModel, orderItemModel)
 	orderController.SetSerializer(orderSerializer)
+	orderController.SetPagination(&api.PageNumberPagination{
+		PageSize:           50,
+		MaxPageSize:        200,
+		PageSizeQueryParam: "page_size",
+	})
+	orderController.SetFilter(&api.Filters{
+		Fields:          []string{"user_id", "status", "total_price", "created_at"},
+		SearchFields:    []string{"status"},
+		OrderingFields:  []string{"created_at", "total_price"},
+		DefaultOrdering: []string{"-created_at"},
+	})
 
 	// Create order item controller
 	orderItemController := api.NewController(orm, orderItemModel, "/api/order-items")
-	orderItemSerializer := CreateOrderItemSerializer(orderItemModel)
+	orderItemSerializer := CreateOrderItemSerializer(orm, orderItemModel)
 	orderItemController.SetSerializer(orderItemSerializer)
+	orderItemController.SetPagination(&api.CursorPagination{
+		PageSize: 50,
+		Ordering: []string{"-created_at", "-id"},
+	})
+	orderItemController.SetFilter(&api.Filters{
+		Fields: []string{"order_id", "product_id", "quantity"},
+	})
 
 	// Register routes
 	apiGroup := r.Group("/api")
 
-	// Order routes
-	apiGroup.GET("/orders", orderController.List, middleware.Auth)
-	apiGroup.GET("/orders/:id", orderController.Get, middleware.Auth)
-	apiGroup.POST("/orders", orderController.Create, middleware.Auth)
-	apiGroup.PUT("/orders/:id", orderController.Update, middleware.Auth)
-	apiGroup.DELETE("/orders/:id", orderController.Delete, middleware.Auth)
+	// Order routes, including POST /api/orders/:id/cancel
+	api.NewViewSet(orderController).
+		Use(middleware.Auth).
+		Action(api.Action{Name: "cancel", Detail: true, Handler: cancelOrder(orm)}).
+		Register(apiGroup)
 
 	// Order item routes
-	apiGroup.GET("/order-items", orderItemController.List, middleware.Auth)
-	apiGroup.GET("/order-items/:id", orderItemController.Get, middleware.Auth)
-	apiGroup.POST("/order-items", orderItemController.Create, middleware.Auth)
-	apiGroup.PUT("/order-items/:id", orderItemController.Update, middleware.Auth)
-	apiGroup.DELETE("/order-items/:id", orderItemController.Delete, middleware.Auth)
+	api.NewViewSet(orderItemController).
+		Use(middleware.Auth).
+		Register(apiGroup)
 
 	fmt.Println("Order API routes registered")
 }
+
+// cancelOrder returns the handler of the cancel action, which marks an order
+// as cancelled
+func cancelOrder(orm *orm.ORM) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := router.GetPathParam(r, "id")
+		if err := orm.UpdateContext(r.Context(), "orders", id, map[string]interface{}{"status": "cancelled"}); err != nil {
+			// A missing order is reported as 404 Not Found
+			apierror.Write(w, r, api.ErrorFrom(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
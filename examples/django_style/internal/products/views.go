@@ -31,14 +31,10 @@ func SetupProductAPI(orm *orm.ORM, r *router.Router) {
 	// Register routes
 	apiGroup := r.Group("/api")
 
-	// Public routes
-	apiGroup.GET("/products", productController.List)
-	apiGroup.GET("/products/:id", productController.Get)
-
-	// Protected routes
-	apiGroup.POST("/products", productController.Create, middleware.Auth)
-	apiGroup.PUT("/products/:id", productController.Update, middleware.Auth)
-	apiGroup.DELETE("/products/:id", productController.Delete, middleware.Auth)
+	// Reads are public, writes need authentication
+	api.NewViewSet(productController).
+		Use(middleware.Auth, api.ActionCreate, api.ActionUpdate, api.ActionPartialUpdate, api.ActionDestroy).
+		Register(apiGroup)
 
 	fmt.Println("Product API routes registered")
 }
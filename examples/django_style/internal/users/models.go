@@ -1,7 +1,6 @@
 package users
 
 import (
-	"reflect"
 	"time"
 
 	"github.com/baxromov/framego/pkg/models"
@@ -10,23 +9,15 @@ import (
 // User represents a user model
 type User struct {
 	models.Model
-	ID        int
-	Username  string
-	Email     string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID        int       `db:"id,pk,autoincrement"`
+	Username  string    `db:"username,notnull,maxlen=50,unique"`
+	Email     string    `db:"email,notnull,maxlen=100,unique"`
+	Password  string    `db:"password,notnull,maxlen=100"`
+	CreatedAt time.Time `db:"created_at,notnull,default=now,auto_now_add"`
+	UpdatedAt time.Time `db:"updated_at,notnull,default=now,auto_now"`
 }
 
 // CreateUserModel creates and returns a user model
 func CreateUserModel() *models.Model {
-	userModel := models.NewModel("users")
-	userModel.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
-	userModel.AddField("username", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(50), models.WithUnique())
-	userModel.AddField("email", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(100), models.WithUnique())
-	userModel.AddField("password", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(100))
-	userModel.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
-	userModel.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
-
-	return userModel
+	return models.MustFromStruct(&User{})
 }
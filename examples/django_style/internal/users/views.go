@@ -29,12 +29,8 @@ func SetupUserAPI(orm *orm.ORM, r *router.Router) {
 	// Register routes
 	apiGroup := r.Group("/api")
 
-	// Public routes
-	apiGroup.GET("/users", userController.List)
-	apiGroup.GET("/users/:id", userController.Get)
-
-	// Protected routes
-	apiGroup.POST("/users", userController.Create, middleware.Auth)
-	apiGroup.PUT("/users/:id", userController.Update, middleware.Auth)
-	apiGroup.DELETE("/users/:id", userController.Delete, middleware.Auth)
+	// Reads are public, writes need authentication
+	api.NewViewSet(userController).
+		Use(middleware.Auth, api.ActionCreate, api.ActionUpdate, api.ActionPartialUpdate, api.ActionDestroy).
+		Register(apiGroup)
 }
@@ -38,8 +38,8 @@ func main() {
 	userModel.AddField("username", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(50), models.WithUnique())
 	userModel.AddField("email", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(100), models.WithUnique())
 	userModel.AddField("password", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(100))
-	userModel.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
-	userModel.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()))
+	userModel.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()), models.WithAutoNowAdd())
+	userModel.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithNotNull(), models.WithDefault(time.Now()), models.WithAutoNow())
 
 	// Create a new ORM instance
 	ormConfig := orm.Config{
@@ -67,6 +67,7 @@ func main() {
 	r := router.New()
 
 	// Add middleware
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recovery)
 	r.Use(middleware.CORS)
@@ -87,14 +88,10 @@ func main() {
 	// Register routes
 	apiGroup := r.Group("/api")
 
-	// Public routes
-	apiGroup.GET("/users", userController.List)
-	apiGroup.GET("/users/:id", userController.Get)
-
-	// Protected routes
-	apiGroup.POST("/users", userController.Create, middleware.Auth)
-	apiGroup.PUT("/users/:id", userController.Update, middleware.Auth)
-	apiGroup.DELETE("/users/:id", userController.Delete, middleware.Auth)
+	// Reads are public, writes need authentication
+	api.NewViewSet(userController).
+		Use(middleware.Auth, api.ActionCreate, api.ActionUpdate, api.ActionPartialUpdate, api.ActionDestroy).
+		Register(apiGroup)
 
 	// Start the server
 	fmt.Println("Server started at http://localhost:8080")
@@ -1,14 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/baxromov/framego/pkg/models"
 	"github.com/baxromov/framego/pkg/orm"
+	"github.com/baxromov/framego/pkg/router"
+	"github.com/baxromov/framego/pkg/serializer"
 )
 
 // Controller represents a REST API controller
@@ -17,6 +21,8 @@ type Controller struct {
 	Model      models.ModelInterface
 	Serializer Serializer
 	BasePath   string
+	Pagination Paginator     // Lists return every record when nil
+	Filter     FilterBackend // Lists ignore query parameters when nil
 }
 
 // Serializer defines methods for serializing and deserializing data
@@ -26,20 +32,62 @@ type Serializer interface {
 	Validate(data map[string]interface{}) error
 }
 
+// PartialValidator is implemented by serializers that can validate a partial
+// update, checking only the fields supplied
+type PartialValidator interface {
+	ValidatePartial(data map[string]interface{}) error
+}
+
+// Preloader is implemented by serializers that render relations of a record,
+// such as nested serializers. The controller preloads them with the records.
+type Preloader interface {
+	Preloads() []string
+}
+
+// ContextSerializer is implemented by serializers that render records for a
+// request, e.g. with fields computed from the request's context. Controllers
+// pass the fields selected by the FieldsQueryParam and ExcludeQueryParam query
+// parameters in the context, see serializer.SelectFields.
+type ContextSerializer interface {
+	SerializeContext(ctx context.Context, data interface{}) (map[string]interface{}, error)
+}
+
+// Query parameters selecting the fields of the records a controller returns
+const (
+	FieldsQueryParam  = "fields"  // Comma-separated fields to return, e.g. ?fields=id,name
+	ExcludeQueryParam = "exclude" // Comma-separated fields to leave out, e.g. ?exclude=description
+)
+
+// Saver is implemented by serializers that validate and store records
+// themselves, such as serializers with writable nested fields. Both methods
+// return the stored record.
+type Saver interface {
+	Create(ctx context.Context, db *orm.ORM, data map[string]interface{}) (map[string]interface{}, error)
+	Update(ctx context.Context, db *orm.ORM, id interface{}, data map[string]interface{}, partial bool) (map[string]interface{}, error)
+}
+
 // DefaultSerializer is a basic implementation of the Serializer interface
 type DefaultSerializer struct {
 	Model models.ModelInterface
 }
 
-// Serialize converts a model instance to a map
+// Serialize converts a model instance, or a record as returned by the ORM, to a map
 func (s *DefaultSerializer) Serialize(data interface{}) (map[string]interface{}, error) {
+	if record, ok := data.(map[string]interface{}); ok {
+		result := make(map[string]interface{}, len(record))
+		for name, value := range record {
+			result[name] = value
+		}
+		return result, nil
+	}
+
 	val := reflect.ValueOf(data)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
 
 	if val.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("data must be a struct or a pointer to a struct")
+		return nil, fmt.Errorf("data must be a map, a struct or a pointer to a struct")
 	}
 
 	result := make(map[string]interface{})
@@ -79,31 +127,70 @@ func (s *DefaultSerializer) Deserialize(data map[string]interface{}) (interface{
 
 // Validate validates the data against the model's constraints
 func (s *DefaultSerializer) Validate(data map[string]interface{}) error {
+	return s.validate(data, false)
+}
+
+// ValidatePartial validates the fields present in data, skipping required checks
+func (s *DefaultSerializer) ValidatePartial(data map[string]interface{}) error {
+	return s.validate(data, true)
+}
+
+// validate validates data, checking required fields unless partial is set,
+// and reports every invalid field
+func (s *DefaultSerializer) validate(data map[string]interface{}, partial bool) error {
 	fields := s.Model.GetFields()
+	var errs serializer.ValidationErrors
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	for name, field := range fields {
+	for _, name := range names {
+		field := fields[name]
 		value, exists := data[name]
-		if !exists && field.NotNull && field.Default == nil {
-			return fmt.Errorf("field %s is required", name)
+		// Timestamps are set by the ORM when missing
+		if !exists && !partial && field.NotNull && field.Default == nil && !field.AutoNow && !field.AutoNowAdd {
+			errs = append(errs, &serializer.FieldError{Field: name, Code: serializer.CodeRequired, Message: fmt.Sprintf("field %s is required", name)})
+			continue
 		}
 
 		if exists {
-			// Type validation
-			valueType := reflect.TypeOf(value)
-			if valueType != field.Type && value != nil {
-				return fmt.Errorf("field %s has invalid type: expected %v, got %v", name, field.Type, valueType)
+			// Type validation, accepting JSON numbers and time strings
+			if _, err := serializer.Coerce(value, field.Type); err != nil {
+				fieldErr := err.(*serializer.FieldError)
+				errs = append(errs, &serializer.FieldError{Field: name, Code: fieldErr.Code,
+					Message: fmt.Sprintf("field %s has invalid value: %v", name, err)})
+				continue
 			}
 
 			// String length validation
 			if field.Type.Kind() == reflect.String && field.MaxLength > 0 {
 				strValue, ok := value.(string)
 				if ok && len(strValue) > field.MaxLength {
-					return fmt.Errorf("field %s exceeds maximum length of %d", name, field.MaxLength)
+					errs = append(errs, &serializer.FieldError{Field: name, Code: serializer.CodeMaxLength,
+						Message: fmt.Sprintf("field %s exceeds maximum length of %d", name, field.MaxLength)})
 				}
 			}
 		}
 	}
 
+	// Keys that are not columns of the model would fail the insert
+	var unknown []string
+	for name := range data {
+		if _, ok := fields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, &serializer.FieldError{Field: name, Code: serializer.CodeUnknownField, Message: fmt.Sprintf("field %s not found", name)})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
@@ -122,33 +209,60 @@ func (c *Controller) SetSerializer(serializer Serializer) {
 	c.Serializer = serializer
 }
 
-// RegisterRoutes registers the controller's routes with the given router
-func (c *Controller) RegisterRoutes(router http.Handler) {
-	// This is a placeholder
-	// In a real implementation, you would register routes with the router
-	// For example:
-	// router.HandleFunc(c.BasePath, c.List).Methods("GET")
-	// router.HandleFunc(c.BasePath+"/{id}", c.Get).Methods("GET")
-	// router.HandleFunc(c.BasePath, c.Create).Methods("POST")
-	// router.HandleFunc(c.BasePath+"/{id}", c.Update).Methods("PUT")
-	// router.HandleFunc(c.BasePath+"/{id}", c.Delete).Methods("DELETE")
+// SetPagination sets the paginator used by List
+func (c *Controller) SetPagination(pagination Paginator) {
+	c.Pagination = pagination
+}
+
+// SetFilter sets the filter backend used by List
+func (c *Controller) SetFilter(filter FilterBackend) {
+	c.Filter = filter
 }
 
-// List handles GET requests to list all records
+// RegisterRoutes registers the controller's routes with the given router,
+// which must be a *router.Router; other handlers are left unchanged. Prefer
+// Register, which mounts the routes on a router group.
+func (c *Controller) RegisterRoutes(handler http.Handler) {
+	if r, ok := handler.(*router.Router); ok {
+		c.Register(r.Group(""))
+	}
+}
+
+// List handles GET requests to list records, filtered by the controller's
+// filter backend and one page at a time when it has a paginator
 func (c *Controller) List(w http.ResponseWriter, r *http.Request) {
-	// Query the database for all records
-	results, err := c.ORM.Query(fmt.Sprintf("SELECT * FROM %s", c.Model.GetTableName()))
+	qs := c.query(r.Context())
+	if c.Filter != nil {
+		if err := c.Filter.Filter(r, qs, c); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	// Query the database for the records
+	var page *Page
+	var results []map[string]interface{}
+	var err error
+	if c.Pagination != nil {
+		page, err = c.Pagination.Paginate(r, qs)
+		if page != nil {
+			results = page.Results
+		}
+	} else {
+		results, err = qs.All()
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Serialize the results
+	serialize := c.serializer(r, true)
 	response := make([]map[string]interface{}, len(results))
 	for i, result := range results {
-		serialized, err := c.Serializer.Serialize(result)
+		serialized, err := serialize(result)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 		response[i] = serialized
@@ -156,29 +270,36 @@ func (c *Controller) List(w http.ResponseWriter, r *http.Request) {
 
 	// Write the response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if page == nil {
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	if link := page.LinkHeader(); link != "" {
+		w.Header().Set("Link", link)
+	}
+	json.NewEncoder(w).Encode(page.envelope(response))
 }
 
 // Get handles GET requests to retrieve a single record
 func (c *Controller) Get(w http.ResponseWriter, r *http.Request) {
 	// Extract the ID from the URL
-	id := extractIDFromURL(r.URL.Path)
+	id := requestID(r)
 	if id == "" {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, badRequest("invalid ID"))
 		return
 	}
 
 	// Query the database for the record
-	result, err := c.ORM.Get(c.Model.GetTableName(), id)
+	result, err := c.get(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Serialize the result
-	response, err := c.Serializer.Serialize(result)
+	response, err := c.serializer(r, true)(result)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -192,73 +313,143 @@ func (c *Controller) Create(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var data map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, badRequest("invalid JSON body: %v", err))
 		return
 	}
 
-	// Validate the data
-	if err := c.Serializer.Validate(data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Validate the data and create the record
+	result, err := c.create(r.Context(), data)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Create the record
-	id, err := c.ORM.Create(c.Model.GetTableName(), data)
+	// Serialize the created record
+	response, err := c.serializer(r, true)(result)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Write the response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
+	json.NewEncoder(w).Encode(response)
 }
 
-// Update handles PUT requests to update an existing record
+// Update handles PUT requests to update an existing record. The updated record
+// is returned.
 func (c *Controller) Update(w http.ResponseWriter, r *http.Request) {
 	// Extract the ID from the URL
-	id := extractIDFromURL(r.URL.Path)
+	id := requestID(r)
 	if id == "" {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, badRequest("invalid ID"))
 		return
 	}
 
 	// Parse the request body
 	var data map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, badRequest("invalid JSON body: %v", err))
 		return
 	}
 
-	// Validate the data
-	if err := c.Serializer.Validate(data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Read from the primary so the response reflects the update
+	ctx := orm.WithPrimary(r.Context())
+
+	// Validate the data and update the record
+	if err := c.update(ctx, id, data, false); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Update the record
-	if err := c.ORM.Update(c.Model.GetTableName(), id, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Serialize the updated record
+	result, err := c.get(ctx, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response, err := c.serializer(r, true)(result)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Write the response
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PartialUpdate handles PATCH requests to change some fields of a record. The
+// body is a JSON Merge Patch (application/json or
+// application/merge-patch+json) or a JSON Patch (application/json-patch+json).
+// Only the changed fields are validated, and the updated record is returned.
+func (c *Controller) PartialUpdate(w http.ResponseWriter, r *http.Request) {
+	// Extract the ID from the URL
+	id := requestID(r)
+	if id == "" {
+		writeError(w, r, badRequest("invalid ID"))
+		return
+	}
+	w.Header().Set("Accept-Patch", acceptPatch)
+
+	// Read from the primary so the response reflects the update
+	ctx := orm.WithPrimary(r.Context())
+
+	// Load the record the patch applies to
+	current, err := c.get(ctx, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	document, err := c.serializer(r, false)(current)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Work out the changed fields
+	data, err := decodePatch(r, document)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Validate the changed fields only and update the record
+	if err := c.update(ctx, id, data, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Serialize the updated record
+	result, err := c.get(ctx, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response, err := c.serializer(r, true)(result)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Write the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // Delete handles DELETE requests to delete a record
 func (c *Controller) Delete(w http.ResponseWriter, r *http.Request) {
 	// Extract the ID from the URL
-	id := extractIDFromURL(r.URL.Path)
+	id := requestID(r)
 	if id == "" {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, r, badRequest("invalid ID"))
 		return
 	}
 
 	// Delete the record
-	if err := c.ORM.Delete(c.Model.GetTableName(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.ORM.DeleteContext(r.Context(), c.Model.GetTableName(), id); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
@@ -266,6 +457,117 @@ func (c *Controller) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// serializer returns the function rendering records for the request. A
+// ContextSerializer gets the request's context, along with the fields selected
+// by the query string when selectable is set.
+func (c *Controller) serializer(r *http.Request, selectable bool) func(data interface{}) (map[string]interface{}, error) {
+	contextSerializer, ok := c.Serializer.(ContextSerializer)
+	if !ok {
+		return c.Serializer.Serialize
+	}
+
+	ctx := r.Context()
+	query := r.URL.Query()
+	if selectable && (query.Has(FieldsQueryParam) || query.Has(ExcludeQueryParam)) {
+		split := func(param string) []string {
+			if value := query.Get(param); value != "" {
+				return strings.Split(value, ",")
+			}
+			return nil
+		}
+		ctx = serializer.SelectFields(ctx, split(FieldsQueryParam), split(ExcludeQueryParam))
+	}
+	return func(data interface{}) (map[string]interface{}, error) {
+		return contextSerializer.SerializeContext(ctx, data)
+	}
+}
+
+// query starts a query for the controller's records, preloading the relations
+// its serializer renders
+func (c *Controller) query(ctx context.Context) *orm.QuerySet {
+	qs := c.ORM.Table(c.Model.GetTableName()).WithContext(ctx)
+	if preloader, ok := c.Serializer.(Preloader); ok {
+		if preloads := preloader.Preloads(); len(preloads) > 0 {
+			qs.Preload(preloads...)
+		}
+	}
+	return qs
+}
+
+// get retrieves a record by primary key, with the relations its serializer renders
+func (c *Controller) get(ctx context.Context, id interface{}) (map[string]interface{}, error) {
+	preloader, ok := c.Serializer.(Preloader)
+	primaryKey := primaryKeyOf(c.Model)
+	if !ok || len(preloader.Preloads()) == 0 || primaryKey == "" {
+		return c.ORM.GetContext(ctx, c.Model.GetTableName(), id)
+	}
+	return c.query(ctx).Where(primaryKey, "=", id).First()
+}
+
+// create validates data and inserts a record, through the serializer if it
+// is a Saver, and returns the stored record with its relations
+func (c *Controller) create(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	saver, ok := c.Serializer.(Saver)
+	if !ok {
+		if err := c.Serializer.Validate(data); err != nil {
+			return nil, validationError(err)
+		}
+		return c.ORM.InsertContext(ctx, c.Model.GetTableName(), data)
+	}
+
+	// Validation errors of a Saver are serializer errors already
+	result, err := saver.Create(ctx, c.ORM, data)
+	if err != nil {
+		return nil, err
+	}
+	if primaryKey := primaryKeyOf(c.Model); primaryKey != "" {
+		return c.get(orm.WithPrimary(ctx), result[primaryKey])
+	}
+	return result, nil
+}
+
+// update validates data and updates a record, through the serializer if it
+// is a Saver. A partial update validates only the fields in data.
+func (c *Controller) update(ctx context.Context, id interface{}, data map[string]interface{}, partial bool) error {
+	if saver, ok := c.Serializer.(Saver); ok {
+		_, err := saver.Update(ctx, c.ORM, id, data, partial)
+		return err
+	}
+
+	validate := c.Serializer.Validate
+	if validator, ok := c.Serializer.(PartialValidator); ok && partial {
+		validate = validator.ValidatePartial
+	}
+	if err := validate(data); err != nil {
+		return validationError(err)
+	}
+	return c.ORM.UpdateContext(ctx, c.Model.GetTableName(), id, data)
+}
+
+// primaryKeyOf returns the primary key column of a model, or "" when the
+// model has none or a composite one
+func primaryKeyOf(model models.ModelInterface) string {
+	var primaryKey string
+	for name, field := range model.GetFields() {
+		if field.PrimaryKey {
+			if primaryKey != "" {
+				return ""
+			}
+			primaryKey = name
+		}
+	}
+	return primaryKey
+}
+
+// requestID returns the :id path parameter, or the last segment of the path
+// for routes registered without one
+func requestID(r *http.Request) string {
+	if id := router.GetPathParam(r, "id"); id != "" {
+		return id
+	}
+	return extractIDFromURL(r.URL.Path)
+}
+
 // extractIDFromURL extracts the ID from the URL path
 func extractIDFromURL(path string) string {
 	parts := strings.Split(path, "/")
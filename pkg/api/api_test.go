@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/router"
+	"github.com/baxromov/framego/pkg/serializer"
+)
+
+// apiUser is the model of the controller in TestControllerCreate
+type apiUser struct {
+	ID    int    `db:"id,pk,autoincrement"`
+	Name  string `db:"name,notnull,maxlen=10"`
+	Email string `db:"email,default=''"`
+	Age   *int   `db:"age"`
+
+	// Set by the ORM, so never required
+	Joined time.Time `db:"joined,notnull,auto_now_add"`
+}
+
+func (apiUser) TableName() string { return "users" }
+
+func TestControllerCreate(t *testing.T) {
+	o := openTestORM(t)
+	model := models.MustFromStruct(&apiUser{})
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	c := NewController(o, model, "/users")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []string // Codes of the invalid fields, as field:code
+	}{
+		{name: "valid", body: `{"name": "b", "age": 30}`, status: http.StatusCreated},
+		{name: "default", body: `{"name": "c"}`, status: http.StatusCreated},
+		{name: "timestamp", body: `{"name": "d", "joined": "2024-01-02T00:00:00Z"}`, status: http.StatusCreated},
+		{name: "missing required field", body: `{"age": 30}`, status: http.StatusBadRequest, fields: []string{"name:required"}},
+		{name: "invalid type", body: `{"name": "b", "age": "old"}`, status: http.StatusBadRequest, fields: []string{"age:invalid_type"}},
+		{name: "too long", body: `{"name": "bartholomew"}`, status: http.StatusBadRequest, fields: []string{"name:max_length"}},
+		{name: "unknown field", body: `{"name": "b", "nope": 1}`, status: http.StatusBadRequest, fields: []string{"nope:unknown_field"}},
+		{
+			name:   "every invalid field",
+			body:   `{"age": 1.5, "zip": "1", "nope": 1}`,
+			status: http.StatusBadRequest,
+			fields: []string{"age:invalid_type", "name:required", "nope:unknown_field", "zip:unknown_field"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			c.Create(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.status)
+			}
+			if tt.fields == nil {
+				return
+			}
+
+			var response struct {
+				Error APIError `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			for _, field := range response.Error.Fields {
+				fields = append(fields, field.Field+":"+field.Code)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestControllerFieldSelection(t *testing.T) {
+	o := openTestORM(t)
+	model := models.MustFromStruct(&apiUser{})
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("users", map[string]interface{}{"name": "ann", "email": "ann@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewController(o, model, "/users")
+	c.SetSerializer(serializer.New(model))
+	r := router.New()
+	c.Register(r.Group(""))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   []string // Fields of the returned user
+	}{
+		{name: "every field", method: http.MethodGet, path: "/users/1", want: []string{"age", "email", "id", "joined", "name"}},
+		{name: "fields", method: http.MethodGet, path: "/users/1?fields=id,name", want: []string{"id", "name"}},
+		{name: "exclude", method: http.MethodGet, path: "/users/1?exclude=email,age,joined", want: []string{"id", "name"}},
+		{name: "list", method: http.MethodGet, path: "/users?fields=name", want: []string{"name"}},
+		{name: "patch", method: http.MethodPatch, path: "/users/1?fields=age", body: `{"age": 30}`, want: []string{"age"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, http.StatusOK)
+			}
+
+			var user map[string]interface{}
+			if strings.HasPrefix(w.Body.String(), "[") {
+				var users []map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil || len(users) != 1 {
+					t.Fatalf("body %s: %v", w.Body, err)
+				}
+				user = users[0]
+			} else if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+				t.Fatalf("body %s: %v", w.Body, err)
+			}
+			var fields []string
+			for name := range user {
+				fields = append(fields, name)
+			}
+			sort.Strings(fields)
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("fields = %q, want %q", fields, tt.want)
+			}
+		})
+	}
+
+	// The selection does not limit the fields a patch keeps
+	stored, err := o.Get("users", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored["email"] != "ann@example.com" || stored["age"] != int64(30) {
+		t.Errorf("stored user = %v, want the email kept and age 30", stored)
+	}
+}
+
+func TestControllerUpdate(t *testing.T) {
+	o := openTestORM(t)
+	model := models.MustFromStruct(&apiUser{})
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("users", map[string]interface{}{"name": "ann", "email": "ann@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.New()
+	NewController(o, model, "/users").Register(r.Group(""))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"name": "bob", "age": 40}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, http.StatusOK)
+	}
+	var user map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := user["joined"]; !ok {
+		t.Errorf("PUT returned %v, want the join time", user)
+	}
+	delete(user, "joined")
+	want := map[string]interface{}{"id": float64(1), "name": "bob", "email": "ann@example.com", "age": float64(40)}
+	if !reflect.DeepEqual(user, want) {
+		t.Errorf("PUT returned %v, want %v", user, want)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/baxromov/framego/pkg/apierror"
+	"github.com/baxromov/framego/pkg/orm"
+	"github.com/baxromov/framego/pkg/serializer"
+)
+
+// APIError is an error returned to API clients, rendered as JSON by the
+// controller's handlers; see package apierror
+type APIError = apierror.Error
+
+// ErrorFrom converts an error to the APIError sent to clients:
+//
+//   - an APIError is returned as is
+//   - a missing record (sql.ErrNoRows) becomes 404 Not Found
+//   - a unique violation becomes 409 Conflict
+//   - a foreign key, not null or check violation becomes 422 Unprocessable Entity
+//   - serializer.ValidationErrors, or a single serializer.FieldError, become
+//     400 Bad Request with the details of every field
+//
+// Any other error becomes 500 Internal Server Error without its message,
+// which may contain SQL.
+func ErrorFrom(err error) *APIError {
+	var apiErr *APIError
+	var validationErrs serializer.ValidationErrors
+	var fieldErr *serializer.FieldError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &validationErrs):
+		return fieldErrors(err, validationErrs...)
+	case errors.As(err, &fieldErr):
+		return fieldErrors(err, fieldErr)
+	case errors.Is(err, sql.ErrNoRows):
+		e := apiError(http.StatusNotFound, apierror.CodeNotFound, "record not found")
+		e.Err = err
+		return e
+	case errors.Is(err, orm.ErrUniqueViolation):
+		e := apiError(http.StatusConflict, apierror.CodeConflict, "a record with the same unique values already exists")
+		e.Err = err
+		return e
+	case errors.Is(err, orm.ErrForeignKeyViolation):
+		e := apiError(http.StatusUnprocessableEntity, apierror.CodeForeignKey, "the record references a missing record or is still referenced")
+		e.Err = err
+		return e
+	case errors.Is(err, orm.ErrNotNullViolation), errors.Is(err, orm.ErrCheckViolation):
+		e := apiError(http.StatusUnprocessableEntity, apierror.CodeConstraint, "the record violates a database constraint")
+		e.Err = err
+		return e
+	default:
+		e := apiError(http.StatusInternalServerError, apierror.CodeInternal, "internal server error")
+		e.Err = err
+		return e
+	}
+}
+
+// fieldErrors returns the 400 Bad Request error reporting invalid fields
+func fieldErrors(err error, fieldErrs ...*serializer.FieldError) *APIError {
+	e := apiError(http.StatusBadRequest, apierror.CodeValidation, "validation failed")
+	for _, fieldErr := range fieldErrs {
+		e.Fields = append(e.Fields, apierror.FieldError{Field: fieldErr.Field, Code: fieldErr.Code, Message: fieldErr.Message})
+	}
+	e.Err = err
+	return e
+}
+
+// writeError writes err as the JSON error response to r. Server errors are
+// logged, as their details are not sent.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := ErrorFrom(err)
+	if e.Status >= http.StatusInternalServerError && e.Err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, e.Err)
+	}
+	apierror.Write(w, r, e)
+}
+
+// validationError treats an error returned by a serializer's validation as a
+// client error, unless it already maps to one
+func validationError(err error) error {
+	var apiErr *APIError
+	var validationErrs serializer.ValidationErrors
+	var fieldErr *serializer.FieldError
+	if errors.As(err, &apiErr) || errors.As(err, &validationErrs) || errors.As(err, &fieldErr) {
+		return err
+	}
+	e := apierror.New(http.StatusBadRequest, apierror.CodeValidation, err.Error())
+	e.Err = err
+	return e
+}
+
+// apiError returns an APIError with a formatted message
+func apiError(status int, code, format string, args ...interface{}) *APIError {
+	return apierror.New(status, code, fmt.Sprintf(format, args...))
+}
+
+// badRequest returns an APIError with status 400 Bad Request
+func badRequest(format string, args ...interface{}) error {
+	return apiError(http.StatusBadRequest, apierror.CodeBadRequest, format, args...)
+}
+
+// notFound returns an APIError with status 404 Not Found
+func notFound(format string, args ...interface{}) error {
+	return apiError(http.StatusNotFound, apierror.CodeNotFound, format, args...)
+}
+
+// conflict returns an APIError with status 409 Conflict
+func conflict(format string, args ...interface{}) error {
+	return apiError(http.StatusConflict, apierror.CodeConflict, format, args...)
+}
+
+// unsupportedMediaType returns an APIError with status 415 Unsupported Media Type
+func unsupportedMediaType(format string, args ...interface{}) error {
+	return apiError(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMedia, format, args...)
+}
+
+// unprocessable returns an APIError with status 422 Unprocessable Entity
+func unprocessable(format string, args ...interface{}) error {
+	return apiError(http.StatusUnprocessableEntity, apierror.CodeUnprocessable, format, args...)
+}
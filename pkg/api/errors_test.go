@@ -0,0 +1,166 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/apierror"
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+	"github.com/baxromov/framego/pkg/router"
+	"github.com/baxromov/framego/pkg/serializer"
+)
+
+func TestErrorFrom(t *testing.T) {
+	conflictErr := apiError(http.StatusConflict, apierror.CodeConflict, "taken")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "api error", err: fmt.Errorf("wrapped: %w", conflictErr), status: http.StatusConflict, code: apierror.CodeConflict},
+		{
+			name:   "field error",
+			err:    &serializer.FieldError{Field: "name", Code: serializer.CodeRequired, Message: "field name is required"},
+			status: http.StatusBadRequest,
+			code:   apierror.CodeValidation,
+		},
+		{
+			name: "validation errors",
+			err: serializer.ValidationErrors{
+				{Field: "name", Code: serializer.CodeRequired, Message: "field name is required"},
+				{Field: "age", Code: serializer.CodeInvalidType, Message: "field age has invalid type"},
+			},
+			status: http.StatusBadRequest,
+			code:   apierror.CodeValidation,
+		},
+		{name: "no rows", err: fmt.Errorf("get: %w", sql.ErrNoRows), status: http.StatusNotFound, code: apierror.CodeNotFound},
+		{
+			name:   "unique violation",
+			err:    &orm.ConstraintError{Kind: orm.ErrUniqueViolation, Err: errors.New("UNIQUE constraint failed")},
+			status: http.StatusConflict,
+			code:   apierror.CodeConflict,
+		},
+		{
+			name:   "foreign key violation",
+			err:    &orm.ConstraintError{Kind: orm.ErrForeignKeyViolation, Err: errors.New("FOREIGN KEY constraint failed")},
+			status: http.StatusUnprocessableEntity,
+			code:   apierror.CodeForeignKey,
+		},
+		{
+			name:   "check violation",
+			err:    &orm.ConstraintError{Kind: orm.ErrCheckViolation, Err: errors.New("CHECK constraint failed")},
+			status: http.StatusUnprocessableEntity,
+			code:   apierror.CodeConstraint,
+		},
+		{name: "other", err: errors.New("SELECT * FROM secrets failed"), status: http.StatusInternalServerError, code: apierror.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ErrorFrom(tt.err)
+			if e.Status != tt.status || e.Code != tt.code {
+				t.Errorf("ErrorFrom() = %d %s, want %d %s", e.Status, e.Code, tt.status, tt.code)
+			}
+			if tt.status == http.StatusInternalServerError && strings.Contains(e.Message, "SELECT") {
+				t.Errorf("ErrorFrom() message %q leaks the error", e.Message)
+			}
+		})
+	}
+
+	e := ErrorFrom(&serializer.FieldError{Field: "name", Code: serializer.CodeRequired, Message: "field name is required"})
+	if want := []apierror.FieldError{{Field: "name", Code: "required", Message: "field name is required"}}; !reflect.DeepEqual(e.Fields, want) {
+		t.Errorf("ErrorFrom() fields = %+v, want %+v", e.Fields, want)
+	}
+}
+
+func TestControllerErrors(t *testing.T) {
+	o := openTestORM(t)
+	model := models.NewModel("users")
+	model.AddField("id", reflect.TypeOf(int64(0)), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("email", reflect.TypeOf(""), models.WithNotNull(), models.WithUnique())
+	model.AddField("name", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(10))
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("users", map[string]interface{}{"email": "a@example.com", "name": "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.New()
+	NewController(o, model, "/users").Register(r.Group(""))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []string // Invalid fields as field:code
+	}{
+		{name: "missing record", method: http.MethodGet, path: "/users/9", status: http.StatusNotFound, code: apierror.CodeNotFound},
+		{name: "update missing record", method: http.MethodPut, path: "/users/9", body: `{"email": "b@example.com", "name": "Bob"}`, status: http.StatusNotFound, code: apierror.CodeNotFound},
+		{name: "delete missing record", method: http.MethodDelete, path: "/users/9", status: http.StatusNotFound, code: apierror.CodeNotFound},
+		{name: "invalid JSON", method: http.MethodPost, path: "/users", body: `{`, status: http.StatusBadRequest, code: apierror.CodeBadRequest},
+		{
+			name:   "every invalid field",
+			method: http.MethodPost,
+			path:   "/users",
+			body:   `{"name": "Bartholomew"}`,
+			status: http.StatusBadRequest,
+			code:   apierror.CodeValidation,
+			fields: []string{"email:required", "name:max_length"},
+		},
+		{
+			name:   "duplicate",
+			method: http.MethodPost,
+			path:   "/users",
+			body:   `{"email": "a@example.com", "name": "Bob"}`,
+			status: http.StatusConflict,
+			code:   apierror.CodeConflict,
+		},
+		{name: "unknown route", method: http.MethodGet, path: "/accounts", status: http.StatusNotFound, code: apierror.CodeNotFound},
+		{name: "method not allowed", method: http.MethodPost, path: "/users/1", status: http.StatusMethodNotAllowed, code: apierror.CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(apierror.RequestIDHeader, "req-1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.status)
+			}
+
+			var response struct {
+				Error APIError `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("body %s: %v", w.Body, err)
+			}
+			if response.Error.Code != tt.code || response.Error.RequestID != "req-1" {
+				t.Errorf("error = %+v, want code %s and request ID req-1", response.Error, tt.code)
+			}
+			var fields []string
+			for _, field := range response.Error.Fields {
+				fields = append(fields, field.Field+":"+field.Code)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %q, want %q", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+// Lookups understood in filter query parameters, written field__lookup
+const (
+	LookupExact    = "exact"
+	LookupGte      = "gte"
+	LookupLte      = "lte"
+	LookupIn       = "in"
+	LookupContains = "contains"
+	LookupIsNull   = "isnull"
+)
+
+// timeLayouts are the layouts accepted for time filter values
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// FilterBackend narrows the query of a list endpoint from the request
+type FilterBackend interface {
+	// Filter adds the conditions and ordering requested by r to qs
+	Filter(r *http.Request, qs *orm.QuerySet, c *Controller) error
+}
+
+// Filters filters, searches and orders list results from query parameters, e.g.
+//
+//	?status=pending&total_price__gte=100&search=acme&ordering=-created_at
+//
+// Every other query parameter, apart from those of the paginator and field
+// selection, names a model field, optionally followed by a lookup. The
+// lookups a field accepts follow from its type: strings accept exact, in and
+// contains; numbers and times exact, gte, lte and in; booleans exact;
+// nullable fields also accept isnull. Values of "in" are separated by commas,
+// and values are parsed as the type of the field. Search terms and contains
+// values match literally, including % and _. Unknown fields, lookups and
+// invalid values are rejected with 400 Bad Request.
+type Filters struct {
+	Fields          []string // Fields that may be filtered on; all model fields when empty
+	SearchFields    []string // Fields matched by the search parameter; search is disabled when empty
+	OrderingFields  []string // Fields clients may order by; ordering is disabled when empty
+	DefaultOrdering []string // Ordering used when the request gives none
+	SearchParam     string   // Defaults to "search"
+	OrderingParam   string   // Defaults to "ordering"
+}
+
+// Filter implements FilterBackend
+func (f *Filters) Filter(r *http.Request, qs *orm.QuerySet, c *Controller) error {
+	searchParam := withDefault(f.SearchParam, "search")
+	orderingParam := withDefault(f.OrderingParam, "ordering")
+	fields := c.Model.GetFields()
+
+	reserved := map[string]bool{
+		searchParam:       true,
+		orderingParam:     true,
+		FieldsQueryParam:  true,
+		ExcludeQueryParam: true,
+	}
+	if c.Pagination != nil {
+		for _, param := range c.Pagination.QueryParams() {
+			reserved[param] = true
+		}
+	}
+	allowed := make(map[string]bool, len(f.Fields))
+	for _, name := range f.Fields {
+		allowed[name] = true
+	}
+
+	query := r.URL.Query()
+	params := make([]string, 0, len(query))
+	for param := range query {
+		if !reserved[param] {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		name, lookup := param, LookupExact
+		if i := strings.LastIndex(param, "__"); i >= 0 {
+			name, lookup = param[:i], param[i+2:]
+		}
+		field, ok := fields[name]
+		if !ok || (len(allowed) > 0 && !allowed[name]) {
+			return badRequest("unknown filter field %q", name)
+		}
+		if !lookupsOf(field)[lookup] {
+			return badRequest("unsupported lookup %q for field %q", lookup, name)
+		}
+		for _, raw := range query[param] {
+			if err := applyLookup(qs, field, param, lookup, raw); err != nil {
+				return err
+			}
+		}
+	}
+
+	if terms := strings.Fields(query.Get(searchParam)); len(terms) > 0 && len(f.SearchFields) > 0 {
+		for _, name := range f.SearchFields {
+			if _, ok := fields[name]; !ok {
+				return fmt.Errorf("search field %s is not a field of %s", name, c.Model.GetTableName())
+			}
+		}
+		// Every term must match at least one of the search fields
+		for _, term := range terms {
+			qs.WhereGroup(func(g *orm.QuerySet) {
+				for _, name := range f.SearchFields {
+					g.OrWhereContains(name, term)
+				}
+			})
+		}
+	}
+
+	return f.order(qs, c, query.Get(orderingParam), orderingParam)
+}
+
+// order applies the requested ordering, or the default one
+func (f *Filters) order(qs *orm.QuerySet, c *Controller, requested, orderingParam string) error {
+	// Cursor pagination orders the results itself
+	if _, ok := c.Pagination.(*CursorPagination); ok {
+		if requested != "" {
+			return badRequest("%s is not supported with cursor pagination", orderingParam)
+		}
+		return nil
+	}
+	if requested == "" {
+		if len(f.DefaultOrdering) > 0 {
+			qs.OrderBy(f.DefaultOrdering...)
+		}
+		return nil
+	}
+
+	orderable := make(map[string]bool, len(f.OrderingFields))
+	for _, name := range f.OrderingFields {
+		orderable[name] = true
+	}
+	var ordering []string
+	for _, column := range strings.Split(requested, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		if !orderable[columnOf(column)] {
+			return badRequest("invalid %s field %q", orderingParam, columnOf(column))
+		}
+		ordering = append(ordering, column)
+	}
+	qs.OrderBy(ordering...)
+	return nil
+}
+
+// lookupsOf returns the lookups a field accepts
+func lookupsOf(field models.Field) map[string]bool {
+	lookups := make(map[string]bool)
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		lookups[LookupExact], lookups[LookupGte], lookups[LookupLte], lookups[LookupIn] = true, true, true, true
+	case typ.Kind() == reflect.String:
+		lookups[LookupExact], lookups[LookupIn], lookups[LookupContains] = true, true, true
+	case typ.Kind() == reflect.Bool:
+		lookups[LookupExact] = true
+	case isNumber(typ.Kind()):
+		lookups[LookupExact], lookups[LookupGte], lookups[LookupLte], lookups[LookupIn] = true, true, true, true
+	}
+	if !field.NotNull && !field.PrimaryKey {
+		lookups[LookupIsNull] = true
+	}
+	return lookups
+}
+
+// applyLookup adds the condition of one filter parameter to qs
+func applyLookup(qs *orm.QuerySet, field models.Field, param, lookup, raw string) error {
+	switch lookup {
+	case LookupIsNull:
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return badRequest("invalid value %q for %s: expected a boolean", raw, param)
+		}
+		if isNull {
+			qs.WhereNull(field.Name)
+		} else {
+			qs.WhereNotNull(field.Name)
+		}
+	case LookupIn:
+		var values []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			value, err := parseFilterValue(field, param, part)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		qs.WhereIn(field.Name, values)
+	case LookupContains:
+		qs.WhereContains(field.Name, raw)
+	default:
+		value, err := parseFilterValue(field, param, raw)
+		if err != nil {
+			return err
+		}
+		operator := map[string]string{LookupExact: "=", LookupGte: ">=", LookupLte: "<="}[lookup]
+		qs.Where(field.Name, operator, value)
+	}
+	return nil
+}
+
+// parseFilterValue parses a query parameter value as the type of a field
+func parseFilterValue(field models.Field, param, raw string) (interface{}, error) {
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	invalid := func(expected string) error {
+		return badRequest("invalid value %q for %s: expected %s", raw, param, expected)
+	}
+
+	if typ == reflect.TypeOf(time.Time{}) {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, invalid("a time")
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, invalid("a boolean")
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, invalid("an integer")
+		}
+		return n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, invalid("a non-negative integer")
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, typ.Bits())
+		if err != nil {
+			return nil, invalid("a number")
+		}
+		return n, nil
+	default:
+		return nil, invalid(typ.String())
+	}
+}
+
+// isNumber reports whether values of the kind are numbers
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+func TestParseFilterValue(t *testing.T) {
+	type status string
+	field := func(v interface{}) models.Field { return models.Field{Type: reflect.TypeOf(v)} }
+
+	tests := []struct {
+		name    string
+		field   models.Field
+		raw     string
+		want    interface{}
+		invalid bool
+	}{
+		{name: "string", field: field(""), raw: "50%_off", want: "50%_off"},
+		{name: "named string", field: field(status("")), raw: "pending", want: "pending"},
+		{name: "bool", field: field(false), raw: "true", want: true},
+		{name: "bool digit", field: field(false), raw: "0", want: false},
+		{name: "invalid bool", field: field(false), raw: "yes", invalid: true},
+		{name: "int", field: field(0), raw: "-42", want: int64(-42)},
+		{name: "int8 max", field: field(int8(0)), raw: "127", want: int64(127)},
+		{name: "int8 overflow", field: field(int8(0)), raw: "128", invalid: true},
+		{name: "int fraction", field: field(0), raw: "1.5", invalid: true},
+		{name: "int64 overflow", field: field(int64(0)), raw: "9223372036854775808", invalid: true},
+		{name: "uint", field: field(uint(0)), raw: "7", want: uint64(7)},
+		{name: "negative uint", field: field(uint(0)), raw: "-1", invalid: true},
+		{name: "float", field: field(0.0), raw: "9.99", want: 9.99},
+		{name: "invalid float", field: field(0.0), raw: "cheap", invalid: true},
+		{name: "pointer", field: field(new(int)), raw: "3", want: int64(3)},
+		{name: "RFC 3339 time", field: field(time.Time{}), raw: "2024-01-02T03:04:05Z", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "time without zone", field: field(time.Time{}), raw: "2024-01-02T03:04:05", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "date", field: field(time.Time{}), raw: "2024-01-02", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid time", field: field(time.Time{}), raw: "yesterday", invalid: true},
+		{name: "unsupported type", field: field([]byte(nil)), raw: "abc", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilterValue(tt.field, "param", tt.raw)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("parseFilterValue() = %v, want an error", got)
+				}
+				if status := ErrorFrom(err).Status; status != http.StatusBadRequest {
+					t.Errorf("parseFilterValue() error status = %d, want %d", status, http.StatusBadRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFilterValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFilterValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// filterProduct is the model filtered in TestFilters
+type filterProduct struct {
+	ID        int       `db:"id,pk,autoincrement"`
+	Name      string    `db:"name,notnull"`
+	Price     float64   `db:"price,notnull"`
+	Stock     *int      `db:"stock"`
+	Active    bool      `db:"active,notnull"`
+	CreatedAt time.Time `db:"created_at,notnull"`
+}
+
+func (filterProduct) TableName() string { return "products" }
+
+func TestFilters(t *testing.T) {
+	o := openTestORM(t)
+	model := models.MustFromStruct(&filterProduct{})
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	stock := 5
+	for i, product := range []map[string]interface{}{
+		{"name": "50% off", "price": 5.0, "stock": stock, "active": true},
+		{"name": "500 off", "price": 10.0, "stock": nil, "active": false},
+		{"name": "a_b", "price": 15.0, "stock": stock, "active": true},
+		{"name": "axb", "price": 20.0, "stock": nil, "active": true},
+		{"name": `back\slash`, "price": 25.0, "stock": stock, "active": false},
+	} {
+		product["created_at"] = time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		if _, err := o.Create("products", product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewController(o, model, "/products")
+	filters := &Filters{
+		SearchFields:    []string{"name"},
+		OrderingFields:  []string{"price", "name"},
+		DefaultOrdering: []string{"id"},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		want   []string // Names of the matching products in order
+		status int      // Expected error status, zero for success
+	}{
+		{name: "no filters", query: "", want: []string{"50% off", "500 off", "a_b", "axb", `back\slash`}},
+		{name: "exact", query: "name=axb", want: []string{"axb"}},
+		{name: "explicit exact", query: "name__exact=axb", want: []string{"axb"}},
+		{name: "gte and lte", query: "price__gte=10&price__lte=20", want: []string{"500 off", "a_b", "axb"}},
+		{name: "in", query: "name__in=axb,a_b,missing", want: []string{"a_b", "axb"}},
+		{name: "bool", query: "active=false", want: []string{"500 off", `back\slash`}},
+		{name: "isnull", query: "stock__isnull=true", want: []string{"500 off", "axb"}},
+		{name: "is not null", query: "stock__isnull=false", want: []string{"50% off", "a_b", `back\slash`}},
+		{name: "time", query: "created_at__gte=2024-01-04", want: []string{"axb", `back\slash`}},
+		{name: "contains", query: "name__contains=off", want: []string{"50% off", "500 off"}},
+		{name: "contains percent", query: "name__contains=50%25", want: []string{"50% off"}},
+		{name: "contains underscore", query: "name__contains=_", want: []string{"a_b"}},
+		{name: "contains backslash", query: `name__contains=\`, want: []string{`back\slash`}},
+		{name: "search", query: "search=off+50", want: []string{"50% off", "500 off"}},
+		{name: "search percent", query: "search=%25", want: []string{"50% off"}},
+		{name: "search underscore", query: "search=a_", want: []string{"a_b"}},
+		{name: "ordering", query: "ordering=-price", want: []string{`back\slash`, "axb", "a_b", "500 off", "50% off"}},
+		{name: "paginator parameters are left alone", query: "page=2&fields=name", want: []string{"50% off", "500 off", "a_b", "axb", `back\slash`}},
+		{name: "unknown field", query: "colour=red", status: http.StatusBadRequest},
+		{name: "unknown lookup", query: "name__startswith=a", status: http.StatusBadRequest},
+		{name: "lookup not for type", query: "price__contains=1", status: http.StatusBadRequest},
+		{name: "isnull on not null field", query: "name__isnull=true", status: http.StatusBadRequest},
+		{name: "invalid number", query: "price__gte=cheap", status: http.StatusBadRequest},
+		{name: "invalid in value", query: "price__in=5,cheap", status: http.StatusBadRequest},
+		{name: "invalid isnull", query: "stock__isnull=maybe", status: http.StatusBadRequest},
+		{name: "invalid time", query: "created_at__gte=yesterday", status: http.StatusBadRequest},
+		{name: "unorderable field", query: "ordering=stock", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.Pagination = &PageNumberPagination{}
+			r := httptest.NewRequest(http.MethodGet, "/products?"+tt.query, nil)
+			qs := o.Table("products")
+			err := filters.Filter(r, qs, c)
+			if tt.status != 0 {
+				if err == nil {
+					t.Fatal("Filter() succeeded, want an error")
+				}
+				if status := ErrorFrom(err).Status; status != tt.status {
+					t.Fatalf("Filter() error status = %d (%v), want %d", status, err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Filter() error = %v", err)
+			}
+			rows, err := qs.All()
+			if err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			var got []string
+			for _, row := range rows {
+				got = append(got, row["name"].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
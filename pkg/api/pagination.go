@@ -0,0 +1,473 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+// Paginator splits the results of a list query into pages
+type Paginator interface {
+	// Paginate restricts the query to the page requested by r and runs it
+	Paginate(r *http.Request, qs *orm.QuerySet) (*Page, error)
+
+	// QueryParams returns the query parameters the paginator reads, which
+	// filters leave alone
+	QueryParams() []string
+}
+
+// Page is one page of results together with the URLs of its neighbours.
+// Empty URLs mean there is no such page.
+type Page struct {
+	Results  []map[string]interface{}
+	Count    *int64 // Total number of results, nil when not counted
+	Next     string
+	Previous string
+	First    string
+	Last     string
+}
+
+// LinkHeader returns the RFC 5988 Link header pointing to the neighbouring pages
+func (p *Page) LinkHeader() string {
+	var links []string
+	for _, link := range []struct{ rel, url string }{
+		{"first", p.First},
+		{"prev", p.Previous},
+		{"next", p.Next},
+		{"last", p.Last},
+	} {
+		if link.url != "" {
+			links = append(links, fmt.Sprintf("<%s>; rel=%q", link.url, link.rel))
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// pageEnvelope is the JSON body of a paginated list response
+type pageEnvelope struct {
+	Count    *int64                   `json:"count,omitempty"`
+	Next     *string                  `json:"next"`
+	Previous *string                  `json:"previous"`
+	Results  []map[string]interface{} `json:"results"`
+}
+
+// envelope wraps serialized results in the {count, next, previous, results} envelope
+func (p *Page) envelope(results []map[string]interface{}) pageEnvelope {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	return pageEnvelope{
+		Count:    p.Count,
+		Next:     optional(p.Next),
+		Previous: optional(p.Previous),
+		Results:  results,
+	}
+}
+
+// PageNumberPagination serves numbered pages of a fixed size, e.g. ?page=3
+type PageNumberPagination struct {
+	PageSize           int    // Defaults to 20
+	MaxPageSize        int    // Upper bound for a client-chosen page size; zero means no bound
+	PageQueryParam     string // Defaults to "page"
+	PageSizeQueryParam string // Lets clients choose the page size when set, e.g. "page_size"
+}
+
+// Paginate implements Paginator
+func (p *PageNumberPagination) Paginate(r *http.Request, qs *orm.QuerySet) (*Page, error) {
+	pageParam := withDefault(p.PageQueryParam, "page")
+	size := p.PageSize
+	if size <= 0 {
+		size = 20
+	}
+	if p.PageSizeQueryParam != "" {
+		if requested, ok, err := intParam(r, p.PageSizeQueryParam); err != nil || (ok && requested < 1) {
+			return nil, badRequest("invalid %s", p.PageSizeQueryParam)
+		} else if ok {
+			size = capped(requested, p.MaxPageSize)
+		}
+	}
+
+	number := 1
+	if requested, ok, err := intParam(r, pageParam); err != nil || (ok && requested < 1) {
+		return nil, notFound("invalid page")
+	} else if ok {
+		number = requested
+	}
+
+	count, err := qs.Count()
+	if err != nil {
+		return nil, err
+	}
+	pages := int((count + int64(size) - 1) / int64(size))
+	if pages == 0 {
+		pages = 1
+	}
+	if number > pages {
+		return nil, notFound("invalid page")
+	}
+
+	results, err := qs.Limit(size).Offset((number - 1) * size).All()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Results: results, Count: &count}
+	link := func(n int) string {
+		if n == 1 {
+			return pageURL(r, nil, pageParam)
+		}
+		return pageURL(r, map[string]string{pageParam: strconv.Itoa(n)})
+	}
+	page.First, page.Last = link(1), link(pages)
+	if number > 1 {
+		page.Previous = link(number - 1)
+	}
+	if number < pages {
+		page.Next = link(number + 1)
+	}
+	return page, nil
+}
+
+// QueryParams implements Paginator
+func (p *PageNumberPagination) QueryParams() []string {
+	params := []string{withDefault(p.PageQueryParam, "page")}
+	if p.PageSizeQueryParam != "" {
+		params = append(params, p.PageSizeQueryParam)
+	}
+	return params
+}
+
+// LimitOffsetPagination lets clients choose a window of results, e.g. ?limit=50&offset=100
+type LimitOffsetPagination struct {
+	DefaultLimit     int    // Defaults to 20
+	MaxLimit         int    // Upper bound for limit; zero means no bound
+	LimitQueryParam  string // Defaults to "limit"
+	OffsetQueryParam string // Defaults to "offset"
+}
+
+// Paginate implements Paginator
+func (p *LimitOffsetPagination) Paginate(r *http.Request, qs *orm.QuerySet) (*Page, error) {
+	limitParam := withDefault(p.LimitQueryParam, "limit")
+	offsetParam := withDefault(p.OffsetQueryParam, "offset")
+
+	limit := p.DefaultLimit
+	if limit <= 0 {
+		limit = 20
+	}
+	if requested, ok, err := intParam(r, limitParam); err != nil || (ok && requested < 1) {
+		return nil, badRequest("invalid %s", limitParam)
+	} else if ok {
+		limit = requested
+	}
+	limit = capped(limit, p.MaxLimit)
+
+	offset, _, err := intParam(r, offsetParam)
+	if err != nil || offset < 0 {
+		return nil, badRequest("invalid %s", offsetParam)
+	}
+
+	count, err := qs.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := qs.Limit(limit).Offset(offset).All()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Results: results, Count: &count}
+	window := func(offset int) string {
+		params := map[string]string{limitParam: strconv.Itoa(limit)}
+		if offset > 0 {
+			params[offsetParam] = strconv.Itoa(offset)
+			return pageURL(r, params)
+		}
+		return pageURL(r, params, offsetParam)
+	}
+	if int64(offset+limit) < count {
+		page.Next = window(offset + limit)
+	}
+	if offset > 0 {
+		previous := offset - limit
+		if previous < 0 {
+			previous = 0
+		}
+		page.Previous = window(previous)
+	}
+	return page, nil
+}
+
+// QueryParams implements Paginator
+func (p *LimitOffsetPagination) QueryParams() []string {
+	return []string{withDefault(p.LimitQueryParam, "limit"), withDefault(p.OffsetQueryParam, "offset")}
+}
+
+// CursorPagination pages through results by their position in a fixed
+// ordering (keyset pagination), e.g. ?cursor=eyJ2Ijpb...
+//
+// Rather than skipping rows with OFFSET, each page starts right after the
+// ordering values of the last row of the previous one, so pages stay fast
+// and stable while rows are inserted. The ordering columns must not be NULL
+// and, taken together, must be unique; end the ordering with the primary key
+// to break ties.
+type CursorPagination struct {
+	PageSize         int      // Defaults to 20
+	Ordering         []string // Columns to order by, "-" prefixed for descending; defaults to "id"
+	CursorQueryParam string   // Defaults to "cursor"
+	IncludeCount     bool     // Counting runs an extra query, so it is off by default
+}
+
+// cursor is the decoded position a cursor URL points at
+type cursor struct {
+	Values  []cursorValue `json:"v"`
+	Reverse bool          `json:"r,omitempty"` // Page backwards from the position
+}
+
+// cursorValue keeps the type of a position value across encoding;
+// decodeCursor converts numbers and times back to int64, float64 and time.Time
+type cursorValue struct {
+	Value interface{} `json:"v"`
+	Time  bool        `json:"t,omitempty"`
+}
+
+// Paginate implements Paginator
+func (p *CursorPagination) Paginate(r *http.Request, qs *orm.QuerySet) (*Page, error) {
+	cursorParam := withDefault(p.CursorQueryParam, "cursor")
+	size := p.PageSize
+	if size <= 0 {
+		size = 20
+	}
+	ordering := p.Ordering
+	if len(ordering) == 0 {
+		ordering = []string{"id"}
+	}
+
+	var position *cursor
+	if encoded := r.URL.Query().Get(cursorParam); encoded != "" {
+		decoded, err := decodeCursor(encoded, len(ordering))
+		if err != nil {
+			return nil, badRequest("invalid %s", cursorParam)
+		}
+		position = decoded
+	}
+
+	var count *int64
+	if p.IncludeCount {
+		n, err := qs.Count()
+		if err != nil {
+			return nil, err
+		}
+		count = &n
+	}
+
+	reverse := position != nil && position.Reverse
+	orderBy := make([]string, len(ordering))
+	for i, column := range ordering {
+		orderBy[i] = column
+		if reverse {
+			orderBy[i] = flipDirection(column)
+		}
+	}
+
+	if position != nil {
+		qs.WhereGroup(func(g *orm.QuerySet) {
+			for i := range ordering {
+				i := i
+				g.OrWhereGroup(func(h *orm.QuerySet) {
+					for j := 0; j < i; j++ {
+						h.Where(columnOf(ordering[j]), "=", position.Values[j].Value)
+					}
+					operator := ">"
+					if strings.HasPrefix(orderBy[i], "-") {
+						operator = "<"
+					}
+					h.Where(columnOf(ordering[i]), operator, position.Values[i].Value)
+				})
+			}
+		})
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	results, err := qs.OrderBy(orderBy...).Limit(size + 1).All()
+	if err != nil {
+		return nil, err
+	}
+	more := len(results) > size
+	if more {
+		results = results[:size]
+	}
+	if reverse {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+
+	page := &Page{Results: results, Count: count}
+	if len(results) == 0 {
+		return page, nil
+	}
+
+	link := func(row map[string]interface{}, reverse bool) (string, error) {
+		encoded, err := encodeCursor(row, ordering, reverse)
+		if err != nil {
+			return "", err
+		}
+		return pageURL(r, map[string]string{cursorParam: encoded}), nil
+	}
+	// Moving forwards there is a next page if the extra row was found, and a
+	// previous one if we got here through a cursor; backwards the reverse holds
+	if (!reverse && more) || reverse {
+		if page.Next, err = link(results[len(results)-1], false); err != nil {
+			return nil, err
+		}
+	}
+	if (reverse && more) || (!reverse && position != nil) {
+		if page.Previous, err = link(results[0], true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// QueryParams implements Paginator
+func (p *CursorPagination) QueryParams() []string {
+	return []string{withDefault(p.CursorQueryParam, "cursor")}
+}
+
+// encodeCursor encodes the position of a row in the ordering
+func encodeCursor(row map[string]interface{}, ordering []string, reverse bool) (string, error) {
+	c := cursor{Reverse: reverse}
+	for _, column := range ordering {
+		value, ok := row[columnOf(column)]
+		if !ok {
+			return "", fmt.Errorf("cursor column %s is not selected", columnOf(column))
+		}
+		switch v := value.(type) {
+		case time.Time:
+			c.Values = append(c.Values, cursorValue{Value: v.Format(time.RFC3339Nano), Time: true})
+		case []byte:
+			c.Values = append(c.Values, cursorValue{Value: string(v)})
+		default:
+			c.Values = append(c.Values, cursorValue{Value: v})
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor for an ordering of n columns
+func decodeCursor(encoded string, n int) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	c := &cursor{}
+	if err := decoder.Decode(c); err != nil {
+		return nil, err
+	}
+	if len(c.Values) != n {
+		return nil, fmt.Errorf("cursor has %d values, expected %d", len(c.Values), n)
+	}
+	// Values are converted here, so that a malformed one rejects the cursor
+	for i, value := range c.Values {
+		switch v := value.Value.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				c.Values[i].Value = n
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i].Value = f
+			} else {
+				return nil, fmt.Errorf("invalid number in cursor: %w", err)
+			}
+		case string:
+			if value.Time {
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid time in cursor: %w", err)
+				}
+				c.Values[i].Value = t
+			}
+		}
+		if _, ok := c.Values[i].Value.(time.Time); value.Time && !ok {
+			return nil, fmt.Errorf("invalid time in cursor")
+		}
+	}
+	return c, nil
+}
+
+// columnOf strips the descending prefix from an ordering column
+func columnOf(ordering string) string {
+	return strings.TrimPrefix(ordering, "-")
+}
+
+// flipDirection reverses the direction of an ordering column
+func flipDirection(ordering string) string {
+	if strings.HasPrefix(ordering, "-") {
+		return ordering[1:]
+	}
+	return "-" + ordering
+}
+
+// pageURL returns the absolute URL of the request with the given query
+// parameters set and the others listed removed
+func pageURL(r *http.Request, set map[string]string, remove ...string) string {
+	query := r.URL.Query()
+	for key, value := range set {
+		query.Set(key, value)
+	}
+	for _, key := range remove {
+		query.Del(key)
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// intParam reads an integer query parameter, reporting whether it was given
+func intParam(r *http.Request, name string) (int, bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, true, err
+	}
+	return n, true, nil
+}
+
+// capped limits n to max when max is positive
+func capped(n, max int) int {
+	if max > 0 && n > max {
+		return max
+	}
+	return n
+}
+
+// withDefault returns value, or fallback when value is empty
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
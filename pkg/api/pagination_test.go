@@ -0,0 +1,374 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/orm"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestORM returns an ORM on a private in-memory SQLite database on which
+// the given statements have been run
+func openTestORM(t *testing.T, stmts ...string) *orm.ORM {
+	t.Helper()
+	// One connection, as every connection opens a new in-memory database
+	o, err := orm.New(orm.Config{Driver: "sqlite3", Database: ":memory:", MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	for _, stmt := range stmts {
+		if _, err := o.DB().Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return o
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+
+	tests := []struct {
+		name     string
+		row      map[string]interface{}
+		ordering []string
+		reverse  bool
+		want     []interface{}
+	}{
+		{
+			name:     "integer",
+			row:      map[string]interface{}{"id": int64(42)},
+			ordering: []string{"id"},
+			want:     []interface{}{int64(42)},
+		},
+		{
+			name:     "backwards",
+			row:      map[string]interface{}{"id": int64(42)},
+			ordering: []string{"id"},
+			reverse:  true,
+			want:     []interface{}{int64(42)},
+		},
+		{
+			name:     "mixed directions",
+			row:      map[string]interface{}{"id": int64(7), "score": 9.5, "name": "Acme"},
+			ordering: []string{"-score", "name", "id"},
+			want:     []interface{}{9.5, "Acme", int64(7)},
+		},
+		{
+			name:     "time keeps nanoseconds",
+			row:      map[string]interface{}{"id": int64(1), "created_at": created},
+			ordering: []string{"-created_at", "id"},
+			want:     []interface{}{created, int64(1)},
+		},
+		{
+			name:     "bytes become strings",
+			row:      map[string]interface{}{"code": []byte("A-1")},
+			ordering: []string{"code"},
+			want:     []interface{}{"A-1"},
+		},
+		{
+			name:     "large integer",
+			row:      map[string]interface{}{"id": int64(1<<62 + 1)},
+			ordering: []string{"id"},
+			want:     []interface{}{int64(1<<62 + 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeCursor(tt.row, tt.ordering, tt.reverse)
+			if err != nil {
+				t.Fatalf("encodeCursor() error = %v", err)
+			}
+			c, err := decodeCursor(encoded, len(tt.ordering))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if c.Reverse != tt.reverse {
+				t.Errorf("Reverse = %v, want %v", c.Reverse, tt.reverse)
+			}
+			var got []interface{}
+			for _, value := range c.Values {
+				got = append(got, value.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCursorMissingColumn(t *testing.T) {
+	if _, err := encodeCursor(map[string]interface{}{"id": 1}, []string{"-created_at", "id"}, false); err == nil {
+		t.Error("encodeCursor() succeeded without the ordering column")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		encoded string
+		n       int
+	}{
+		{name: "not base64", encoded: "!!!", n: 1},
+		{name: "not JSON", encoded: encode(`{"v":`), n: 1},
+		{name: "too few values", encoded: encode(`{"v":[{"v":1}]}`), n: 2},
+		{name: "too many values", encoded: encode(`{"v":[{"v":1},{"v":2}]}`), n: 1},
+		{name: "malformed time", encoded: encode(`{"v":[{"v":"yesterday","t":true}]}`), n: 1},
+		{name: "time without zone", encoded: encode(`{"v":[{"v":"2024-01-02T03:04:05","t":true}]}`), n: 1},
+		{name: "number as time", encoded: encode(`{"v":[{"v":1704164645,"t":true}]}`), n: 1},
+		{name: "number out of range", encoded: encode(`{"v":[{"v":1e400}]}`), n: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodeCursor(tt.encoded, tt.n); err == nil {
+				t.Errorf("decodeCursor() = %+v, want an error", c)
+			}
+		})
+	}
+}
+
+func TestCursorPaginationInvalidCursor(t *testing.T) {
+	p := &CursorPagination{Ordering: []string{"-created_at", "id"}}
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"v":[{"v":"not a time","t":true},{"v":1}]}`))
+	r := httptest.NewRequest(http.MethodGet, "/items?cursor="+cursor, nil)
+
+	// The cursor is rejected before the query is used
+	_, err := p.Paginate(r, nil)
+	if err == nil {
+		t.Fatal("Paginate() succeeded with an invalid cursor")
+	}
+	if status := ErrorFrom(err).Status; status != http.StatusBadRequest {
+		t.Errorf("Paginate() error status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	o := openTestORM(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, score INTEGER NOT NULL, created_at TIMESTAMP NOT NULL)")
+	// Scores repeat, so ties are broken by the next ordering column
+	for _, item := range []struct{ id, score, day int }{{1, 3, 1}, {2, 1, 3}, {3, 3, 2}, {4, 2, 5}, {5, 1, 4}, {6, 3, 6}, {7, 2, 7}} {
+		createdAt := time.Date(2024, 1, item.day, 0, 0, 0, 0, time.UTC)
+		if _, err := o.DB().Exec("INSERT INTO items (id, score, created_at) VALUES (?, ?, ?)", item.id, item.score, createdAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		ordering []string
+		want     []int64 // ids in order
+	}{
+		{name: "primary key", ordering: nil, want: []int64{1, 2, 3, 4, 5, 6, 7}},
+		{name: "descending", ordering: []string{"-id"}, want: []int64{7, 6, 5, 4, 3, 2, 1}},
+		{name: "mixed directions", ordering: []string{"-score", "id"}, want: []int64{1, 3, 6, 4, 7, 2, 5}},
+		{name: "mixed directions reversed", ordering: []string{"score", "-id"}, want: []int64{5, 2, 7, 4, 6, 3, 1}},
+		{name: "times", ordering: []string{"-created_at", "id"}, want: []int64{7, 6, 4, 5, 2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CursorPagination{PageSize: 3, Ordering: tt.ordering}
+			paginate := func(url string) *Page {
+				t.Helper()
+				page, err := p.Paginate(httptest.NewRequest(http.MethodGet, url, nil), o.Table("items"))
+				if err != nil {
+					t.Fatalf("Paginate(%s) error = %v", url, err)
+				}
+				return page
+			}
+
+			// Forwards through every page
+			var pages []*Page
+			var got []int64
+			for page := paginate("http://example.com/items"); ; page = paginate(page.Next) {
+				pages = append(pages, page)
+				got = append(got, ids(page)...)
+				if page.Next == "" {
+					break
+				}
+				if len(pages) > len(tt.want) {
+					t.Fatal("pagination does not end")
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("forwards = %v, want %v", got, tt.want)
+			}
+			if pages[0].Previous != "" {
+				t.Errorf("first page has a previous page %s", pages[0].Previous)
+			}
+
+			// Backwards from the last page, each page matches the one seen
+			// moving forwards
+			last := pages[len(pages)-1]
+			for i := len(pages) - 2; i >= 0; i-- {
+				if last.Previous == "" {
+					t.Fatalf("page %d has no previous page", i+1)
+				}
+				previous := paginate(last.Previous)
+				if !reflect.DeepEqual(ids(previous), ids(pages[i])) {
+					t.Errorf("backwards page %d = %v, want %v", i, ids(previous), ids(pages[i]))
+				}
+				if previous.Next == "" {
+					t.Errorf("backwards page %d has no next page", i)
+				}
+				last = previous
+			}
+			if last.Previous != "" {
+				t.Errorf("first page reached backwards has a previous page %s", last.Previous)
+			}
+		})
+	}
+}
+
+// openItemsORM returns a test ORM with items 1 to n
+func openItemsORM(t *testing.T, n int) *orm.ORM {
+	t.Helper()
+	o := openTestORM(t, "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	for id := 1; id <= n; id++ {
+		if _, err := o.DB().Exec("INSERT INTO items (id) VALUES (?)", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return o
+}
+
+// ids returns the ids of the results on a page
+func ids(page *Page) []int64 {
+	var ids []int64
+	for _, row := range page.Results {
+		ids = append(ids, row["id"].(int64))
+	}
+	return ids
+}
+
+func TestPageNumberPagination(t *testing.T) {
+	o := openItemsORM(t, 7)
+	p := &PageNumberPagination{PageSize: 3, MaxPageSize: 4, PageSizeQueryParam: "page_size"}
+
+	tests := []struct {
+		url      string
+		ids      []int64
+		next     string
+		previous string
+		last     string
+		status   int // Status of the error, zero for success
+	}{
+		{
+			url:  "/items",
+			ids:  []int64{1, 2, 3},
+			next: "http://example.com/items?page=2",
+			last: "http://example.com/items?page=3",
+		},
+		{
+			url:      "/items?page=2",
+			ids:      []int64{4, 5, 6},
+			next:     "http://example.com/items?page=3",
+			previous: "http://example.com/items",
+			last:     "http://example.com/items?page=3",
+		},
+		{
+			url:      "/items?page=3",
+			ids:      []int64{7},
+			previous: "http://example.com/items?page=2",
+			last:     "http://example.com/items?page=3",
+		},
+		{
+			url:  "/items?page_size=100",
+			ids:  []int64{1, 2, 3, 4},
+			next: "http://example.com/items?page=2&page_size=100",
+			last: "http://example.com/items?page=2&page_size=100",
+		},
+		{url: "/items?page=4", status: http.StatusNotFound},
+		{url: "/items?page=0", status: http.StatusNotFound},
+		{url: "/items?page=last", status: http.StatusNotFound},
+		{url: "/items?page_size=0", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			page, err := p.Paginate(httptest.NewRequest(http.MethodGet, tt.url, nil), o.Table("items"))
+			if tt.status != 0 {
+				if status := ErrorFrom(err).Status; status != tt.status {
+					t.Errorf("Paginate() error = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Paginate() error = %v", err)
+			}
+			if !reflect.DeepEqual(ids(page), tt.ids) {
+				t.Errorf("ids = %v, want %v", ids(page), tt.ids)
+			}
+			if page.Count == nil || *page.Count != 7 {
+				t.Errorf("Count = %v, want 7", page.Count)
+			}
+			if page.Next != tt.next || page.Previous != tt.previous || page.Last != tt.last {
+				t.Errorf("links = next %q, previous %q, last %q, want %q, %q, %q",
+					page.Next, page.Previous, page.Last, tt.next, tt.previous, tt.last)
+			}
+		})
+	}
+}
+
+func TestLimitOffsetPagination(t *testing.T) {
+	o := openItemsORM(t, 7)
+	p := &LimitOffsetPagination{DefaultLimit: 3, MaxLimit: 5}
+
+	tests := []struct {
+		url      string
+		ids      []int64
+		next     string
+		previous string
+		status   int
+	}{
+		{url: "/items", ids: []int64{1, 2, 3}, next: "http://example.com/items?limit=3&offset=3"},
+		{
+			url:      "/items?offset=2",
+			ids:      []int64{3, 4, 5},
+			next:     "http://example.com/items?limit=3&offset=5",
+			previous: "http://example.com/items?limit=3",
+		},
+		{url: "/items?limit=2&offset=6", ids: []int64{7}, previous: "http://example.com/items?limit=2&offset=4"},
+		{url: "/items?limit=50", ids: []int64{1, 2, 3, 4, 5}, next: "http://example.com/items?limit=5&offset=5"},
+		{url: "/items?limit=0", status: http.StatusBadRequest},
+		{url: "/items?offset=-1", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			page, err := p.Paginate(httptest.NewRequest(http.MethodGet, tt.url, nil), o.Table("items"))
+			if tt.status != 0 {
+				if status := ErrorFrom(err).Status; status != tt.status {
+					t.Errorf("Paginate() error = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Paginate() error = %v", err)
+			}
+			if !reflect.DeepEqual(ids(page), tt.ids) {
+				t.Errorf("ids = %v, want %v", ids(page), tt.ids)
+			}
+			if page.Next != tt.next || page.Previous != tt.previous {
+				t.Errorf("links = next %q, previous %q, want %q, %q", page.Next, page.Previous, tt.next, tt.previous)
+			}
+		})
+	}
+}
+
+func TestPageLinkHeader(t *testing.T) {
+	page := &Page{Next: "http://example.com/items?page=3", Previous: "http://example.com/items", First: "http://example.com/items"}
+	want := `<http://example.com/items>; rel="first", <http://example.com/items>; rel="prev", <http://example.com/items?page=3>; rel="next"`
+	if got := page.LinkHeader(); got != want {
+		t.Errorf("LinkHeader() = %s, want %s", got, want)
+	}
+}
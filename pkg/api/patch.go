@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Media types of the patch documents accepted by PartialUpdate
+const (
+	MergePatchType = "application/merge-patch+json" // RFC 7396
+	JSONPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+// acceptPatch is the Accept-Patch header advertised by PartialUpdate
+var acceptPatch = strings.Join([]string{"application/json", MergePatchType, JSONPatchType}, ", ")
+
+// patchOperation is one operation of a JSON Patch document
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"` // Empty when missing; null is a value
+}
+
+// decodePatch reads the patch document of a PATCH request and returns the
+// changed fields of the record with their new values; removed fields map to
+// nil. JSON Patch operations are applied to current, the record as clients
+// see it.
+func decodePatch(r *http.Request, current map[string]interface{}) (map[string]interface{}, error) {
+	mediaType := "application/json"
+	if header := r.Header.Get("Content-Type"); header != "" {
+		parsed, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return nil, unsupportedMediaType("invalid Content-Type")
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case "application/json", MergePatchType:
+		// A merge patch of a flat record replaces the fields it names; null
+		// removes a field, which clears the column
+		var patch map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return nil, badRequest("invalid merge patch: %v", err)
+		}
+		if patch == nil {
+			return nil, badRequest("invalid merge patch: expected an object")
+		}
+		return patch, nil
+	case JSONPatchType:
+		var operations []patchOperation
+		if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+			return nil, badRequest("invalid JSON patch: %v", err)
+		}
+		return applyJSONPatch(current, operations)
+	default:
+		return nil, unsupportedMediaType("unsupported patch media type %s", mediaType)
+	}
+}
+
+// applyJSONPatch applies JSON Patch operations to a copy of a record and
+// returns the fields they changed. Records are flat, so paths name a field.
+func applyJSONPatch(record map[string]interface{}, operations []patchOperation) (map[string]interface{}, error) {
+	document := make(map[string]interface{}, len(record))
+	for key, value := range record {
+		document[key] = value
+	}
+	changed := make(map[string]bool)
+
+	for i, operation := range operations {
+		field, err := patchField(operation.Path)
+		if err != nil {
+			return nil, badRequest("operation %d: %v", i, err)
+		}
+		value := func() (interface{}, error) {
+			if len(operation.Value) == 0 {
+				return nil, badRequest("operation %d: %s requires a value", i, operation.Op)
+			}
+			var v interface{}
+			if err := json.Unmarshal(operation.Value, &v); err != nil {
+				return nil, badRequest("operation %d: invalid value: %v", i, err)
+			}
+			return v, nil
+		}
+		existing := func(field string) (interface{}, error) {
+			v, ok := document[field]
+			if !ok {
+				return nil, unprocessable("operation %d: field %q does not exist", i, field)
+			}
+			return v, nil
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			if operation.Op == "replace" {
+				if _, err := existing(field); err != nil {
+					return nil, err
+				}
+			}
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			document[field] = v
+			changed[field] = true
+		case "remove":
+			if _, err := existing(field); err != nil {
+				return nil, err
+			}
+			document[field] = nil
+			changed[field] = true
+		case "move", "copy":
+			from, err := patchField(operation.From)
+			if err != nil {
+				return nil, badRequest("operation %d: %v", i, err)
+			}
+			v, err := existing(from)
+			if err != nil {
+				return nil, err
+			}
+			if operation.Op == "move" && from != field {
+				document[from] = nil
+				changed[from] = true
+			}
+			document[field] = v
+			changed[field] = true
+		case "test":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			current, err := existing(field)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, v) {
+				return nil, conflict("operation %d: test of field %q failed", i, field)
+			}
+		default:
+			return nil, badRequest("operation %d: unknown op %q", i, operation.Op)
+		}
+	}
+
+	patch := make(map[string]interface{}, len(changed))
+	for field := range changed {
+		patch[field] = document[field]
+	}
+	return patch, nil
+}
+
+// patchField returns the field a JSON Pointer such as "/status" refers to
+func patchField(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return "", fmt.Errorf("invalid path %q", pointer)
+	}
+	field := pointer[1:]
+	if field == "" || strings.Contains(field, "/") {
+		return "", fmt.Errorf("path %q does not name a field", pointer)
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(field), nil
+}
+
+// jsonEqual reports whether two values have the same JSON representation
+func jsonEqual(a, b interface{}) bool {
+	normalize := func(v interface{}) (interface{}, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var normalized interface{}
+		err = json.Unmarshal(data, &normalized)
+		return normalized, err
+	}
+	na, errA := normalize(a)
+	nb, errB := normalize(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(na, nb)
+}
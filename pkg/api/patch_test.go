@@ -0,0 +1,363 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/router"
+	"github.com/baxromov/framego/pkg/serializer"
+)
+
+func TestDecodePatch(t *testing.T) {
+	current := map[string]interface{}{"name": "Acme", "status": "pending", "total": float64(10)}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        map[string]interface{}
+		status      int // Expected error status, zero for success
+	}{
+		{
+			name: "merge patch without content type",
+			body: `{"status": "shipped"}`,
+			want: map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name:        "merge patch",
+			contentType: MergePatchType,
+			body:        `{"status": "shipped", "total": 12.5}`,
+			want:        map[string]interface{}{"status": "shipped", "total": 12.5},
+		},
+		{
+			name:        "merge patch null clears a field",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name": null}`,
+			want:        map[string]interface{}{"name": nil},
+		},
+		{
+			name:        "merge patch must be an object",
+			contentType: MergePatchType,
+			body:        `null`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid merge patch",
+			contentType: MergePatchType,
+			body:        `{"status":`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "JSON patch",
+			contentType: JSONPatchType,
+			body:        `[{"op": "replace", "path": "/status", "value": "shipped"}]`,
+			want:        map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name:        "invalid JSON patch",
+			contentType: JSONPatchType,
+			body:        `{"op": "replace"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        `status=shipped`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "invalid content type",
+			contentType: "application/",
+			body:        `{}`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/orders/1", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			got, err := decodePatch(r, current)
+			if tt.status != 0 {
+				if err == nil {
+					t.Fatalf("decodePatch() = %v, want status %d", got, tt.status)
+				}
+				if status := ErrorFrom(err).Status; status != tt.status {
+					t.Fatalf("decodePatch() error status = %d (%v), want %d", status, err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodePatch() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodePatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	record := map[string]interface{}{
+		"name":   "Acme",
+		"status": "pending",
+		"total":  float64(10),
+		"tags":   []interface{}{"a", "b"},
+		"a/b":    "slash",
+		"m~n":    "tilde",
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		want   map[string]interface{}
+		status int // Expected error status, zero for success
+	}{
+		{
+			name: "add",
+			body: `[{"op": "add", "path": "/notes", "value": "fragile"}]`,
+			want: map[string]interface{}{"notes": "fragile"},
+		},
+		{
+			name: "add replaces an existing field",
+			body: `[{"op": "add", "path": "/status", "value": "shipped"}]`,
+			want: map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name: "add null",
+			body: `[{"op": "add", "path": "/name", "value": null}]`,
+			want: map[string]interface{}{"name": nil},
+		},
+		{
+			name:   "missing value",
+			body:   `[{"op": "replace", "path": "/status"}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "replace",
+			body: `[{"op": "replace", "path": "/total", "value": 12}]`,
+			want: map[string]interface{}{"total": float64(12)},
+		},
+		{
+			name:   "replace a missing field",
+			body:   `[{"op": "replace", "path": "/notes", "value": "fragile"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "replace without a value",
+			body:   `[{"op": "replace", "path": "/status"}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "remove",
+			body: `[{"op": "remove", "path": "/name"}]`,
+			want: map[string]interface{}{"name": nil},
+		},
+		{
+			name:   "remove a missing field",
+			body:   `[{"op": "remove", "path": "/notes"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "move",
+			body: `[{"op": "move", "from": "/name", "path": "/status"}]`,
+			want: map[string]interface{}{"name": nil, "status": "Acme"},
+		},
+		{
+			name: "move to itself",
+			body: `[{"op": "move", "from": "/name", "path": "/name"}]`,
+			want: map[string]interface{}{"name": "Acme"},
+		},
+		{
+			name: "copy",
+			body: `[{"op": "copy", "from": "/name", "path": "/status"}]`,
+			want: map[string]interface{}{"status": "Acme"},
+		},
+		{
+			name:   "copy from a missing field",
+			body:   `[{"op": "copy", "from": "/notes", "path": "/status"}]`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "test passes",
+			body: `[{"op": "test", "path": "/total", "value": 10}, {"op": "replace", "path": "/status", "value": "shipped"}]`,
+			want: map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name: "test compares arrays",
+			body: `[{"op": "test", "path": "/tags", "value": ["a", "b"]}]`,
+			want: map[string]interface{}{},
+		},
+		{
+			name:   "test fails",
+			body:   `[{"op": "test", "path": "/status", "value": "shipped"}, {"op": "replace", "path": "/status", "value": "cancelled"}]`,
+			status: http.StatusConflict,
+		},
+		{
+			name: "operations see earlier ones",
+			body: `[{"op": "replace", "path": "/status", "value": "shipped"}, {"op": "test", "path": "/status", "value": "shipped"}]`,
+			want: map[string]interface{}{"status": "shipped"},
+		},
+		{
+			name: "escaped paths",
+			body: `[{"op": "replace", "path": "/a~1b", "value": 1}, {"op": "replace", "path": "/m~0n", "value": 2}]`,
+			want: map[string]interface{}{"a/b": float64(1), "m~n": float64(2)},
+		},
+		{
+			name:   "nested path",
+			body:   `[{"op": "replace", "path": "/tags/0", "value": "c"}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "path without a slash",
+			body:   `[{"op": "replace", "path": "status", "value": "shipped"}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "root path",
+			body:   `[{"op": "replace", "path": "/", "value": "shipped"}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown op",
+			body:   `[{"op": "increment", "path": "/total", "value": 1}]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "no operations",
+			body: `[]`,
+			want: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/orders/1", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", JSONPatchType)
+			got, err := decodePatch(r, record)
+			if tt.status != 0 {
+				if err == nil {
+					t.Fatalf("applyJSONPatch() = %v, want status %d", got, tt.status)
+				}
+				if status := ErrorFrom(err).Status; status != tt.status {
+					t.Fatalf("applyJSONPatch() error status = %d (%v), want %d", status, err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyJSONPatch() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyJSONPatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if record["status"] != "pending" || record["name"] != "Acme" {
+		t.Errorf("applyJSONPatch() changed the record: %v", record)
+	}
+}
+
+func TestPartialUpdate(t *testing.T) {
+	o := openTestORM(t)
+	model := models.NewModel("orders")
+	model.AddField("id", reflect.TypeOf(int64(0)), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("customer", reflect.TypeOf(""), models.WithNotNull())
+	model.AddField("status", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(10))
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("orders", map[string]interface{}{"customer": "Acme", "status": "pending"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewController(o, model, "/orders")
+	c.SetSerializer(serializer.New(model))
+	r := router.New()
+	c.Register(r.Group(""))
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		want        map[string]interface{} // Stored order afterwards
+	}{
+		{
+			name:   "merge patch leaves other fields alone",
+			path:   "/orders/1",
+			body:   `{"status": "paid"}`,
+			status: http.StatusOK,
+			want:   map[string]interface{}{"id": int64(1), "customer": "Acme", "status": "paid"},
+		},
+		{
+			name:        "JSON patch",
+			path:        "/orders/1",
+			contentType: JSONPatchType,
+			body:        `[{"op": "test", "path": "/status", "value": "paid"}, {"op": "replace", "path": "/status", "value": "shipped"}]`,
+			status:      http.StatusOK,
+			want:        map[string]interface{}{"id": int64(1), "customer": "Acme", "status": "shipped"},
+		},
+		{
+			name:        "failed test operation",
+			path:        "/orders/1",
+			contentType: JSONPatchType,
+			body:        `[{"op": "test", "path": "/status", "value": "paid"}, {"op": "replace", "path": "/status", "value": "lost"}]`,
+			status:      http.StatusConflict,
+			want:        map[string]interface{}{"id": int64(1), "customer": "Acme", "status": "shipped"},
+		},
+		{
+			name:   "changed fields are validated",
+			path:   "/orders/1",
+			body:   `{"status": "waiting for payment"}`,
+			status: http.StatusBadRequest,
+			want:   map[string]interface{}{"id": int64(1), "customer": "Acme", "status": "shipped"},
+		},
+		{
+			name:        "unsupported media type",
+			path:        "/orders/1",
+			contentType: "text/plain",
+			body:        `status=paid`,
+			status:      http.StatusUnsupportedMediaType,
+			want:        map[string]interface{}{"id": int64(1), "customer": "Acme", "status": "shipped"},
+		},
+		{name: "missing order", path: "/orders/2", body: `{"status": "paid"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body, tt.status)
+			}
+			if w.Header().Get("Accept-Patch") == "" {
+				t.Error("response has no Accept-Patch header")
+			}
+			if tt.want == nil {
+				return
+			}
+			row, err := o.Get("orders", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(row, tt.want) {
+				t.Errorf("order = %v, want %v", row, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/baxromov/framego/pkg/apierror"
+	"github.com/baxromov/framego/pkg/router"
+)
+
+// Standard actions of a view set
+const (
+	ActionList          = "list"           // GET /resource
+	ActionCreate        = "create"         // POST /resource
+	ActionRetrieve      = "retrieve"       // GET /resource/:id
+	ActionUpdate        = "update"         // PUT /resource/:id
+	ActionPartialUpdate = "partial_update" // PATCH /resource/:id
+	ActionDestroy       = "destroy"        // DELETE /resource/:id
+)
+
+// standardActions lists the standard actions in the order they are mounted
+var standardActions = []struct {
+	name   string
+	method string
+	detail bool
+}{
+	{ActionList, http.MethodGet, false},
+	{ActionCreate, http.MethodPost, false},
+	{ActionRetrieve, http.MethodGet, true},
+	{ActionUpdate, http.MethodPut, true},
+	{ActionPartialUpdate, http.MethodPatch, true},
+	{ActionDestroy, http.MethodDelete, true},
+}
+
+// Permission decides whether a request may run an action of a view set
+type Permission interface {
+	HasPermission(r *http.Request, action string) bool
+}
+
+// PermissionFunc adapts a function to the Permission interface
+type PermissionFunc func(r *http.Request, action string) bool
+
+// HasPermission implements Permission
+func (f PermissionFunc) HasPermission(r *http.Request, action string) bool {
+	return f(r, action)
+}
+
+// ForActions restricts a permission to the given actions; the others are allowed
+func ForActions(permission Permission, actions ...string) Permission {
+	restricted := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		restricted[action] = true
+	}
+	return PermissionFunc(func(r *http.Request, action string) bool {
+		return !restricted[action] || permission.HasPermission(r, action)
+	})
+}
+
+// Action is an extra route of a view set, e.g. POST /orders/:id/cancel
+type Action struct {
+	Name    string // Identifies the action to middleware and permissions
+	Method  string // Defaults to POST
+	Path    string // Path below the resource; defaults to Name with dashes for underscores
+	Detail  bool   // Acts on one record, mounted below /:id
+	Handler http.HandlerFunc
+}
+
+// ViewSet mounts the actions of a controller as routes of a router group
+type ViewSet struct {
+	Controller  *Controller
+	Actions     []string     // Standard actions to mount; all when empty
+	Permissions []Permission // Checked for every action once its middleware has run
+	middleware  map[string][]router.Middleware
+	handlers    map[string]http.HandlerFunc
+	extra       []Action
+}
+
+// NewViewSet creates a view set mounting every standard action of the controller
+func NewViewSet(c *Controller) *ViewSet {
+	return &ViewSet{
+		Controller: c,
+		middleware: make(map[string][]router.Middleware),
+		handlers: map[string]http.HandlerFunc{
+			ActionList:          c.List,
+			ActionCreate:        c.Create,
+			ActionRetrieve:      c.Get,
+			ActionUpdate:        c.Update,
+			ActionPartialUpdate: c.PartialUpdate,
+			ActionDestroy:       c.Delete,
+		},
+	}
+}
+
+// Only limits the standard actions mounted, e.g. to make a read-only view set
+func (v *ViewSet) Only(actions ...string) *ViewSet {
+	v.Actions = actions
+	return v
+}
+
+// Use adds middleware to the given actions, or to every action when none are given
+func (v *ViewSet) Use(middleware router.Middleware, actions ...string) *ViewSet {
+	if len(actions) == 0 {
+		actions = []string{""}
+	}
+	for _, action := range actions {
+		v.middleware[action] = append(v.middleware[action], middleware)
+	}
+	return v
+}
+
+// Permit adds permissions checked for every action
+func (v *ViewSet) Permit(permissions ...Permission) *ViewSet {
+	v.Permissions = append(v.Permissions, permissions...)
+	return v
+}
+
+// Handle replaces the handler of a standard action
+func (v *ViewSet) Handle(action string, handler http.HandlerFunc) *ViewSet {
+	v.handlers[action] = handler
+	return v
+}
+
+// Action adds an extra action
+func (v *ViewSet) Action(action Action) *ViewSet {
+	v.extra = append(v.extra, action)
+	return v
+}
+
+// Register mounts the actions on the group, below the controller's base path
+// with the group's prefix removed: a controller for /api/orders registered on
+// the /api group serves /api/orders and /api/orders/:id.
+func (v *ViewSet) Register(group *router.Group) {
+	base := v.Controller.BasePath
+	if prefix := strings.TrimSuffix(group.Prefix, "/"); prefix != "" && strings.HasPrefix(base, prefix) {
+		if rest := base[len(prefix):]; rest == "" || strings.HasPrefix(rest, "/") {
+			base = rest
+		}
+	}
+	base = strings.TrimSuffix(base, "/")
+	path := func(detail bool, suffix string) string {
+		p := base
+		if detail {
+			p += "/:id"
+		}
+		if suffix != "" {
+			p += "/" + suffix
+		}
+		if p == "" {
+			p = "/"
+		}
+		return p
+	}
+
+	// Extra actions come first so that /orders/export is not taken for /orders/:id
+	for _, action := range v.extra {
+		method := action.Method
+		if method == "" {
+			method = http.MethodPost
+		}
+		suffix := action.Path
+		if suffix == "" {
+			suffix = strings.ReplaceAll(action.Name, "_", "-")
+		}
+		v.mount(group, method, path(action.Detail, strings.Trim(suffix, "/")), action.Name, action.Handler)
+	}
+
+	mounted := make(map[string]bool, len(v.Actions))
+	for _, action := range v.Actions {
+		mounted[action] = true
+	}
+	for _, action := range standardActions {
+		handler := v.handlers[action.name]
+		if handler == nil || (len(v.Actions) > 0 && !mounted[action.name]) {
+			continue
+		}
+		v.mount(group, action.method, path(action.detail, ""), action.name, handler)
+	}
+}
+
+// mount registers one action, checking permissions inside its middleware
+func (v *ViewSet) mount(group *router.Group, method, pattern, action string, handler http.HandlerFunc) {
+	permissions := v.Permissions
+	next := handler
+	if len(permissions) > 0 {
+		next = func(w http.ResponseWriter, r *http.Request) {
+			for _, permission := range permissions {
+				if !permission.HasPermission(r, action) {
+					writeError(w, r, apiError(http.StatusForbidden, apierror.CodeForbidden, "permission denied"))
+					return
+				}
+			}
+			handler(w, r)
+		}
+	}
+
+	middleware := append(append([]router.Middleware{}, v.middleware[""]...), v.middleware[action]...)
+	group.Handle(method, pattern, next, middleware...)
+}
+
+// Register mounts every standard action of the controller on the group; use a
+// ViewSet to add middleware, permissions or extra actions
+func (c *Controller) Register(group *router.Group) {
+	NewViewSet(c).Register(group)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/router"
+)
+
+// record returns a handler writing the action and the :id parameter it served
+func record(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(action + " " + router.GetPathParam(r, "id")))
+	}
+}
+
+// newRecordingViewSet returns a view set for /api/orders whose standard
+// actions are replaced by recording handlers
+func newRecordingViewSet() *ViewSet {
+	v := NewViewSet(&Controller{BasePath: "/api/orders"})
+	for _, action := range []string{ActionList, ActionCreate, ActionRetrieve, ActionUpdate, ActionPartialUpdate, ActionDestroy} {
+		v.Handle(action, record(action))
+	}
+	return v
+}
+
+// serve sends a request to the router and returns the status and body
+func serve(r *router.Router, method, path string) (int, string) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestViewSetRoutes(t *testing.T) {
+	r := router.New()
+	newRecordingViewSet().
+		Action(Action{Name: "export", Method: http.MethodGet, Handler: record("export")}).
+		Action(Action{Name: "mark_paid", Detail: true, Handler: record("mark_paid")}).
+		Register(r.Group("/api"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: http.MethodGet, path: "/api/orders", status: http.StatusOK, body: "list "},
+		{method: http.MethodPost, path: "/api/orders", status: http.StatusOK, body: "create "},
+		{method: http.MethodGet, path: "/api/orders/7", status: http.StatusOK, body: "retrieve 7"},
+		{method: http.MethodPut, path: "/api/orders/7", status: http.StatusOK, body: "update 7"},
+		{method: http.MethodDelete, path: "/api/orders/7", status: http.StatusOK, body: "destroy 7"},
+		{method: http.MethodGet, path: "/api/orders/export", status: http.StatusOK, body: "export "},
+		{method: http.MethodPost, path: "/api/orders/7/mark-paid", status: http.StatusOK, body: "mark_paid 7"},
+		{method: http.MethodPatch, path: "/api/orders/7", status: http.StatusOK, body: "partial_update 7"},
+		{method: http.MethodGet, path: "/api/api/orders", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			status, body := serve(r, tt.method, tt.path)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if tt.body != "" && body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestViewSetOnly(t *testing.T) {
+	r := router.New()
+	newRecordingViewSet().Only(ActionList, ActionRetrieve).Register(r.Group(""))
+
+	if status, _ := serve(r, http.MethodGet, "/api/orders/1"); status != http.StatusOK {
+		t.Errorf("retrieve status = %d, want %d", status, http.StatusOK)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		path := "/api/orders/1"
+		if method == http.MethodPost {
+			path = "/api/orders"
+		}
+		if status, _ := serve(r, method, path); status != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s status = %d, want %d", method, path, status, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func TestViewSetMiddlewareAndPermissions(t *testing.T) {
+	var calls []string
+	middleware := func(name string) router.Middleware {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next(w, r)
+			}
+		}
+	}
+	staff := PermissionFunc(func(r *http.Request, action string) bool {
+		calls = append(calls, "permission "+action)
+		return r.Header.Get("X-Staff") == "true"
+	})
+
+	r := router.New()
+	newRecordingViewSet().
+		Use(middleware("all")).
+		Use(middleware("writes"), ActionCreate, ActionDestroy).
+		Permit(ForActions(staff, ActionDestroy)).
+		Register(r.Group("/api"))
+
+	tests := []struct {
+		method string
+		path   string
+		staff  bool
+		status int
+		calls  []string
+	}{
+		{method: http.MethodGet, path: "/api/orders", status: http.StatusOK, calls: []string{"all"}},
+		{method: http.MethodPost, path: "/api/orders", status: http.StatusOK, calls: []string{"all", "writes"}},
+		{
+			method: http.MethodDelete, path: "/api/orders/1", status: http.StatusForbidden,
+			calls: []string{"all", "writes", "permission destroy"},
+		},
+		{
+			method: http.MethodDelete, path: "/api/orders/1", staff: true, status: http.StatusOK,
+			calls: []string{"all", "writes", "permission destroy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			calls = nil
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.staff {
+				req.Header.Set("X-Staff", "true")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("calls = %q, want %q", calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package apierror renders HTTP API errors as JSON.
+//
+// Errors are written as
+//
+//	{"error": {"code": "not_found", "message": "record not found", "request_id": "..."}}
+//
+// or, when the client accepts application/problem+json or ProblemDetails is
+// set, as an RFC 7807 problem document.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ProblemDetails makes Write render every error as application/problem+json,
+// rather than only for clients that ask for it in their Accept header
+var ProblemDetails = false
+
+// RequestIDHeader carries the ID of a request, see middleware.RequestID
+const RequestIDHeader = "X-Request-ID"
+
+// Error codes used by the framework
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_error"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "permission_denied"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeUnprocessable    = "unprocessable_entity"
+	CodeForeignKey       = "foreign_key_violation"
+	CodeConstraint       = "constraint_violation"
+	CodeInternal         = "internal_error"
+)
+
+// Error is an error returned to API clients
+type Error struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Fields    []FieldError `json:"fields,omitempty"` // Per-field details of validation errors
+	RequestID string       `json:"request_id,omitempty"`
+	Err       error        `json:"-"` // Underlying cause, never sent to clients
+}
+
+// FieldError describes why the value of one field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// New creates an error with the given status, code and message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// problem is the RFC 7807 representation of an Error
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Write writes the error as the response to r, filling in the request ID
+func Write(w http.ResponseWriter, r *http.Request, e *Error) {
+	status := e.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	body := *e
+	if body.RequestID == "" {
+		body.RequestID = RequestID(w, r)
+	}
+
+	if ProblemDetails || accepts(r, "application/problem+json") {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    body.Message,
+			Instance:  r.URL.Path,
+			Code:      body.Code,
+			Errors:    body.Fields,
+			RequestID: body.RequestID,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]*Error{"error": &body})
+}
+
+// RequestID returns the ID of the request, as set on the response or sent by the client
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// accepts reports whether the Accept header of r lists the media type
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if i := strings.Index(accepted, ";"); i >= 0 {
+			accepted = accepted[:i]
+		}
+		if strings.EqualFold(strings.TrimSpace(accepted), mediaType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	e := New(http.StatusBadRequest, CodeValidation, "validation failed")
+	e.Fields = []FieldError{{Field: "name", Code: "required", Message: "field name is required"}}
+	e.Err = errors.New("SELECT secret FROM users")
+
+	tests := []struct {
+		name        string
+		accept      string
+		problem     bool // ProblemDetails
+		contentType string
+		body        map[string]interface{}
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body: map[string]interface{}{"error": map[string]interface{}{
+				"code":       "validation_error",
+				"message":    "validation failed",
+				"fields":     []interface{}{map[string]interface{}{"field": "name", "code": "required", "message": "field name is required"}},
+				"request_id": "req-1",
+			}},
+		},
+		{
+			name:        "problem requested",
+			accept:      "text/html, application/problem+json;q=0.9",
+			contentType: "application/problem+json",
+			body: map[string]interface{}{
+				"type":       "about:blank",
+				"title":      "Bad Request",
+				"status":     float64(400),
+				"detail":     "validation failed",
+				"instance":   "/users",
+				"code":       "validation_error",
+				"errors":     []interface{}{map[string]interface{}{"field": "name", "code": "required", "message": "field name is required"}},
+				"request_id": "req-1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users", nil)
+			r.Header.Set(RequestIDHeader, "req-1")
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			Write(w, r, e)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %s, want %s", got, tt.contentType)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tt.body) {
+				t.Errorf("body = %v, want %v", body, tt.body)
+			}
+		})
+	}
+
+	if e.RequestID != "" {
+		t.Error("Write() modified the error")
+	}
+}
+
+func TestWriteDefaults(t *testing.T) {
+	ProblemDetails = true
+	defer func() { ProblemDetails = false }()
+
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req-2")
+	Write(w, httptest.NewRequest(http.MethodGet, "/", nil), &Error{Code: CodeInternal, Message: "internal server error"})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	var body problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Type") != "application/problem+json" || body.Status != 500 || body.RequestID != "req-2" {
+		t.Errorf("response = %s %+v", w.Header().Get("Content-Type"), body)
+	}
+}
@@ -39,6 +39,65 @@ type DatabaseConfig struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Database string `json:"database"`
+
+	// QueryTimeout is the default per-query timeout, e.g. "5s"
+	QueryTimeout Duration `json:"query_timeout"`
+
+	// Connection pool settings
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+
+	// Params are extra driver parameters appended to the DSN
+	Params map[string]string `json:"params,omitempty"`
+
+	// DSN overrides the connection string built from the fields above
+	DSN string `json:"dsn,omitempty"`
+
+	// Replicas are read-only copies of the database; empty fields are taken from the primary
+	Replicas []DatabaseConfig `json:"replicas,omitempty"`
+
+	// HealthCheckInterval is how often replicas are pinged, e.g. "30s"
+	HealthCheckInterval Duration `json:"health_check_interval"`
+
+	// Databases are additional named databases, e.g. "analytics"
+	Databases map[string]DatabaseConfig `json:"databases,omitempty"`
+}
+
+// Duration is a time.Duration read from JSON as a string such as "30s" or as a number of seconds
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the duration from a string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		d.Duration = time.Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		d.Duration = parsed
+	case nil:
+		d.Duration = 0
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+
+	return nil
 }
 
 // ServerConfig represents the server configuration
@@ -129,14 +188,44 @@ func (c *Config) SaveToFile(filename string) error {
 
 // ToORMConfig converts the database configuration to an ORM configuration
 func (c *Config) ToORMConfig() orm.Config {
-	return orm.Config{
-		Driver:   c.Database.Driver,
-		Host:     c.Database.Host,
-		Port:     c.Database.Port,
-		User:     c.Database.User,
-		Password: c.Database.Password,
-		Database: c.Database.Database,
+	return c.Database.ToORMConfig()
+}
+
+// ToORMConfig converts a database configuration, including its replicas and
+// named databases, to an ORM configuration
+func (d DatabaseConfig) ToORMConfig() orm.Config {
+	config := orm.Config{
+		Driver:   d.Driver,
+		Host:     d.Host,
+		Port:     d.Port,
+		User:     d.User,
+		Password: d.Password,
+		Database: d.Database,
+
+		QueryTimeout: d.QueryTimeout.Duration,
+
+		MaxOpenConns:    d.MaxOpenConns,
+		MaxIdleConns:    d.MaxIdleConns,
+		ConnMaxLifetime: d.ConnMaxLifetime.Duration,
+		ConnMaxIdleTime: d.ConnMaxIdleTime.Duration,
+		Params:          d.Params,
+		DSN:             d.DSN,
+
+		HealthCheckInterval: d.HealthCheckInterval.Duration,
 	}
+
+	for _, replica := range d.Replicas {
+		config.Replicas = append(config.Replicas, replica.ToORMConfig())
+	}
+
+	if len(d.Databases) > 0 {
+		config.Databases = make(map[string]orm.Config, len(d.Databases))
+		for name, database := range d.Databases {
+			config.Databases[name] = database.ToORMConfig()
+		}
+	}
+
+	return config
 }
 
 // generateRandomKey generates a random key of the specified length
@@ -178,6 +267,41 @@ func LoadFromEnv() *Config {
 	config.Database.User = GetEnv("DB_USER", config.Database.User)
 	config.Database.Password = GetEnv("DB_PASSWORD", config.Database.Password)
 	config.Database.Database = GetEnv("DB_NAME", config.Database.Database)
+	config.Database.DSN = GetEnv("DB_DSN", config.Database.DSN)
+	if timeout := GetEnv("DB_QUERY_TIMEOUT", ""); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			config.Database.QueryTimeout.Duration = d
+		}
+	}
+	if n := GetEnv("DB_MAX_OPEN_CONNS", ""); n != "" {
+		fmt.Sscanf(n, "%d", &config.Database.MaxOpenConns)
+	}
+	if n := GetEnv("DB_MAX_IDLE_CONNS", ""); n != "" {
+		fmt.Sscanf(n, "%d", &config.Database.MaxIdleConns)
+	}
+	if lifetime := GetEnv("DB_CONN_MAX_LIFETIME", ""); lifetime != "" {
+		if d, err := time.ParseDuration(lifetime); err == nil {
+			config.Database.ConnMaxLifetime.Duration = d
+		}
+	}
+	if idle := GetEnv("DB_CONN_MAX_IDLE_TIME", ""); idle != "" {
+		if d, err := time.ParseDuration(idle); err == nil {
+			config.Database.ConnMaxIdleTime.Duration = d
+		}
+	}
+	// Replicas share every setting of the primary except the host
+	if hosts := GetEnv("DB_REPLICA_HOSTS", ""); hosts != "" {
+		for _, host := range strings.Split(hosts, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				config.Database.Replicas = append(config.Database.Replicas, DatabaseConfig{Host: host})
+			}
+		}
+	}
+	if interval := GetEnv("DB_HEALTH_CHECK_INTERVAL", ""); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			config.Database.HealthCheckInterval.Duration = d
+		}
+	}
 
 	// Server configuration
 	config.Server.Host = GetEnv("SERVER_HOST", config.Server.Host)
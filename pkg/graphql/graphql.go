@@ -4,14 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"reflect"
 	"strings"
 	"time"
 
+	"github.com/baxromov/framego/pkg/api"
+	"github.com/baxromov/framego/pkg/apierror"
 	"github.com/baxromov/framego/pkg/models"
 	"github.com/baxromov/framego/pkg/orm"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
 )
 
 // Handler represents a GraphQL handler
@@ -153,7 +157,7 @@ func (h *Handler) RegisterModel(model models.ModelInterface) error {
 		Args:        make(map[string]*Argument),
 		Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
 			// Query all records
-			results, err := h.ORM.Query(fmt.Sprintf("SELECT * FROM %s", tableName))
+			results, err := h.ORM.Table(tableName).WithContext(ctx).All()
 			if err != nil {
 				return nil, err
 			}
@@ -176,9 +180,9 @@ func (h *Handler) RegisterModel(model models.ModelInterface) error {
 			// Get record by ID
 			id, ok := args["id"]
 			if !ok {
-				return nil, fmt.Errorf("id is required")
+				return nil, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "id is required")
 			}
-			result, err := h.ORM.Get(tableName, id)
+			result, err := h.ORM.GetContext(ctx, tableName, id)
 			if err != nil {
 				return nil, err
 			}
@@ -193,13 +197,8 @@ func (h *Handler) RegisterModel(model models.ModelInterface) error {
 		Type:        typeName,
 		Args:        createInputArgs(model),
 		Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
-			// Create record
-			id, err := h.ORM.Create(tableName, args)
-			if err != nil {
-				return nil, err
-			}
-			// Get created record
-			result, err := h.ORM.Get(tableName, id)
+			// Create record and return it as stored
+			result, err := h.ORM.InsertContext(ctx, tableName, args)
 			if err != nil {
 				return nil, err
 			}
@@ -216,16 +215,16 @@ func (h *Handler) RegisterModel(model models.ModelInterface) error {
 			// Get ID from args
 			id, ok := args["id"]
 			if !ok {
-				return nil, fmt.Errorf("id is required")
+				return nil, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "id is required")
 			}
 			delete(args, "id")
 
 			// Update record
-			if err := h.ORM.Update(tableName, id, args); err != nil {
+			if err := h.ORM.UpdateContext(ctx, tableName, id, args); err != nil {
 				return nil, err
 			}
 			// Get updated record
-			result, err := h.ORM.Get(tableName, id)
+			result, err := h.ORM.GetContext(ctx, tableName, id)
 			if err != nil {
 				return nil, err
 			}
@@ -248,11 +247,11 @@ func (h *Handler) RegisterModel(model models.ModelInterface) error {
 			// Get ID from args
 			id, ok := args["id"]
 			if !ok {
-				return nil, fmt.Errorf("id is required")
+				return nil, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "id is required")
 			}
 
 			// Delete record
-			if err := h.ORM.Delete(tableName, id); err != nil {
+			if err := h.ORM.DeleteContext(ctx, tableName, id); err != nil {
 				return nil, err
 			}
 			return true, nil
@@ -310,7 +309,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Variables map[string]interface{} `json:"variables"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, r, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "invalid JSON body: "+err.Error()))
 			return
 		}
 		query = request.Query
@@ -321,20 +320,21 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		variablesStr := r.URL.Query().Get("variables")
 		if variablesStr != "" {
 			if err := json.Unmarshal([]byte(variablesStr), &variables); err != nil {
-				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				writeError(w, r, apierror.New(http.StatusBadRequest, apierror.CodeBadRequest, "invalid variables"))
 				return
 			}
 		}
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method "+r.Method+" not allowed"))
 		return
 	}
 
-	// Execute query
-	result, err := h.ExecuteQuery(query, variables)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Execute query; errors are reported in the result as GraphQL requires
+	result := h.execute(r.Context(), query, variables)
+	requestID := apierror.RequestID(w, r)
+	for i, formatted := range result.Errors {
+		result.Errors[i] = formatError(r, formatted, requestID)
 	}
 
 	// Write response
@@ -342,18 +342,74 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// formatError adds the error code and request ID to a GraphQL error. Errors
+// raised by resolvers are mapped like REST errors, see api.ErrorFrom, so
+// database errors are not sent to clients.
+func formatError(r *http.Request, formatted gqlerrors.FormattedError, requestID string) gqlerrors.FormattedError {
+	extensions := map[string]interface{}{}
+	for key, value := range formatted.Extensions {
+		extensions[key] = value
+	}
+
+	cause := formatted.OriginalError()
+	if located, ok := cause.(*gqlerrors.Error); ok {
+		cause = located.OriginalError
+	}
+	if cause == nil {
+		// Syntax and validation errors of the query itself
+		extensions["code"] = apierror.CodeBadRequest
+	} else {
+		e := api.ErrorFrom(cause)
+		if e.Status >= http.StatusInternalServerError {
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, cause)
+		}
+		formatted.Message = e.Message
+		extensions["code"] = e.Code
+		if len(e.Fields) > 0 {
+			extensions["fields"] = e.Fields
+		}
+	}
+	if requestID != "" {
+		extensions["request_id"] = requestID
+	}
+	formatted.Extensions = extensions
+	return formatted
+}
+
+// writeError writes a request error in the GraphQL response format
+func writeError(w http.ResponseWriter, r *http.Request, e *apierror.Error) {
+	extensions := map[string]interface{}{"code": e.Code}
+	if requestID := apierror.RequestID(w, r); requestID != "" {
+		extensions["request_id"] = requestID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{"message": e.Message, "extensions": extensions}},
+	})
+}
+
 // ExecuteQuery executes a GraphQL query
 func (h *Handler) ExecuteQuery(query string, variables map[string]interface{}) (interface{}, error) {
-	// Use graphql-go to execute the query
+	return h.ExecuteQueryContext(context.Background(), query, variables)
+}
+
+// ExecuteQueryContext executes a GraphQL query using the given context
+func (h *Handler) ExecuteQueryContext(ctx context.Context, query string, variables map[string]interface{}) (interface{}, error) {
+	result := h.execute(ctx, query, variables)
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("errors: %+v", result.Errors)
+	}
+	return result, nil
+}
+
+// execute runs a GraphQL query with graphql-go
+func (h *Handler) execute(ctx context.Context, query string, variables map[string]interface{}) *graphql.Result {
 	params := graphql.Params{
 		Schema:         h.GQLSchema,
 		RequestString:  query,
 		VariableValues: variables,
-		Context:        context.Background(),
+		Context:        ctx,
 	}
-	result := graphql.Do(params)
-	if len(result.Errors) > 0 {
-		return nil, fmt.Errorf("errors: %+v", result.Errors)
-	}
-	return result, nil
+	return graphql.Do(params)
 }
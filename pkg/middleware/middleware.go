@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
 	"time"
+
+	"github.com/baxromov/framego/pkg/apierror"
 )
 
 // Logger is a middleware that logs requests
@@ -22,7 +26,7 @@ func Logger(next http.HandlerFunc) http.HandlerFunc {
 func CORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		
 		if r.Method == "OPTIONS" {
@@ -41,7 +45,7 @@ func Auth(next http.HandlerFunc) http.HandlerFunc {
 		// In a real application, you would check for a valid token
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, "authentication required"))
 			return
 		}
 		
@@ -55,10 +59,28 @@ func Recovery(next http.HandlerFunc) http.HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 				log.Printf("Panic: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "internal server error"))
 			}
 		}()
 		
 		next(w, r)
 	}
-}
\ No newline at end of file
+}
+
+// RequestID is a middleware that identifies every request. It keeps the
+// X-Request-ID header sent by the client or generates one, and echoes it in
+// the response so that errors and logs can be correlated.
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+			r.Header.Set(apierror.RequestIDHeader, id)
+		}
+		w.Header().Set(apierror.RequestIDHeader, id)
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/apierror"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(apierror.RequestIDHeader)
+	})
+
+	tests := []struct {
+		name  string
+		sent  string
+		keeps bool // The client's ID is used
+	}{
+		{name: "generated"},
+		{name: "kept", sent: "req-1", keeps: true},
+		{name: "too long", sent: strings.Repeat("x", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.sent != "" {
+				r.Header.Set(apierror.RequestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			id := w.Header().Get(apierror.RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("response ID = %q, handler saw %q", id, seen)
+			}
+			if (id == tt.sent) != tt.keeps {
+				t.Errorf("ID = %q for %q sent", id, tt.sent)
+			}
+			if !tt.keeps && len(id) != 32 {
+				t.Errorf("generated ID %q is not 16 hex-encoded bytes", id)
+			}
+		})
+	}
+}
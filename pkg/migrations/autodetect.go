@@ -0,0 +1,181 @@
+package migrations
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Diff returns the operations that transform the schema state from into to
+func Diff(from, to State) []Operation {
+	var ops []Operation
+
+	// Create new tables, referenced tables first
+	var created []string
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			created = append(created, name)
+		}
+	}
+	for _, name := range dependencyOrder(to, created) {
+		table := to[name]
+		ops = append(ops, Operation{Type: CreateTable, Table: name, Columns: table.clone().Columns, Checks: table.clone().Checks})
+		for _, index := range table.Indexes {
+			index := index
+			ops = append(ops, Operation{Type: AddIndex, Table: name, Index: &index})
+		}
+	}
+
+	// Alter existing tables
+	var existing []string
+	for name := range to {
+		if _, ok := from[name]; ok {
+			existing = append(existing, name)
+		}
+	}
+	sort.Strings(existing)
+	for _, name := range existing {
+		ops = append(ops, diffTable(from[name], to[name])...)
+	}
+
+	// Drop removed tables, referencing tables first
+	var dropped []string
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			dropped = append(dropped, name)
+		}
+	}
+	order := dependencyOrder(from, dropped)
+	for i := len(order) - 1; i >= 0; i-- {
+		table := from[order[i]]
+		for _, index := range table.Indexes {
+			index := index
+			ops = append(ops, Operation{Type: DropIndex, Table: table.Name, Index: &index})
+		}
+		ops = append(ops, Operation{Type: DropTable, Table: table.Name, Columns: table.clone().Columns, Checks: table.clone().Checks})
+	}
+
+	return ops
+}
+
+// diffTable returns the operations that transform one table into another
+func diffTable(from, to *Table) []Operation {
+	var ops []Operation
+	name := to.Name
+
+	// Indexes and checks are dropped first so that columns they cover can be changed
+	for _, index := range from.Indexes {
+		i := to.index(index.Name)
+		if i < 0 || !reflect.DeepEqual(index, to.Indexes[i]) {
+			index := index
+			ops = append(ops, Operation{Type: DropIndex, Table: name, Index: &index})
+		}
+	}
+	for _, check := range from.Checks {
+		i := to.check(check.Name)
+		if i < 0 || check != to.Checks[i] {
+			check := check
+			ops = append(ops, Operation{Type: DropCheck, Table: name, Check: &check})
+		}
+	}
+
+	for _, old := range from.Columns {
+		if _, _, ok := to.column(old.Name); !ok {
+			old := old
+			ops = append(ops, Operation{Type: DropColumn, Table: name, Column: &old})
+		}
+	}
+
+	for _, column := range to.Columns {
+		column := column
+		old, _, ok := from.column(column.Name)
+		if !ok {
+			added := column
+			added.ForeignKey = nil
+			ops = append(ops, Operation{Type: AddColumn, Table: name, Column: &added})
+			if column.ForeignKey != nil {
+				ops = append(ops, Operation{Type: AddForeignKey, Table: name, Column: &column})
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(old, column) {
+			continue
+		}
+
+		// Changes limited to the foreign key get their own operations
+		withoutFK, oldWithoutFK := column, old
+		withoutFK.ForeignKey, oldWithoutFK.ForeignKey = nil, nil
+		if reflect.DeepEqual(oldWithoutFK, withoutFK) {
+			if old.ForeignKey == nil {
+				ops = append(ops, Operation{Type: AddForeignKey, Table: name, Column: &column})
+				continue
+			}
+			if column.ForeignKey == nil {
+				ops = append(ops, Operation{Type: DropForeignKey, Table: name, Column: &old})
+				continue
+			}
+		}
+
+		ops = append(ops, Operation{Type: AlterColumn, Table: name, Column: &column, Previous: &old})
+	}
+
+	for _, index := range to.Indexes {
+		i := from.index(index.Name)
+		if i < 0 || !reflect.DeepEqual(index, from.Indexes[i]) {
+			index := index
+			ops = append(ops, Operation{Type: AddIndex, Table: name, Index: &index})
+		}
+	}
+	for _, check := range to.Checks {
+		i := from.check(check.Name)
+		if i < 0 || check != from.Checks[i] {
+			check := check
+			ops = append(ops, Operation{Type: AddCheck, Table: name, Check: &check})
+		}
+	}
+
+	return ops
+}
+
+// dependencyOrder sorts the given tables so that tables referenced by foreign
+// keys come before the tables referencing them. Cycles fall back to name order.
+func dependencyOrder(state State, names []string) []string {
+	sort.Strings(names)
+
+	pending := make(map[string]bool, len(names))
+	for _, name := range names {
+		pending[name] = true
+	}
+
+	var order []string
+	for len(pending) > 0 {
+		progressed := false
+		for _, name := range names {
+			if !pending[name] {
+				continue
+			}
+			ready := true
+			for _, column := range state[name].Columns {
+				if column.ForeignKey != nil && column.ForeignKey.Model != name && pending[column.ForeignKey.Model] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, name)
+				delete(pending, name)
+				progressed = true
+			}
+		}
+		if !progressed {
+			for _, name := range names {
+				if pending[name] {
+					order = append(order, name)
+					delete(pending, name)
+				}
+			}
+		}
+	}
+
+	return order
+}
@@ -0,0 +1,176 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+func TestStateFromModels(t *testing.T) {
+	model := models.NewModel("posts")
+	model.AddField("title", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(200))
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("author_id", reflect.TypeOf(int64(0)), models.WithForeignKey("users", "id", "CASCADE", ""))
+	model.AddField("status", reflect.TypeOf(""), models.WithDefault("draft"))
+	model.AddField("views", reflect.TypeOf(0), models.WithDefault(0))
+	model.AddIndex("posts_title", []string{"title"}, models.IndexOptions{Unique: true})
+	model.AddIndex("posts_author", []string{"author_id", "status"}, models.IndexOptions{Where: "status = 'draft'"})
+	model.AddCheck("posts_views", "views >= 0")
+
+	state, err := StateFromModels(map[string]models.ModelInterface{"posts": model})
+	if err != nil {
+		t.Fatalf("StateFromModels() error = %v", err)
+	}
+
+	// Primary keys come first, then columns by name; defaults are stored as
+	// they are read back from JSON
+	want := &Table{Name: "posts", Columns: []Column{
+		{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true},
+		{Name: "author_id", Type: "int64", ForeignKey: &models.ForeignKey{Model: "users", Field: "id", OnDelete: "CASCADE"}},
+		{Name: "status", Type: "string", Default: "draft"},
+		{Name: "title", Type: "string", NotNull: true, MaxLength: 200},
+		{Name: "views", Type: "int", Default: float64(0)},
+	}, Indexes: []Index{
+		{Name: "posts_author", Columns: []string{"author_id", "status"}, Where: "status = 'draft'"},
+		{Name: "posts_title", Columns: []string{"title"}, Unique: true},
+	}, Checks: []Check{
+		{Name: "posts_views", Expression: "views >= 0"},
+	}}
+	if !reflect.DeepEqual(state["posts"], want) {
+		t.Errorf("StateFromModels() = %+v, want %+v", state["posts"], want)
+	}
+
+	// Columns convert back to the fields they came from
+	for _, column := range want.Columns {
+		field := column.Field()
+		if field.Name != column.Name || field.Type != fieldTypes[column.Type] || field.PrimaryKey != column.PrimaryKey {
+			t.Errorf("Field() = %+v for column %+v", field, column)
+		}
+	}
+
+	unsupported := models.NewModel("things")
+	unsupported.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey())
+	unsupported.AddField("tags", reflect.TypeOf([]string{}))
+	if _, err := StateFromModels(map[string]models.ModelInterface{"things": unsupported}); err == nil {
+		t.Error("StateFromModels() accepted a field of unsupported type")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	id := Column{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true}
+	name := Column{Name: "name", Type: "string", NotNull: true}
+	userID := Column{Name: "user_id", Type: "int", ForeignKey: &models.ForeignKey{Model: "users", Field: "id"}}
+	table := func(name string, columns ...Column) *Table {
+		return &Table{Name: name, Columns: columns}
+	}
+	withIndex := func(t *Table, index Index) *Table {
+		t.Indexes = append(t.Indexes, index)
+		return t
+	}
+	withCheck := func(t *Table, check Check) *Table {
+		t.Checks = append(t.Checks, check)
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		from, to State
+		want     []string // Operations, see Operation.String
+	}{
+		{
+			name: "no changes",
+			from: State{"users": table("users", id, name)},
+			to:   State{"users": table("users", id, name)},
+		},
+		{
+			name: "referenced tables are created first",
+			from: State{},
+			to:   State{"posts": table("posts", id, userID), "users": table("users", id, name)},
+			want: []string{"create_table users", "create_table posts"},
+		},
+		{
+			name: "referencing tables are dropped first",
+			from: State{"posts": table("posts", id, userID), "users": table("users", id, name)},
+			to:   State{},
+			want: []string{"drop_table posts", "drop_table users"},
+		},
+		{
+			name: "add and drop columns",
+			from: State{"users": table("users", id, name)},
+			to:   State{"users": table("users", id, Column{Name: "email", Type: "string"})},
+			want: []string{"drop_column users.name", "add_column users.email"},
+		},
+		{
+			name: "added foreign key column",
+			from: State{"posts": table("posts", id)},
+			to:   State{"posts": table("posts", id, userID)},
+			want: []string{"add_column posts.user_id", "add_foreign_key posts.user_id"},
+		},
+		{
+			name: "alter column",
+			from: State{"users": table("users", id, name)},
+			to:   State{"users": table("users", id, Column{Name: "name", Type: "string", MaxLength: 100})},
+			want: []string{"alter_column users.name"},
+		},
+		{
+			name: "foreign key added",
+			from: State{"posts": table("posts", id, Column{Name: "user_id", Type: "int"})},
+			to:   State{"posts": table("posts", id, userID)},
+			want: []string{"add_foreign_key posts.user_id"},
+		},
+		{
+			name: "foreign key dropped",
+			from: State{"posts": table("posts", id, userID)},
+			to:   State{"posts": table("posts", id, Column{Name: "user_id", Type: "int"})},
+			want: []string{"drop_foreign_key posts.user_id"},
+		},
+		{
+			name: "changed index is dropped and added",
+			from: State{"users": withIndex(table("users", id, name), Index{Name: "users_name", Columns: []string{"name"}})},
+			to:   State{"users": withIndex(table("users", id, name), Index{Name: "users_name", Columns: []string{"name"}, Unique: true})},
+			want: []string{"drop_index users_name on users", "add_index users_name on users"},
+		},
+		{
+			name: "check added",
+			from: State{"users": table("users", id, name)},
+			to:   State{"users": withCheck(table("users", id, name), Check{Name: "users_name", Expression: "name != ''"})},
+			want: []string{"add_check users_name on users"},
+		},
+		{
+			name: "changed check is dropped and added",
+			from: State{"users": withCheck(table("users", id, name), Check{Name: "users_name", Expression: "name != ''"})},
+			to:   State{"users": withCheck(table("users", id, name), Check{Name: "users_name", Expression: "length(name) > 1"})},
+			want: []string{"drop_check users_name on users", "add_check users_name on users"},
+		},
+		{
+			name: "checks are created with the table",
+			from: State{},
+			to:   State{"users": withCheck(table("users", id, name), Check{Name: "users_name", Expression: "name != ''"})},
+			want: []string{"create_table users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, op := range Diff(tt.from, tt.to) {
+				got = append(got, op.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+
+			// Applying the operations turns from into to
+			state := tt.from.Clone()
+			for _, op := range Diff(tt.from, tt.to) {
+				if err := op.Apply(state); err != nil {
+					t.Fatalf("Apply(%s) error = %v", op, err)
+				}
+			}
+			if !reflect.DeepEqual(state, tt.to) {
+				t.Errorf("applied state = %+v, want %+v", state, tt.to)
+			}
+		})
+	}
+}
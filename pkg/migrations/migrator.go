@@ -0,0 +1,511 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+// HistoryTable is the table recording applied migrations
+const HistoryTable = "framego_migrations"
+
+// migrationNamePattern matches migration file names without the extension
+var migrationNamePattern = regexp.MustCompile(`^[0-9]{4}_[A-Za-z0-9_]+$`)
+
+// Migration represents a single migration file
+type Migration struct {
+	Name       string      `json:"name"`
+	Operations []Operation `json:"operations"`
+}
+
+// Migrator generates and applies migrations for the models registered with an ORM
+type Migrator struct {
+	ORM *orm.ORM
+	Dir string
+}
+
+// New creates a new migrator storing migration files in dir
+func New(o *orm.ORM, dir string) *Migrator {
+	return &Migrator{
+		ORM: o,
+		Dir: dir,
+	}
+}
+
+// Load reads all migration files from the migrations directory, in order
+func (m *Migrator) Load() ([]*Migration, error) {
+	files, err := filepath.Glob(filepath.Join(m.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var migrations []*Migration
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		if !migrationNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		migration := &Migration{}
+		if err := json.Unmarshal(data, migration); err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", name, err)
+		}
+		migration.Name = name
+
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+// MakeMigrations compares the registered models with the state recorded by the
+// existing migrations and writes a new migration file for the differences.
+// It returns nil if no changes were detected.
+//
+// Only models of the migrator's database are compared; models registered in
+// a named database are migrated by a migrator for that database's ORM, see
+// orm.ORM.Database, with its own directory.
+func (m *Migrator) MakeMigrations(name string) (*Migration, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := replay(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := StateFromModels(m.ORM.Models())
+	if err != nil {
+		return nil, err
+	}
+
+	ops := Diff(from, to)
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	if name == "" {
+		if len(migrations) == 0 {
+			name = "initial"
+		} else {
+			name = "auto_" + time.Now().Format("20060102_1504")
+		}
+	}
+
+	migration := &Migration{
+		Name:       fmt.Sprintf("%04d_%s", len(migrations)+1, name),
+		Operations: ops,
+	}
+	if !migrationNamePattern.MatchString(migration.Name) {
+		return nil, fmt.Errorf("invalid migration name %q", name)
+	}
+
+	data, err := json.MarshalIndent(migration, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migration: %w", err)
+	}
+
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(m.Dir, migration.Name+".json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write migration: %w", err)
+	}
+
+	return migration, nil
+}
+
+// Applied returns the names of the migrations recorded as applied
+func (m *Migrator) Applied(ctx context.Context) (map[string]bool, error) {
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.ORM.DB().QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s", HistoryTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// Migrate applies all pending migrations in order and returns their names
+func (m *Migrator) Migrate(ctx context.Context) ([]string, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	state := make(State)
+	for _, migration := range migrations {
+		stmts, next, err := migration.forwards(m.ORM, state)
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+
+		if !applied[migration.Name] {
+			err := m.run(ctx, migration.Name, stmts, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx,
+					fmt.Sprintf("INSERT INTO %s (name, applied_at) VALUES (%s, %s)",
+						HistoryTable, m.placeholder(1), m.placeholder(2)),
+					migration.Name, time.Now().UTC())
+				return err
+			})
+			if err != nil {
+				return done, err
+			}
+			done = append(done, migration.Name)
+		}
+
+		state = next
+	}
+
+	return done, nil
+}
+
+// Rollback reverts the given number of most recently applied migrations and returns their names
+func (m *Migrator) Rollback(ctx context.Context, steps int) ([]string, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the state before each migration so it can be reversed
+	states := make([]State, len(migrations))
+	state := make(State)
+	for i, migration := range migrations {
+		states[i] = state
+		_, state, err = migration.forwards(m.ORM, state)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+	}
+
+	var done []string
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if !applied[migration.Name] {
+			continue
+		}
+
+		stmts, err := migration.backwards(m.ORM, states[i])
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+
+		err = m.run(ctx, migration.Name, stmts, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx,
+				fmt.Sprintf("DELETE FROM %s WHERE name = %s", HistoryTable, m.placeholder(1)),
+				migration.Name)
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, migration.Name)
+	}
+
+	return done, nil
+}
+
+// SQL returns the statements a migration runs when applied
+func (m *Migrator) SQL(name string) ([]string, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(State)
+	for _, migration := range migrations {
+		stmts, next, err := migration.forwards(m.ORM, state)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", migration.Name, err)
+		}
+		if migration.Name == name {
+			return stmts, nil
+		}
+		state = next
+	}
+
+	return nil, fmt.Errorf("migration %s not found", name)
+}
+
+// RunCommand runs a management command: makemigrations [name], migrate,
+// rollback [steps], showmigrations, sqlmigrate <name> or
+// inspectdb [package [tables...]]
+func (m *Migrator) RunCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	switch args[0] {
+	case "makemigrations":
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		migration, err := m.MakeMigrations(name)
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			fmt.Println("No changes detected")
+			return nil
+		}
+		fmt.Printf("Created migration %s:\n", migration.Name)
+		for _, op := range migration.Operations {
+			fmt.Printf("  - %s\n", op)
+		}
+	case "migrate":
+		done, err := m.Migrate(ctx)
+		for _, name := range done {
+			fmt.Printf("Applied %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("No migrations to apply")
+		}
+	case "rollback":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		done, err := m.Rollback(ctx, steps)
+		for _, name := range done {
+			fmt.Printf("Reverted %s\n", name)
+		}
+		if err != nil {
+			return err
+		}
+	case "showmigrations":
+		migrations, err := m.Load()
+		if err != nil {
+			return err
+		}
+		applied, err := m.Applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			mark := " "
+			if applied[migration.Name] {
+				mark = "X"
+			}
+			fmt.Printf("[%s] %s\n", mark, migration.Name)
+		}
+	case "sqlmigrate":
+		if len(args) < 2 {
+			return fmt.Errorf("sqlmigrate requires a migration name")
+		}
+		stmts, err := m.SQL(args[1])
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			fmt.Println(stmt + ";")
+		}
+	case "inspectdb":
+		packageName := "models"
+		if len(args) > 1 {
+			packageName = args[1]
+		}
+		src, err := m.InspectDB(ctx, packageName, args[2:]...)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(src))
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return nil
+}
+
+// InspectDB generates Go models for the given tables, or for every table
+// except the migration history when none are given
+func (m *Migrator) InspectDB(ctx context.Context, packageName string, tables ...string) ([]byte, error) {
+	if len(tables) == 0 {
+		all, err := m.ORM.Tables(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range all {
+			if table != HistoryTable {
+				tables = append(tables, table)
+			}
+		}
+		if len(tables) == 0 {
+			return nil, fmt.Errorf("no tables found")
+		}
+	}
+
+	infos, err := m.ORM.Inspect(ctx, tables...)
+	if err != nil {
+		return nil, err
+	}
+	return orm.GenerateModels(packageName, infos)
+}
+
+// forwards returns the statements applying the migration and the resulting state
+func (mig *Migration) forwards(o *orm.ORM, state State) ([]string, State, error) {
+	var stmts []string
+	for _, op := range mig.Operations {
+		next := state.Clone()
+		if err := op.Apply(next); err != nil {
+			return nil, nil, err
+		}
+		sql, err := op.SQL(o, state, next)
+		if err != nil {
+			return nil, nil, err
+		}
+		stmts = append(stmts, sql...)
+		state = next
+	}
+	return stmts, state, nil
+}
+
+// backwards returns the statements reverting the migration, given the state before it
+func (mig *Migration) backwards(o *orm.ORM, state State) ([]string, error) {
+	// Record the state after each operation
+	states := []State{state}
+	for _, op := range mig.Operations {
+		next := state.Clone()
+		if err := op.Apply(next); err != nil {
+			return nil, err
+		}
+		states = append(states, next)
+		state = next
+	}
+
+	var stmts []string
+	for i := len(mig.Operations) - 1; i >= 0; i-- {
+		rev, err := mig.Operations[i].Reverse()
+		if err != nil {
+			return nil, err
+		}
+		sql, err := rev.SQL(o, states[i+1], states[i])
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, sql...)
+	}
+	return stmts, nil
+}
+
+// replay returns the state produced by applying the given migrations
+func replay(migrations []*Migration) (State, error) {
+	state := make(State)
+	for _, migration := range migrations {
+		for _, op := range migration.Operations {
+			if err := op.Apply(state); err != nil {
+				return nil, fmt.Errorf("migration %s: %w", migration.Name, err)
+			}
+		}
+	}
+	return state, nil
+}
+
+// ensureHistoryTable creates the migration history table if it does not exist
+func (m *Migrator) ensureHistoryTable(ctx context.Context) error {
+	_, err := m.ORM.DB().ExecContext(ctx, m.ORM.SchemaEditor().HistoryTable(HistoryTable))
+	return err
+}
+
+// run executes the statements of a migration and records it within a single
+// transaction. MySQL commits DDL implicitly, so a failed migration may be
+// partially applied there.
+func (m *Migrator) run(ctx context.Context, name string, stmts []string, record func(tx *sql.Tx) error) error {
+	conn, err := m.ORM.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Table rebuilds require foreign key enforcement to be off, which may only
+	// be changed outside a transaction
+	rebuilder, rebuilds := m.ORM.TableRebuilder()
+	if rebuilds {
+		enable, err := rebuilder.DisableForeignKeys(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer enable()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed on %q: %w", name, stmt, err)
+		}
+	}
+
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+
+	if rebuilds {
+		if err := rebuilder.CheckForeignKeys(ctx, tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed the foreign key check: %w", name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// placeholder returns the bind parameter placeholder for the n-th argument
+func (m *Migrator) placeholder(n int) string {
+	return m.ORM.Dialect().Placeholder(n)
+}
@@ -0,0 +1,251 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestORM returns an ORM on a new SQLite database
+func openTestORM(t *testing.T) *orm.ORM {
+	t.Helper()
+	o, err := orm.New(orm.Config{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+// tables returns the names of the tables in the test database, other than
+// the migration history
+func tables(t *testing.T, o *orm.ORM) []string {
+	t.Helper()
+	rows, err := o.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name != ? ORDER BY name", HistoryTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, row := range rows {
+		names = append(names, row["name"].(string))
+	}
+	return names
+}
+
+// columns returns the columns of a table in the test database
+func columns(t *testing.T, o *orm.ORM, table string) []string {
+	t.Helper()
+	rows, err := o.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, row := range rows {
+		names = append(names, row["name"].(string))
+	}
+	return names
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	o := openTestORM(t)
+	m := New(o, t.TempDir())
+
+	users := models.NewModel("users")
+	users.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	users.AddField("name", reflect.TypeOf(""), models.WithNotNull())
+	if err := o.RegisterModel(users); err != nil {
+		t.Fatal(err)
+	}
+
+	initial, err := m.MakeMigrations("")
+	if err != nil {
+		t.Fatalf("MakeMigrations() error = %v", err)
+	}
+	if initial == nil || initial.Name != "0001_initial" {
+		t.Fatalf("MakeMigrations() = %+v, want 0001_initial", initial)
+	}
+	if migration, err := m.MakeMigrations(""); err != nil || migration != nil {
+		t.Fatalf("MakeMigrations() without changes = %+v, %v", migration, err)
+	}
+
+	done, err := m.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if want := []string{"0001_initial"}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Migrate() = %q, want %q", done, want)
+	}
+	if _, err := o.Create("users", map[string]interface{}{"name": "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A unique column and a new table referencing users; SQLite rebuilds the
+	// users table and keeps its rows
+	users.AddField("email", reflect.TypeOf(""), models.WithUnique())
+	posts := models.NewModel("posts")
+	posts.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	posts.AddField("user_id", reflect.TypeOf(0), models.WithNotNull(), models.WithForeignKey("users", "id", "CASCADE", ""))
+	if err := o.RegisterModel(posts); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := m.MakeMigrations("profile")
+	if err != nil {
+		t.Fatalf("MakeMigrations() error = %v", err)
+	}
+	if profile == nil || profile.Name != "0002_profile" {
+		t.Fatalf("MakeMigrations() = %+v, want 0002_profile", profile)
+	}
+	if _, err := os.Stat(filepath.Join(m.Dir, "0002_profile.json")); err != nil {
+		t.Errorf("migration file not written: %v", err)
+	}
+
+	stmts, err := m.SQL("0002_profile")
+	if err != nil || len(stmts) == 0 {
+		t.Fatalf("SQL() = %q, %v", stmts, err)
+	}
+
+	done, err = m.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if want := []string{"0002_profile"}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Migrate() = %q, want %q", done, want)
+	}
+	if got, want := tables(t, o), []string{"posts", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tables = %q, want %q", got, want)
+	}
+	if got, want := columns(t, o, "users"), []string{"id", "email", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users columns = %q, want %q", got, want)
+	}
+	user, err := o.Get("users", 1)
+	if err != nil || user["name"] != "Ann" {
+		t.Fatalf("Get() after the rebuild = %v, %v", user, err)
+	}
+	if _, err := o.Create("posts", map[string]interface{}{"user_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("posts", map[string]interface{}{"user_id": 2}); err == nil {
+		t.Error("foreign key not enforced after the migration")
+	}
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"0001_initial": true, "0002_profile": true}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("Applied() = %v, want %v", applied, want)
+	}
+
+	done, err = m.Rollback(ctx, 1)
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if want := []string{"0002_profile"}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Rollback() = %q, want %q", done, want)
+	}
+	if got, want := columns(t, o, "users"), []string{"id", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users columns after the rollback = %q, want %q", got, want)
+	}
+	if user, err := o.Get("users", 1); err != nil || user["name"] != "Ann" {
+		t.Errorf("Get() after the rollback = %v, %v", user, err)
+	}
+
+	done, err = m.Rollback(ctx, 5)
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if want := []string{"0001_initial"}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Rollback() = %q, want %q", done, want)
+	}
+	if got := tables(t, o); len(got) != 0 {
+		t.Errorf("tables after rolling back everything = %q", got)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	ctx := context.Background()
+	o := openTestORM(t)
+	m := New(o, t.TempDir())
+
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(m.Dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("0001_initial.json", `{"operations": [
+		{"type": "create_table", "table": "users", "columns": [{"name": "id", "type": "int", "primary_key": true}]}
+	]}`)
+	if _, err := m.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if _, err := o.DB().Exec("INSERT INTO users (id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// SQLite cannot add a NOT NULL column without a default to a table with
+	// rows, so the table created first is rolled back as well
+	write("0002_name.json", `{"operations": [
+		{"type": "create_table", "table": "tags", "columns": [{"name": "id", "type": "int", "primary_key": true}]},
+		{"type": "add_column", "table": "users", "column": {"name": "name", "type": "string", "not_null": true}}
+	]}`)
+	if done, err := m.Migrate(ctx); err == nil {
+		t.Fatalf("Migrate() = %q, want an error", done)
+	}
+	if got, want := tables(t, o), []string{"users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tables = %q, want %q", got, want)
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied["0002_name"] {
+		t.Error("failed migration recorded as applied")
+	}
+}
+
+func TestLoadInvalidName(t *testing.T) {
+	m := New(nil, t.TempDir())
+	if err := os.WriteFile(filepath.Join(m.Dir, "initial.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Load(); err == nil {
+		t.Error("Load() accepted a migration without a number")
+	}
+}
+
+func TestInspectDB(t *testing.T) {
+	o := openTestORM(t)
+	m := New(o, t.TempDir())
+	ctx := context.Background()
+
+	if _, err := m.InspectDB(ctx, "models"); err == nil {
+		t.Error("InspectDB() accepted an empty database")
+	}
+
+	if err := m.ensureHistoryTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.DB().Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	src, err := m.InspectDB(ctx, "models")
+	if err != nil {
+		t.Fatalf("InspectDB() error = %v", err)
+	}
+	if !strings.Contains(string(src), "type User struct") {
+		t.Errorf("InspectDB() did not generate the users model:\n%s", src)
+	}
+	if strings.Contains(string(src), HistoryTable) {
+		t.Errorf("InspectDB() generated the migration history model:\n%s", src)
+	}
+}
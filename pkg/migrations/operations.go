@@ -0,0 +1,350 @@
+package migrations
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+// OperationType identifies the kind of schema change performed by an operation
+type OperationType string
+
+// Supported operation types
+const (
+	CreateTable    OperationType = "create_table"
+	DropTable      OperationType = "drop_table"
+	AddColumn      OperationType = "add_column"
+	DropColumn     OperationType = "drop_column"
+	AlterColumn    OperationType = "alter_column"
+	AddForeignKey  OperationType = "add_foreign_key"
+	DropForeignKey OperationType = "drop_foreign_key"
+	AddIndex       OperationType = "add_index"
+	DropIndex      OperationType = "drop_index"
+	AddCheck       OperationType = "add_check"
+	DropCheck      OperationType = "drop_check"
+)
+
+// Operation represents a single schema change.
+// Every operation stores enough information to be reversed.
+type Operation struct {
+	Type     OperationType `json:"type"`
+	Table    string        `json:"table"`
+	Columns  []Column      `json:"columns,omitempty"`
+	Column   *Column       `json:"column,omitempty"`
+	Previous *Column       `json:"previous,omitempty"`
+	Index    *Index        `json:"index,omitempty"`
+	Checks   []Check       `json:"checks,omitempty"`
+	Check    *Check        `json:"check,omitempty"`
+}
+
+// Reverse returns the operation that undoes this one
+func (op Operation) Reverse() (Operation, error) {
+	rev := op
+	switch op.Type {
+	case CreateTable:
+		rev.Type = DropTable
+	case DropTable:
+		rev.Type = CreateTable
+	case AddColumn:
+		rev.Type = DropColumn
+	case DropColumn:
+		rev.Type = AddColumn
+	case AlterColumn:
+		rev.Column, rev.Previous = op.Previous, op.Column
+	case AddForeignKey:
+		rev.Type = DropForeignKey
+	case DropForeignKey:
+		rev.Type = AddForeignKey
+	case AddIndex:
+		rev.Type = DropIndex
+	case DropIndex:
+		rev.Type = AddIndex
+	case AddCheck:
+		rev.Type = DropCheck
+	case DropCheck:
+		rev.Type = AddCheck
+	default:
+		return Operation{}, fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	return rev, nil
+}
+
+// Apply applies the operation to the given schema state
+func (op Operation) Apply(state State) error {
+	if op.Type == CreateTable {
+		if _, exists := state[op.Table]; exists {
+			return fmt.Errorf("table %s already exists", op.Table)
+		}
+		table := &Table{Name: op.Table, Columns: append([]Column(nil), op.Columns...), Checks: op.Checks}
+		state[op.Table] = table.clone()
+		return nil
+	}
+
+	table, ok := state[op.Table]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", op.Table)
+	}
+
+	switch op.Type {
+	case DropTable:
+		delete(state, op.Table)
+	case AddColumn:
+		if op.Column == nil {
+			return fmt.Errorf("%s on %s requires a column", op.Type, op.Table)
+		}
+		if _, _, exists := table.column(op.Column.Name); exists {
+			return fmt.Errorf("column %s.%s already exists", op.Table, op.Column.Name)
+		}
+		table.Columns = append(table.Columns, *op.Column)
+		sortColumns(table.Columns)
+	case DropColumn, AlterColumn, AddForeignKey, DropForeignKey:
+		if op.Column == nil {
+			return fmt.Errorf("%s on %s requires a column", op.Type, op.Table)
+		}
+		_, i, exists := table.column(op.Column.Name)
+		if !exists {
+			return fmt.Errorf("column %s.%s does not exist", op.Table, op.Column.Name)
+		}
+		switch op.Type {
+		case DropColumn:
+			table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+		case AlterColumn, AddForeignKey:
+			table.Columns[i] = *op.Column
+		case DropForeignKey:
+			table.Columns[i].ForeignKey = nil
+		}
+	case AddIndex:
+		if op.Index == nil {
+			return fmt.Errorf("%s on %s requires an index", op.Type, op.Table)
+		}
+		if table.index(op.Index.Name) >= 0 {
+			return fmt.Errorf("index %s already exists", op.Index.Name)
+		}
+		table.Indexes = append(table.Indexes, *op.Index)
+	case DropIndex:
+		if op.Index == nil {
+			return fmt.Errorf("%s on %s requires an index", op.Type, op.Table)
+		}
+		i := table.index(op.Index.Name)
+		if i < 0 {
+			return fmt.Errorf("index %s does not exist", op.Index.Name)
+		}
+		table.Indexes = append(table.Indexes[:i], table.Indexes[i+1:]...)
+	case AddCheck:
+		if op.Check == nil {
+			return fmt.Errorf("%s on %s requires a check", op.Type, op.Table)
+		}
+		if table.check(op.Check.Name) >= 0 {
+			return fmt.Errorf("check %s already exists", op.Check.Name)
+		}
+		table.Checks = append(table.Checks, *op.Check)
+	case DropCheck:
+		if op.Check == nil {
+			return fmt.Errorf("%s on %s requires a check", op.Type, op.Table)
+		}
+		i := table.check(op.Check.Name)
+		if i < 0 {
+			return fmt.Errorf("check %s does not exist", op.Check.Name)
+		}
+		table.Checks = append(table.Checks[:i], table.Checks[i+1:]...)
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	return nil
+}
+
+// SQL returns the statements that perform the operation, given the schema
+// state before and after it has been applied. Changes the dialect cannot make
+// with ALTER TABLE rebuild the table, see orm.TableRebuilder.
+func (op Operation) SQL(o *orm.ORM, before, after State) ([]string, error) {
+	editor := o.SchemaEditor()
+	rebuilder, rebuilds := o.TableRebuilder()
+
+	switch op.Type {
+	case CreateTable:
+		stmts := []string{createTableSQL(o, after[op.Table], op.Table)}
+		return stmts, nil
+	case DropTable:
+		return []string{fmt.Sprintf("DROP TABLE %s", o.Quote(op.Table))}, nil
+	case AddColumn:
+		column := *op.Column
+		if rebuilds && !rebuilder.CanAddColumn(column.Field()) {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		fk := column.ForeignKey
+		column.ForeignKey = nil
+		stmts := []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", o.Quote(op.Table), o.ColumnDefinition(column.Name, column.Field()))}
+		if fk != nil {
+			stmts = append(stmts, addForeignKeySQL(o, op.Table, op.Column))
+		}
+		return stmts, nil
+	case DropColumn:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		var foreignKey string
+		if op.Column.ForeignKey != nil {
+			foreignKey = foreignKeyName(op.Table, op.Column.Name)
+		}
+		return editor.DropColumn(op.Table, op.Column.Name, foreignKey), nil
+	case AlterColumn:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		return alterColumnSQL(o, op.Table, *op.Previous, *op.Column)
+	case AddForeignKey:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		return []string{addForeignKeySQL(o, op.Table, op.Column)}, nil
+	case DropForeignKey:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		return []string{editor.DropForeignKey(op.Table, foreignKeyName(op.Table, op.Column.Name))}, nil
+	case AddIndex:
+		stmt, err := createIndexSQL(o, op.Table, *op.Index)
+		if err != nil {
+			return nil, err
+		}
+		return []string{stmt}, nil
+	case DropIndex:
+		return []string{editor.DropIndex(op.Table, op.Index.Name)}, nil
+	case AddCheck:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s ADD %s", o.Quote(op.Table), o.CheckDefinition(op.Check.model()))}, nil
+	case DropCheck:
+		if rebuilds {
+			return rebuildTableSQL(o, before[op.Table], after[op.Table])
+		}
+		return []string{editor.DropCheck(op.Table, op.Check.Name)}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+// String returns a short human-readable description of the operation
+func (op Operation) String() string {
+	switch op.Type {
+	case CreateTable, DropTable:
+		return fmt.Sprintf("%s %s", op.Type, op.Table)
+	case AddIndex, DropIndex:
+		return fmt.Sprintf("%s %s on %s", op.Type, op.Index.Name, op.Table)
+	case AddCheck, DropCheck:
+		return fmt.Sprintf("%s %s on %s", op.Type, op.Check.Name, op.Table)
+	default:
+		return fmt.Sprintf("%s %s.%s", op.Type, op.Table, op.Column.Name)
+	}
+}
+
+// foreignKeyName returns the constraint name used for the foreign key on a column
+func foreignKeyName(table, column string) string {
+	return fmt.Sprintf("fk_%s_%s", table, column)
+}
+
+// createTableSQL renders CREATE TABLE for the table state under the given name
+func createTableSQL(o *orm.ORM, table *Table, name string) string {
+	var definitions []string
+	var primaryKeys []string
+	var foreignKeys []string
+
+	for _, column := range table.Columns {
+		definitions = append(definitions, o.ColumnDefinition(column.Name, column.Field()))
+		if column.PrimaryKey {
+			primaryKeys = append(primaryKeys, o.Quote(column.Name))
+		}
+		if column.ForeignKey != nil {
+			foreignKeys = append(foreignKeys, fmt.Sprintf("CONSTRAINT %s %s",
+				o.Quote(foreignKeyName(table.Name, column.Name)), o.ForeignKeyDefinition(column.Name, column.ForeignKey)))
+		}
+	}
+
+	if len(primaryKeys) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
+	}
+
+	definitions = append(definitions, foreignKeys...)
+
+	for _, check := range table.Checks {
+		definitions = append(definitions, o.CheckDefinition(check.model()))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s)", o.Quote(name), strings.Join(definitions, ", "))
+}
+
+// createIndexSQL renders CREATE INDEX for the given index
+func createIndexSQL(o *orm.ORM, table string, index Index) (string, error) {
+	return o.IndexDefinition(table, models.Index{
+		Name:    index.Name,
+		Columns: index.Columns,
+		Unique:  index.Unique,
+		Where:   index.Where,
+	})
+}
+
+// addForeignKeySQL renders ALTER TABLE ... ADD CONSTRAINT for the column's foreign key
+func addForeignKeySQL(o *orm.ORM, table string, column *Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s",
+		o.Quote(table), o.Quote(foreignKeyName(table, column.Name)), o.ForeignKeyDefinition(column.Name, column.ForeignKey))
+}
+
+// alterColumnSQL renders the statements changing a column with ALTER TABLE
+func alterColumnSQL(o *orm.ORM, table string, previous, column Column) ([]string, error) {
+	if previous.PrimaryKey != column.PrimaryKey {
+		return nil, fmt.Errorf("changing the primary key of %s.%s is not supported", table, column.Name)
+	}
+
+	editor := o.SchemaEditor()
+	foreignKeyChanged := !reflect.DeepEqual(previous.ForeignKey, column.ForeignKey)
+	var stmts []string
+
+	if foreignKeyChanged && previous.ForeignKey != nil {
+		stmts = append(stmts, editor.DropForeignKey(table, foreignKeyName(table, column.Name)))
+	}
+
+	stmts = append(stmts, editor.AlterColumn(table, column.Name, previous.Field(), column.Field())...)
+
+	if foreignKeyChanged && column.ForeignKey != nil {
+		stmts = append(stmts, addForeignKeySQL(o, table, &column))
+	}
+
+	return stmts, nil
+}
+
+// rebuildTableSQL renders the table rebuild used for changes the dialect cannot
+// make with ALTER TABLE. Foreign key enforcement must be disabled while these
+// statements run.
+func rebuildTableSQL(o *orm.ORM, before, after *Table) ([]string, error) {
+	tmp := after.Name + "__framego_new"
+
+	var common []string
+	for _, column := range after.Columns {
+		if _, _, ok := before.column(column.Name); ok {
+			common = append(common, o.Quote(column.Name))
+		}
+	}
+
+	stmts := []string{
+		createTableSQL(o, after, tmp),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+			o.Quote(tmp), strings.Join(common, ", "), strings.Join(common, ", "), o.Quote(before.Name)),
+		fmt.Sprintf("DROP TABLE %s", o.Quote(before.Name)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", o.Quote(tmp), o.Quote(after.Name)),
+	}
+
+	// Indexes are dropped together with the old table
+	for _, index := range after.Indexes {
+		stmt, err := createIndexSQL(o, after.Name, index)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts, nil
+}
@@ -0,0 +1,302 @@
+package migrations
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+func TestOperationReverse(t *testing.T) {
+	column := &Column{Name: "email", Type: "string"}
+	previous := &Column{Name: "email", Type: "string", NotNull: true}
+	index := &Index{Name: "users_email", Columns: []string{"email"}}
+	check := &Check{Name: "users_email", Expression: "email != ''"}
+
+	tests := []struct {
+		op   Operation
+		want Operation
+	}{
+		{op: Operation{Type: CreateTable, Table: "users"}, want: Operation{Type: DropTable, Table: "users"}},
+		{op: Operation{Type: DropTable, Table: "users"}, want: Operation{Type: CreateTable, Table: "users"}},
+		{op: Operation{Type: AddColumn, Table: "users", Column: column}, want: Operation{Type: DropColumn, Table: "users", Column: column}},
+		{op: Operation{Type: DropColumn, Table: "users", Column: column}, want: Operation{Type: AddColumn, Table: "users", Column: column}},
+		{
+			op:   Operation{Type: AlterColumn, Table: "users", Column: column, Previous: previous},
+			want: Operation{Type: AlterColumn, Table: "users", Column: previous, Previous: column},
+		},
+		{op: Operation{Type: AddForeignKey, Table: "users", Column: column}, want: Operation{Type: DropForeignKey, Table: "users", Column: column}},
+		{op: Operation{Type: DropForeignKey, Table: "users", Column: column}, want: Operation{Type: AddForeignKey, Table: "users", Column: column}},
+		{op: Operation{Type: AddIndex, Table: "users", Index: index}, want: Operation{Type: DropIndex, Table: "users", Index: index}},
+		{op: Operation{Type: DropIndex, Table: "users", Index: index}, want: Operation{Type: AddIndex, Table: "users", Index: index}},
+		{op: Operation{Type: AddCheck, Table: "users", Check: check}, want: Operation{Type: DropCheck, Table: "users", Check: check}},
+		{op: Operation{Type: DropCheck, Table: "users", Check: check}, want: Operation{Type: AddCheck, Table: "users", Check: check}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.op.Type), func(t *testing.T) {
+			got, err := tt.op.Reverse()
+			if err != nil {
+				t.Fatalf("Reverse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reverse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Operation{Type: "rename_table"}).Reverse(); err == nil {
+		t.Error("Reverse() accepted an unknown operation")
+	}
+}
+
+func TestOperationApplyInvalid(t *testing.T) {
+	state := State{"users": {Name: "users", Columns: []Column{{Name: "id", Type: "int", PrimaryKey: true}}}}
+
+	tests := []struct {
+		name string
+		op   Operation
+	}{
+		{name: "existing table", op: Operation{Type: CreateTable, Table: "users"}},
+		{name: "missing table", op: Operation{Type: DropTable, Table: "posts"}},
+		{name: "existing column", op: Operation{Type: AddColumn, Table: "users", Column: &Column{Name: "id", Type: "int"}}},
+		{name: "missing column", op: Operation{Type: DropColumn, Table: "users", Column: &Column{Name: "email", Type: "string"}}},
+		{name: "without column", op: Operation{Type: AlterColumn, Table: "users"}},
+		{name: "missing index", op: Operation{Type: DropIndex, Table: "users", Index: &Index{Name: "users_email"}}},
+		{name: "check without check", op: Operation{Type: AddCheck, Table: "users"}},
+		{name: "missing check", op: Operation{Type: DropCheck, Table: "users", Check: &Check{Name: "users_id"}}},
+		{name: "unknown type", op: Operation{Type: "rename_table", Table: "users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op.Apply(state.Clone()); err == nil {
+				t.Errorf("Apply() accepted %+v", tt.op)
+			}
+		})
+	}
+}
+
+func TestOperationSQL(t *testing.T) {
+	o := openTestORM(t)
+	id := Column{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true}
+	name := Column{Name: "name", Type: "string", NotNull: true, MaxLength: 50}
+	userID := Column{Name: "user_id", Type: "int", ForeignKey: &models.ForeignKey{Model: "users", Field: "id", OnDelete: "CASCADE"}}
+	posts := State{"posts": {Name: "posts", Columns: []Column{id, name}}}
+
+	tests := []struct {
+		name   string
+		before State
+		op     Operation
+		want   []string
+	}{
+		{
+			name:   "create table",
+			before: State{},
+			op:     Operation{Type: CreateTable, Table: "posts", Columns: []Column{id, name, userID}},
+			want: []string{`CREATE TABLE "posts" ("id" INTEGER, "name" VARCHAR(50) NOT NULL, "user_id" INTEGER, PRIMARY KEY ("id"), ` +
+				`CONSTRAINT "fk_posts_user_id" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE)`},
+		},
+		{
+			name:   "drop table",
+			before: posts,
+			op:     Operation{Type: DropTable, Table: "posts", Columns: []Column{id, name}},
+			want:   []string{`DROP TABLE "posts"`},
+		},
+		{
+			name:   "add column",
+			before: posts,
+			op:     Operation{Type: AddColumn, Table: "posts", Column: &Column{Name: "views", Type: "int", NotNull: true, Default: float64(0)}},
+			want:   []string{`ALTER TABLE "posts" ADD COLUMN "views" INTEGER NOT NULL DEFAULT 0`},
+		},
+		{
+			name:   "add foreign key rebuilds the table",
+			before: State{"posts": {Name: "posts", Columns: []Column{id, name, {Name: "user_id", Type: "int"}}}},
+			op:     Operation{Type: AddForeignKey, Table: "posts", Column: &userID},
+			want: []string{
+				`CREATE TABLE "posts__framego_new" ("id" INTEGER, "name" VARCHAR(50) NOT NULL, "user_id" INTEGER, PRIMARY KEY ("id"), ` +
+					`CONSTRAINT "fk_posts_user_id" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE)`,
+				`INSERT INTO "posts__framego_new" ("id", "name", "user_id") SELECT "id", "name", "user_id" FROM "posts"`,
+				`DROP TABLE "posts"`,
+				`ALTER TABLE "posts__framego_new" RENAME TO "posts"`,
+			},
+		},
+		{
+			name:   "drop column rebuilds the table",
+			before: posts,
+			op:     Operation{Type: DropColumn, Table: "posts", Column: &name},
+			want: []string{
+				`CREATE TABLE "posts__framego_new" ("id" INTEGER, PRIMARY KEY ("id"))`,
+				`INSERT INTO "posts__framego_new" ("id") SELECT "id" FROM "posts"`,
+				`DROP TABLE "posts"`,
+				`ALTER TABLE "posts__framego_new" RENAME TO "posts"`,
+			},
+		},
+		{
+			name:   "add index",
+			before: posts,
+			op:     Operation{Type: AddIndex, Table: "posts", Index: &Index{Name: "posts_name", Columns: []string{"name", "id"}, Unique: true}},
+			want:   []string{`CREATE UNIQUE INDEX "posts_name" ON "posts" ("name", "id")`},
+		},
+		{
+			name:   "add check rebuilds the table and its indexes",
+			before: State{"posts": {Name: "posts", Columns: []Column{id, name}, Indexes: []Index{{Name: "posts_name", Columns: []string{"name"}, Where: "name != ''"}}}},
+			op:     Operation{Type: AddCheck, Table: "posts", Check: &Check{Name: "posts_name_length", Expression: "length(name) > 1"}},
+			want: []string{
+				`CREATE TABLE "posts__framego_new" ("id" INTEGER, "name" VARCHAR(50) NOT NULL, PRIMARY KEY ("id"), ` +
+					`CONSTRAINT "posts_name_length" CHECK (length(name) > 1))`,
+				`INSERT INTO "posts__framego_new" ("id", "name") SELECT "id", "name" FROM "posts"`,
+				`DROP TABLE "posts"`,
+				`ALTER TABLE "posts__framego_new" RENAME TO "posts"`,
+				`CREATE INDEX "posts_name" ON "posts" ("name") WHERE name != ''`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.before.Clone()
+			if err := tt.op.Apply(after); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			got, err := tt.op.SQL(o, tt.before, after)
+			if err != nil {
+				t.Fatalf("SQL() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQL() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// openDialectORM returns an ORM that generates SQL for the given dialect on
+// top of a SQLite connection, for comparing statements without the server
+func openDialectORM(t *testing.T, dialect string) *orm.ORM {
+	t.Helper()
+	o, err := orm.New(orm.Config{Driver: "sqlite3", Dialect: dialect, DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+func TestOperationSQLDialects(t *testing.T) {
+	id := Column{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true}
+	name := Column{Name: "name", Type: "string", MaxLength: 50}
+	userID := Column{Name: "user_id", Type: "int", ForeignKey: &models.ForeignKey{Model: "users", Field: "id", OnDelete: "CASCADE"}}
+	posts := State{"posts": {Name: "posts", Columns: []Column{id, name, userID}}}
+	renamed := Column{Name: "name", Type: "int64", NotNull: true, Unique: true, Default: float64(1)}
+	index := &Index{Name: "posts_name", Columns: []string{"name"}}
+	check := &Check{Name: "posts_name_length", Expression: "length(name) > 1"}
+
+	tests := []struct {
+		name     string
+		op       Operation
+		postgres []string
+		mysql    []string
+	}{
+		{
+			name:     "create table",
+			op:       Operation{Type: CreateTable, Table: "tags", Columns: []Column{id}},
+			postgres: []string{`CREATE TABLE "tags" ("id" SERIAL, PRIMARY KEY ("id"))`},
+			mysql:    []string{"CREATE TABLE `tags` (`id` INTEGER AUTO_INCREMENT, PRIMARY KEY (`id`))"},
+		},
+		{
+			name: "add foreign key column",
+			op:   Operation{Type: AddColumn, Table: "posts", Column: &Column{Name: "editor_id", Type: "int", ForeignKey: &models.ForeignKey{Model: "users", Field: "id"}}},
+			postgres: []string{
+				`ALTER TABLE "posts" ADD COLUMN "editor_id" INTEGER`,
+				`ALTER TABLE "posts" ADD CONSTRAINT "fk_posts_editor_id" FOREIGN KEY ("editor_id") REFERENCES "users"("id")`,
+			},
+			mysql: []string{
+				"ALTER TABLE `posts` ADD COLUMN `editor_id` INTEGER",
+				"ALTER TABLE `posts` ADD CONSTRAINT `fk_posts_editor_id` FOREIGN KEY (`editor_id`) REFERENCES `users`(`id`)",
+			},
+		},
+		{
+			name:     "drop foreign key column",
+			op:       Operation{Type: DropColumn, Table: "posts", Column: &userID},
+			postgres: []string{`ALTER TABLE "posts" DROP COLUMN "user_id"`},
+			mysql: []string{
+				"ALTER TABLE `posts` DROP FOREIGN KEY `fk_posts_user_id`",
+				"ALTER TABLE `posts` DROP COLUMN `user_id`",
+			},
+		},
+		{
+			name: "alter column",
+			op:   Operation{Type: AlterColumn, Table: "posts", Column: &renamed, Previous: &name},
+			postgres: []string{
+				`ALTER TABLE "posts" ALTER COLUMN "name" TYPE BIGINT USING "name"::BIGINT`,
+				`ALTER TABLE "posts" ALTER COLUMN "name" SET NOT NULL`,
+				`ALTER TABLE "posts" ALTER COLUMN "name" SET DEFAULT 1`,
+				`ALTER TABLE "posts" ADD CONSTRAINT "posts_name_key" UNIQUE ("name")`,
+			},
+			mysql: []string{
+				"ALTER TABLE `posts` MODIFY COLUMN `name` BIGINT NOT NULL DEFAULT 1",
+				"ALTER TABLE `posts` ADD UNIQUE INDEX `name` (`name`)",
+			},
+		},
+		{
+			name:     "drop foreign key",
+			op:       Operation{Type: DropForeignKey, Table: "posts", Column: &userID},
+			postgres: []string{`ALTER TABLE "posts" DROP CONSTRAINT "fk_posts_user_id"`},
+			mysql:    []string{"ALTER TABLE `posts` DROP FOREIGN KEY `fk_posts_user_id`"},
+		},
+		{
+			name:     "drop index",
+			op:       Operation{Type: DropIndex, Table: "posts", Index: index},
+			postgres: []string{`DROP INDEX "posts_name"`},
+			mysql:    []string{"DROP INDEX `posts_name` ON `posts`"},
+		},
+		{
+			name:     "add check",
+			op:       Operation{Type: AddCheck, Table: "posts", Check: check},
+			postgres: []string{`ALTER TABLE "posts" ADD CONSTRAINT "posts_name_length" CHECK (length(name) > 1)`},
+			mysql:    []string{"ALTER TABLE `posts` ADD CONSTRAINT `posts_name_length` CHECK (length(name) > 1)"},
+		},
+		{
+			name:     "drop check",
+			op:       Operation{Type: DropCheck, Table: "posts", Check: check},
+			postgres: []string{`ALTER TABLE "posts" DROP CONSTRAINT "posts_name_length"`},
+			mysql:    []string{"ALTER TABLE `posts` DROP CHECK `posts_name_length`"},
+		},
+	}
+
+	for _, dialect := range []string{"postgres", "mysql"} {
+		o := openDialectORM(t, dialect)
+		for _, tt := range tests {
+			t.Run(dialect+"/"+tt.name, func(t *testing.T) {
+				before := posts.Clone()
+				switch tt.op.Type {
+				case DropIndex:
+					before["posts"].Indexes = []Index{*index}
+				case DropCheck:
+					before["posts"].Checks = []Check{*check}
+				}
+				after := before.Clone()
+				if err := tt.op.Apply(after); err != nil {
+					t.Fatalf("Apply() error = %v", err)
+				}
+				got, err := tt.op.SQL(o, before, after)
+				if err != nil {
+					t.Fatalf("SQL() error = %v", err)
+				}
+				want := tt.postgres
+				if dialect == "mysql" {
+					want = tt.mysql
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("SQL() =\n%q\nwant\n%q", got, want)
+				}
+			})
+		}
+	}
+
+	if _, err := (Operation{Type: AlterColumn, Table: "posts", Column: &Column{Name: "id", Type: "int"}, Previous: &id}).SQL(openDialectORM(t, "postgres"), posts, posts); err == nil {
+		t.Error("SQL() accepted a primary key change")
+	}
+}
@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// CurrentTimestamp is the default value stored for time columns defaulting to the insertion time
+const CurrentTimestamp = "CURRENT_TIMESTAMP"
+
+// fieldTypes maps the type names used in migration files to Go types
+var fieldTypes = map[string]reflect.Type{
+	"bool":    reflect.TypeOf(false),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"string":  reflect.TypeOf(""),
+	"time":    reflect.TypeOf(time.Time{}),
+	"bytes":   reflect.TypeOf([]byte(nil)),
+}
+
+// Column is the serializable definition of a model field
+type Column struct {
+	Name          string             `json:"name"`
+	Type          string             `json:"type"`
+	PrimaryKey    bool               `json:"primary_key,omitempty"`
+	AutoIncrement bool               `json:"auto_increment,omitempty"`
+	Unique        bool               `json:"unique,omitempty"`
+	NotNull       bool               `json:"not_null,omitempty"`
+	Default       interface{}        `json:"default,omitempty"`
+	MaxLength     int                `json:"max_length,omitempty"`
+	ForeignKey    *models.ForeignKey `json:"foreign_key,omitempty"`
+}
+
+// Index is the serializable definition of a table index
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique,omitempty"`
+	Where   string   `json:"where,omitempty"`
+}
+
+// Check is the serializable definition of a CHECK constraint
+type Check struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// model converts the check to the model constraint rendered by the ORM
+func (c Check) model() models.Check {
+	return models.Check{Name: c.Name, Expression: c.Expression}
+}
+
+// Table is the schema of a single table
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+	Indexes []Index  `json:"indexes,omitempty"`
+	Checks  []Check  `json:"checks,omitempty"`
+}
+
+// State is the schema of every table, keyed by table name
+type State map[string]*Table
+
+// columnFromField converts a model field to a serializable column
+func columnFromField(name string, field models.Field) (Column, error) {
+	typeName := ""
+	for n, t := range fieldTypes {
+		if t == field.Type {
+			typeName = n
+			break
+		}
+	}
+	if typeName == "" {
+		return Column{}, fmt.Errorf("field %s has unsupported type %v", name, field.Type)
+	}
+
+	column := Column{
+		Name:          name,
+		Type:          typeName,
+		PrimaryKey:    field.PrimaryKey,
+		AutoIncrement: field.AutoIncrement,
+		Unique:        field.Unique,
+		NotNull:       field.NotNull,
+		MaxLength:     field.MaxLength,
+	}
+
+	if field.Default != nil {
+		if typeName == "time" {
+			column.Default = CurrentTimestamp
+		} else {
+			// Round-trip through JSON so defaults compare equal to the ones loaded from files
+			data, err := json.Marshal(field.Default)
+			if err != nil {
+				return Column{}, fmt.Errorf("field %s has unserializable default: %w", name, err)
+			}
+			if err := json.Unmarshal(data, &column.Default); err != nil {
+				return Column{}, err
+			}
+		}
+	}
+
+	if field.ForeignKey != nil {
+		fk := *field.ForeignKey
+		column.ForeignKey = &fk
+	}
+
+	return column, nil
+}
+
+// Field converts the column back to a model field
+func (c Column) Field() models.Field {
+	field := models.Field{
+		Name:          c.Name,
+		Type:          fieldTypes[c.Type],
+		PrimaryKey:    c.PrimaryKey,
+		AutoIncrement: c.AutoIncrement,
+		Unique:        c.Unique,
+		NotNull:       c.NotNull,
+		MaxLength:     c.MaxLength,
+	}
+
+	if field.Type == nil {
+		field.Type = fieldTypes["string"]
+	}
+
+	if c.Default != nil {
+		if c.Type == "time" {
+			field.Default = time.Time{}
+		} else {
+			field.Default = c.Default
+		}
+	}
+
+	if c.ForeignKey != nil {
+		fk := *c.ForeignKey
+		field.ForeignKey = &fk
+	}
+
+	return field
+}
+
+// StateFromModels builds the schema state of the given models
+func StateFromModels(registered map[string]models.ModelInterface) (State, error) {
+	state := make(State)
+	for tableName, model := range registered {
+		table := &Table{Name: tableName}
+		for name, field := range model.GetFields() {
+			column, err := columnFromField(name, field)
+			if err != nil {
+				return nil, fmt.Errorf("model %s: %w", tableName, err)
+			}
+			table.Columns = append(table.Columns, column)
+		}
+		sortColumns(table.Columns)
+
+		if provider, ok := model.(models.ConstraintProvider); ok {
+			for _, index := range provider.GetIndexes() {
+				table.Indexes = append(table.Indexes, Index{
+					Name:    index.Name,
+					Columns: append([]string(nil), index.Columns...),
+					Unique:  index.Unique,
+					Where:   index.Where,
+				})
+			}
+			for _, check := range provider.GetChecks() {
+				table.Checks = append(table.Checks, Check{Name: check.Name, Expression: check.Expression})
+			}
+			sort.Slice(table.Indexes, func(i, j int) bool { return table.Indexes[i].Name < table.Indexes[j].Name })
+			sort.Slice(table.Checks, func(i, j int) bool { return table.Checks[i].Name < table.Checks[j].Name })
+		}
+
+		state[tableName] = table
+	}
+	return state, nil
+}
+
+// sortColumns orders columns with primary keys first, then alphabetically
+func sortColumns(columns []Column) {
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].PrimaryKey != columns[j].PrimaryKey {
+			return columns[i].PrimaryKey
+		}
+		return columns[i].Name < columns[j].Name
+	})
+}
+
+// Clone returns a deep copy of the state
+func (s State) Clone() State {
+	clone := make(State, len(s))
+	for name, table := range s {
+		clone[name] = table.clone()
+	}
+	return clone
+}
+
+// clone returns a deep copy of the table
+func (t *Table) clone() *Table {
+	c := &Table{Name: t.Name}
+	for _, column := range t.Columns {
+		if column.ForeignKey != nil {
+			fk := *column.ForeignKey
+			column.ForeignKey = &fk
+		}
+		c.Columns = append(c.Columns, column)
+	}
+	for _, index := range t.Indexes {
+		index.Columns = append([]string(nil), index.Columns...)
+		c.Indexes = append(c.Indexes, index)
+	}
+	c.Checks = append([]Check(nil), t.Checks...)
+	return c
+}
+
+// column returns the named column and its position
+func (t *Table) column(name string) (Column, int, bool) {
+	for i, column := range t.Columns {
+		if column.Name == name {
+			return column, i, true
+		}
+	}
+	return Column{}, -1, false
+}
+
+// check returns the position of the named check
+func (t *Table) check(name string) int {
+	for i, check := range t.Checks {
+		if check.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// index returns the position of the named index
+func (t *Table) index(name string) int {
+	for i, index := range t.Indexes {
+		if index.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package models
+
+import "fmt"
+
+// Index represents an index over one or more columns of a model
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Where   string // Optional predicate making the index partial
+}
+
+// IndexOptions configures an index added with AddIndex
+type IndexOptions struct {
+	Unique bool
+	Where  string
+}
+
+// Check represents a CHECK constraint
+type Check struct {
+	Name       string
+	Expression string
+}
+
+// ConstraintProvider is implemented by models that declare indexes or CHECK constraints
+type ConstraintProvider interface {
+	GetIndexes() []Index
+	GetChecks() []Check
+}
+
+// GetIndexes returns the indexes declared on the model
+func (m *Model) GetIndexes() []Index {
+	return m.Indexes
+}
+
+// GetChecks returns the CHECK constraints declared on the model
+func (m *Model) GetChecks() []Check {
+	return m.Checks
+}
+
+// AddIndex declares an index over the given columns, e.g. a composite unique key
+//
+//	model.AddIndex("order_items_order_product", []string{"order_id", "product_id"}, models.IndexOptions{Unique: true})
+func (m *Model) AddIndex(name string, columns []string, opts IndexOptions) error {
+	if name == "" || len(columns) == 0 {
+		return fmt.Errorf("index on %s requires a name and at least one column", m.TableName)
+	}
+	if m.hasConstraint(name) {
+		return fmt.Errorf("model %s already has a constraint named %s", m.TableName, name)
+	}
+
+	m.Indexes = append(m.Indexes, Index{
+		Name:    name,
+		Columns: append([]string(nil), columns...),
+		Unique:  opts.Unique,
+		Where:   opts.Where,
+	})
+	return nil
+}
+
+// AddCheck declares a CHECK constraint with the given SQL expression, e.g. "quantity > 0"
+func (m *Model) AddCheck(name, expression string) error {
+	if name == "" || expression == "" {
+		return fmt.Errorf("check on %s requires a name and an expression", m.TableName)
+	}
+	if m.hasConstraint(name) {
+		return fmt.Errorf("model %s already has a constraint named %s", m.TableName, name)
+	}
+
+	m.Checks = append(m.Checks, Check{Name: name, Expression: expression})
+	return nil
+}
+
+// hasConstraint reports whether an index or check with the given name exists
+func (m *Model) hasConstraint(name string) bool {
+	for _, index := range m.Indexes {
+		if index.Name == name {
+			return true
+		}
+	}
+	for _, check := range m.Checks {
+		if check.Name == name {
+			return true
+		}
+	}
+	return false
+}
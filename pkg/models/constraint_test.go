@@ -0,0 +1,55 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddConstraints(t *testing.T) {
+	model := NewModel("items")
+	model.AddField("id", reflect.TypeOf(0), WithPrimaryKey())
+	model.AddField("order_id", reflect.TypeOf(0))
+	model.AddField("quantity", reflect.TypeOf(0))
+
+	columns := []string{"order_id", "id"}
+	if err := model.AddIndex("items_order", columns, IndexOptions{Unique: true, Where: "quantity > 0"}); err != nil {
+		t.Fatalf("AddIndex() error = %v", err)
+	}
+	columns[0] = "changed"
+	if err := model.AddCheck("items_quantity", "quantity > 0"); err != nil {
+		t.Fatalf("AddCheck() error = %v", err)
+	}
+
+	wantIndexes := []Index{{Name: "items_order", Columns: []string{"order_id", "id"}, Unique: true, Where: "quantity > 0"}}
+	if !reflect.DeepEqual(model.GetIndexes(), wantIndexes) {
+		t.Errorf("GetIndexes() = %+v, want %+v", model.GetIndexes(), wantIndexes)
+	}
+	if want := []Check{{Name: "items_quantity", Expression: "quantity > 0"}}; !reflect.DeepEqual(model.GetChecks(), want) {
+		t.Errorf("GetChecks() = %+v, want %+v", model.GetChecks(), want)
+	}
+	if err := model.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	invalid := map[string]func() error{
+		"index without a name":        func() error { return model.AddIndex("", []string{"id"}, IndexOptions{}) },
+		"index without columns":       func() error { return model.AddIndex("items_none", nil, IndexOptions{}) },
+		"check without a name":        func() error { return model.AddCheck("", "id > 0") },
+		"check without an expression": func() error { return model.AddCheck("items_empty", "") },
+		"index named like check":      func() error { return model.AddIndex("items_quantity", []string{"id"}, IndexOptions{}) },
+		"check named like index":      func() error { return model.AddCheck("items_order", "id > 0") },
+		"duplicate check":             func() error { return model.AddCheck("items_quantity", "id > 0") },
+	}
+	for name, add := range invalid {
+		if err := add(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	if err := model.AddIndex("items_sku", []string{"sku"}, IndexOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Validate(); err == nil {
+		t.Error("Validate() accepted an index on an unknown column")
+	}
+}
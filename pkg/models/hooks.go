@@ -0,0 +1,52 @@
+package models
+
+import "context"
+
+// Lifecycle hooks are optional interfaces a ModelInterface implementation can
+// satisfy to run code around the ORM's Create, Insert, Update and Delete.
+//
+// Before hooks receive the data about to be written and may modify it;
+// returning an error aborts the operation. After hooks run once the statement
+// has succeeded; an error they return is passed to the caller, so run the
+// operation inside a transaction for it to be rolled back as well. The context
+// carries the current transaction, if any.
+//
+// A model built with NewModel or FromStruct gains hooks by being wrapped:
+//
+//	type UserModel struct{ *models.Model }
+//
+//	func (UserModel) BeforeCreate(ctx context.Context, data map[string]interface{}) error {
+//		data["email"] = strings.ToLower(data["email"].(string))
+//		return nil
+//	}
+
+// BeforeCreateHook is called before a record is inserted
+type BeforeCreateHook interface {
+	BeforeCreate(ctx context.Context, data map[string]interface{}) error
+}
+
+// AfterCreateHook is called after a record is inserted with the inserted
+// data, including the generated primary key
+type AfterCreateHook interface {
+	AfterCreate(ctx context.Context, data map[string]interface{}) error
+}
+
+// BeforeUpdateHook is called before the record with the given primary key is updated
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, id interface{}, data map[string]interface{}) error
+}
+
+// AfterUpdateHook is called after the record with the given primary key is updated
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, id interface{}, data map[string]interface{}) error
+}
+
+// BeforeDeleteHook is called before the record with the given primary key is deleted
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, id interface{}) error
+}
+
+// AfterDeleteHook is called after the record with the given primary key is deleted
+type AfterDeleteHook interface {
+	AfterDelete(ctx context.Context, id interface{}) error
+}
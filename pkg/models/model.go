@@ -18,6 +18,9 @@ type Field struct {
 	Default      interface{}
 	MaxLength    int
 	ForeignKey   *ForeignKey
+	AutoNow      bool // Set to the current time whenever the row is saved
+	AutoNowAdd   bool // Set to the current time when the row is created
+	SoftDelete   bool // Marks the row as deleted instead of deleting it
 }
 
 // ForeignKey represents a foreign key relationship
@@ -32,6 +35,10 @@ type ForeignKey struct {
 type Model struct {
 	TableName string
 	Fields    map[string]Field
+	Relations map[string]Relation
+	Indexes   []Index
+	Checks    []Check
+	Database  string // Named database the model lives in; empty means the default database
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -43,14 +50,28 @@ type ModelInterface interface {
 	Validate() error
 }
 
+// DatabaseProvider is implemented by models that live in a named database
+// other than the default one
+type DatabaseProvider interface {
+	GetDatabase() string
+}
+
 // NewModel creates a new model with the given table name
-func NewModel(tableName string) *Model {
-	return &Model{
+func NewModel(tableName string, options ...func(*Model)) *Model {
+	model := &Model{
 		TableName: tableName,
 		Fields:    make(map[string]Field),
+		Relations: make(map[string]Relation),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+
+	// Apply options
+	for _, option := range options {
+		option(model)
+	}
+
+	return model
 }
 
 // GetTableName returns the table name for the model
@@ -58,6 +79,11 @@ func (m *Model) GetTableName() string {
 	return m.TableName
 }
 
+// GetDatabase returns the named database the model lives in
+func (m *Model) GetDatabase() string {
+	return m.Database
+}
+
 // GetFields returns the fields for the model
 func (m *Model) GetFields() map[string]Field {
 	return m.Fields
@@ -83,6 +109,33 @@ func (m *Model) Validate() error {
 		return fmt.Errorf("model %s has no primary key", m.TableName)
 	}
 
+	// Check that automatic timestamps and soft deletes are only set on time fields
+	softDeletes := 0
+	for name, field := range m.Fields {
+		if (field.AutoNow || field.AutoNowAdd) && field.Type != reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("field %s.%s must be a time.Time to use auto_now or auto_now_add", m.TableName, name)
+		}
+		if field.SoftDelete {
+			if field.Type != reflect.TypeOf(time.Time{}) || field.NotNull {
+				return fmt.Errorf("soft delete field %s.%s must be a nullable time.Time", m.TableName, name)
+			}
+			softDeletes++
+		}
+	}
+
+	if softDeletes > 1 {
+		return fmt.Errorf("model %s has more than one soft delete field", m.TableName)
+	}
+
+	// Check that indexes cover existing columns
+	for _, index := range m.Indexes {
+		for _, column := range index.Columns {
+			if _, ok := m.Fields[column]; !ok {
+				return fmt.Errorf("index %s on %s references unknown column %s", index.Name, m.TableName, column)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -143,6 +196,50 @@ func WithMaxLength(length int) func(*Field) {
 	}
 }
 
+// WithAutoNow sets the field to the current time on every create and update
+func WithAutoNow() func(*Field) {
+	return func(f *Field) {
+		f.AutoNow = true
+	}
+}
+
+// WithAutoNowAdd sets the field to the current time when the record is created
+func WithAutoNowAdd() func(*Field) {
+	return func(f *Field) {
+		f.AutoNowAdd = true
+	}
+}
+
+// WithSoftDelete adds a nullable deleted_at column to the model. Deleting a
+// record then sets deleted_at instead of removing the row, and queries skip
+// deleted rows unless asked otherwise.
+func WithSoftDelete() func(*Model) {
+	return func(m *Model) {
+		m.AddField("deleted_at", reflect.TypeOf(time.Time{}), func(f *Field) {
+			f.SoftDelete = true
+		})
+	}
+}
+
+// WithDatabase places the model in the named database configured on the ORM,
+// e.g. a separate analytics database
+func WithDatabase(name string) func(*Model) {
+	return func(m *Model) {
+		m.Database = name
+	}
+}
+
+// SoftDeleteField returns the column marking deleted rows of a model, and
+// false if the model does not use soft deletes
+func SoftDeleteField(model ModelInterface) (string, bool) {
+	for name, field := range model.GetFields() {
+		if field.SoftDelete {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // WithForeignKey sets a foreign key relationship for the field
 func WithForeignKey(model, field string, onDelete, onUpdate string) func(*Field) {
 	return func(f *Field) {
@@ -159,6 +256,9 @@ func WithForeignKey(model, field string, onDelete, onUpdate string) func(*Field)
 func (m *Model) String() string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Model: %s\n", m.TableName))
+	if m.Database != "" {
+		sb.WriteString(fmt.Sprintf("Database: %s\n", m.Database))
+	}
 	sb.WriteString("Fields:\n")
 	
 	for name, field := range m.Fields {
@@ -184,8 +284,45 @@ func (m *Model) String() string {
 		if field.ForeignKey != nil {
 			sb.WriteString(fmt.Sprintf(" (FK: %s.%s)", field.ForeignKey.Model, field.ForeignKey.Field))
 		}
+		if field.AutoNow {
+			sb.WriteString(" (AutoNow)")
+		}
+		if field.AutoNowAdd {
+			sb.WriteString(" (AutoNowAdd)")
+		}
+		if field.SoftDelete {
+			sb.WriteString(" (SoftDelete)")
+		}
 		sb.WriteString("\n")
 	}
+
+	if len(m.Indexes) > 0 {
+		sb.WriteString("Indexes:\n")
+		for _, index := range m.Indexes {
+			sb.WriteString(fmt.Sprintf("  %s: (%s)", index.Name, strings.Join(index.Columns, ", ")))
+			if index.Unique {
+				sb.WriteString(" (U)")
+			}
+			if index.Where != "" {
+				sb.WriteString(fmt.Sprintf(" (Where: %s)", index.Where))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if len(m.Checks) > 0 {
+		sb.WriteString("Checks:\n")
+		for _, check := range m.Checks {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", check.Name, check.Expression))
+		}
+	}
+
+	if len(m.Relations) > 0 {
+		sb.WriteString("Relations:\n")
+		for name, relation := range m.Relations {
+			sb.WriteString(fmt.Sprintf("  %s: %s %s (%s)\n", name, relation.Type, relation.Model, relation.ForeignKey))
+		}
+	}
 	
 	return sb.String()
 }
\ No newline at end of file
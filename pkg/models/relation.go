@@ -0,0 +1,95 @@
+package models
+
+import "fmt"
+
+// RelationType represents the kind of relationship between two models
+type RelationType string
+
+// Supported relation types
+const (
+	BelongsTo  RelationType = "belongs_to"
+	HasMany    RelationType = "has_many"
+	ManyToMany RelationType = "many_to_many"
+)
+
+// Relation represents a relationship to another model.
+//
+// For BelongsTo, ForeignKey is the column on this table and References the
+// column on the related table. For HasMany, ForeignKey is the column on the
+// related table and References the column on this table. For ManyToMany,
+// JoinTable holds ForeignKey pointing at this table and JoinForeignKey
+// pointing at the related table, and References is the primary key of both.
+type Relation struct {
+	Name           string
+	Type           RelationType
+	Model          string
+	ForeignKey     string
+	References     string
+	JoinTable      string
+	JoinForeignKey string
+}
+
+// RelationProvider is implemented by models that declare relations
+type RelationProvider interface {
+	GetRelations() map[string]Relation
+}
+
+// GetRelations returns the relations declared on the model
+func (m *Model) GetRelations() map[string]Relation {
+	return m.Relations
+}
+
+// AddRelation adds a relation to the model
+func (m *Model) AddRelation(relation Relation) error {
+	if relation.Name == "" || relation.Model == "" || relation.ForeignKey == "" {
+		return fmt.Errorf("relation on %s requires a name, a model and a foreign key", m.TableName)
+	}
+	if relation.Type == ManyToMany && (relation.JoinTable == "" || relation.JoinForeignKey == "") {
+		return fmt.Errorf("many-to-many relation %s requires a join table and join foreign key", relation.Name)
+	}
+	if _, exists := m.Fields[relation.Name]; exists {
+		return fmt.Errorf("relation %s clashes with a field of model %s", relation.Name, m.TableName)
+	}
+	if relation.References == "" {
+		relation.References = "id"
+	}
+
+	if m.Relations == nil {
+		m.Relations = make(map[string]Relation)
+	}
+	m.Relations[relation.Name] = relation
+	return nil
+}
+
+// BelongsTo declares that foreignKey on this model references the primary key of model
+func (m *Model) BelongsTo(name, model, foreignKey string) error {
+	return m.AddRelation(Relation{
+		Name:       name,
+		Type:       BelongsTo,
+		Model:      model,
+		ForeignKey: foreignKey,
+	})
+}
+
+// HasMany declares that foreignKey on model references the primary key of this model
+func (m *Model) HasMany(name, model, foreignKey string) error {
+	return m.AddRelation(Relation{
+		Name:       name,
+		Type:       HasMany,
+		Model:      model,
+		ForeignKey: foreignKey,
+	})
+}
+
+// ManyToMany declares a relation to model through joinTable, where foreignKey
+// references this model and relatedKey references model
+func (m *Model) ManyToMany(name, model, joinTable, foreignKey, relatedKey string) error {
+	return m.AddRelation(Relation{
+		Name:           name,
+		Type:           ManyToMany,
+		Model:          model,
+		ForeignKey:     foreignKey,
+		JoinTable:      joinTable,
+		JoinForeignKey: relatedKey,
+	})
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddRelation(t *testing.T) {
+	model := NewModel("orders")
+	model.AddField("id", reflect.TypeOf(0), WithPrimaryKey())
+	model.AddField("customer_id", reflect.TypeOf(0))
+
+	if err := model.BelongsTo("customer", "customers", "customer_id"); err != nil {
+		t.Fatalf("BelongsTo() error = %v", err)
+	}
+	want := Relation{Name: "customer", Type: BelongsTo, Model: "customers", ForeignKey: "customer_id", References: "id"}
+	if got := model.GetRelations()["customer"]; got != want {
+		t.Errorf("relation = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		relation Relation
+	}{
+		{name: "without name", relation: Relation{Type: HasMany, Model: "items", ForeignKey: "order_id"}},
+		{name: "without foreign key", relation: Relation{Name: "items", Type: HasMany, Model: "items"}},
+		{name: "without join table", relation: Relation{Name: "tags", Type: ManyToMany, Model: "tags", ForeignKey: "order_id"}},
+		{name: "clashes with a field", relation: Relation{Name: "customer_id", Type: BelongsTo, Model: "customers", ForeignKey: "customer_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := model.AddRelation(tt.relation); err == nil {
+				t.Errorf("AddRelation() accepted %+v", tt.relation)
+			}
+		})
+	}
+}
+
+func TestFromStructSkipsRelations(t *testing.T) {
+	type item struct {
+		ID int `db:"id,pk"`
+	}
+	type order struct {
+		ID       int     `db:"id,pk"`
+		Customer *item   `db:"customer"`
+		Items    []item  `db:"items"`
+		Note     *string `db:"note"`
+	}
+
+	model, err := FromStruct(order{})
+	if err != nil {
+		t.Fatalf("FromStruct() error = %v", err)
+	}
+	var names []string
+	for name := range model.GetFields() {
+		names = append(names, name)
+	}
+	if len(names) != 2 || model.GetFields()["note"].Name == "" {
+		t.Errorf("fields = %q, want id and note", names)
+	}
+}
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TableNamer can be implemented by structs passed to FromStruct to override the table name
+type TableNamer interface {
+	TableName() string
+}
+
+// nullableTypes maps database/sql null wrappers to the type they hold
+var nullableTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullTime{}):    reflect.TypeOf(time.Time{}),
+}
+
+// FromStruct builds a model from the exported fields of a struct.
+//
+// The column name and options are read from the `db` tag, e.g.
+//
+//	UserID int `db:"user_id,notnull,fk=users.id,ondelete=CASCADE"`
+//
+// Supported options are pk, autoincrement, unique, notnull, default=<value>,
+// maxlen=<n>, fk=<table>.<column>, ondelete=<action>, onupdate=<action>,
+// auto_now, auto_now_add and softdelete.
+// Fields without a tag use the snake_case field name, fields tagged `db:"-"`
+// are skipped, and an embedded Model is ignored. The table name is taken from
+// a TableName method if the struct has one, otherwise it is the pluralised
+// snake_case struct name.
+func FromStruct(v interface{}) (*Model, error) {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("FromStruct requires a struct or a pointer to a struct, got %T", v)
+	}
+
+	tableName := TableNameOf(v)
+
+	model := NewModel(tableName)
+	if err := addStructFields(model, typ); err != nil {
+		return nil, fmt.Errorf("model %s: %w", tableName, err)
+	}
+
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// TableNameOf returns the table name FromStruct uses for the given struct:
+// the result of its TableName method, or the pluralised snake_case type name
+func TableNameOf(v interface{}) string {
+	if namer, ok := v.(TableNamer); ok {
+		return namer.TableName()
+	}
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return ""
+	}
+	return pluralize(ToSnakeCase(typ.Name()))
+}
+
+// ColumnName returns the column a struct field maps to, and false if the field
+// is excluded with `db:"-"`
+func ColumnName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("db")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.Split(tag, ",")[0])
+	if name == "" {
+		name = ToSnakeCase(sf.Name)
+	}
+	return name, true
+}
+
+// MustFromStruct is like FromStruct but panics on error
+func MustFromStruct(v interface{}) *Model {
+	model, err := FromStruct(v)
+	if err != nil {
+		panic(err)
+	}
+	return model
+}
+
+// addStructFields adds a field to the model for every exported struct field
+func addStructFields(model *Model, typ reflect.Type) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+
+		if sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded == reflect.TypeOf(Model{}) {
+				continue // Skip the base model
+			}
+			if embedded.Kind() == reflect.Struct && sf.Tag.Get("db") == "" {
+				if err := addStructFields(model, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			continue // Skip unexported fields
+		}
+
+		if isRelationType(sf.Type) {
+			continue // Preloaded relations are not columns
+		}
+
+		name, ok := ColumnName(sf)
+		if !ok {
+			continue
+		}
+
+		options := strings.Split(sf.Tag.Get("db"), ",")[1:]
+		field, err := parseField(name, sf.Type, options)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+
+		model.Fields[name] = field
+	}
+
+	return nil
+}
+
+// isRelationType reports whether a field type holds preloaded related rows
+// (a struct, or a slice of structs) rather than a column value
+func isRelationType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	_, nullable := nullableTypes[typ]
+	return !nullable
+}
+
+// parseField builds a field from a Go type and the options of a `db` tag
+func parseField(name string, goType reflect.Type, options []string) (Field, error) {
+	fieldType := goType
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if inner, ok := nullableTypes[fieldType]; ok {
+		fieldType = inner
+	}
+
+	field := Field{
+		Name: name,
+		Type: fieldType,
+	}
+
+	var onDelete, onUpdate string
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		key, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+
+		switch strings.ToLower(key) {
+		case "pk", "primarykey":
+			field.PrimaryKey = true
+		case "autoincrement", "ai":
+			field.AutoIncrement = true
+		case "unique":
+			field.Unique = true
+		case "notnull":
+			field.NotNull = true
+		case "default":
+			defaultValue, err := parseDefault(value, fieldType)
+			if err != nil {
+				return Field{}, err
+			}
+			field.Default = defaultValue
+		case "maxlen", "maxlength":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return Field{}, fmt.Errorf("invalid maxlen %q", value)
+			}
+			field.MaxLength = n
+		case "fk":
+			ref := strings.SplitN(value, ".", 2)
+			if len(ref) != 2 || ref[0] == "" || ref[1] == "" {
+				return Field{}, fmt.Errorf("invalid fk %q: expected table.column", value)
+			}
+			field.ForeignKey = &ForeignKey{Model: ref[0], Field: ref[1]}
+		case "auto_now":
+			field.AutoNow = true
+		case "auto_now_add":
+			field.AutoNowAdd = true
+		case "softdelete":
+			field.SoftDelete = true
+		case "ondelete":
+			onDelete = value
+		case "onupdate":
+			onUpdate = value
+		default:
+			return Field{}, fmt.Errorf("unknown tag option %q", key)
+		}
+	}
+
+	if onDelete != "" || onUpdate != "" {
+		if field.ForeignKey == nil {
+			return Field{}, fmt.Errorf("ondelete and onupdate require fk")
+		}
+		field.ForeignKey.OnDelete = onDelete
+		field.ForeignKey.OnUpdate = onUpdate
+	}
+
+	return field, nil
+}
+
+// parseDefault converts a default value from a tag to the field's type
+func parseDefault(value string, fieldType reflect.Type) (interface{}, error) {
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		if value == "now" || strings.EqualFold(value, "CURRENT_TIMESTAMP") {
+			return time.Now(), nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time default %q", value)
+		}
+		return t, nil
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool default %q", value)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fieldType.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer default %q", value)
+		}
+		return reflect.ValueOf(n).Convert(fieldType).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fieldType.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer default %q", value)
+		}
+		return reflect.ValueOf(n).Convert(fieldType).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fieldType.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("invalid float default %q", value)
+		}
+		return reflect.ValueOf(f).Convert(fieldType).Interface(), nil
+	default:
+		return value, nil
+	}
+}
+
+// ToSnakeCase converts a Go identifier such as "UserID" to "user_id"
+func ToSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word after a lowercase letter, or before the last
+			// capital of an acronym followed by a lowercase letter ("HTTPServer")
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// pluralize returns a naive English plural of a snake_case name
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
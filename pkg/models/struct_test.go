@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Timestamps is embedded by structFixture
+type Timestamps struct {
+	CreatedAt time.Time `db:"created_at,notnull,default=now"`
+	UpdatedAt time.Time `db:"updated_at,auto_now"`
+}
+
+// structFixture covers the tag options of FromStruct
+type structFixture struct {
+	Model
+	Timestamps
+	ID       int64          `db:"id,pk,autoincrement"`
+	Email    string         `db:"email,unique,notnull,maxlen=255"`
+	Status   string         `db:"status,default=it's new"`
+	Score    float32        `db:"score,default=1.5"`
+	Retries  uint8          `db:"retries,default=3"`
+	Active   bool           `db:",default=true"`
+	Nickname sql.NullString // Untagged: snake_case name
+	ParentID *int           `db:"parent_id,fk=struct_fixtures.id,ondelete=SET NULL,onupdate=CASCADE"`
+	Ignored  string         `db:"-"`
+	hidden   string
+}
+
+func (structFixture) TableName() string { return "fixtures" }
+
+func TestFromStruct(t *testing.T) {
+	model, err := FromStruct(&structFixture{})
+	if err != nil {
+		t.Fatalf("FromStruct() error = %v", err)
+	}
+	if model.GetTableName() != "fixtures" {
+		t.Errorf("table = %s, want fixtures", model.GetTableName())
+	}
+
+	fields := model.GetFields()
+	created, ok := fields["created_at"].Default.(time.Time)
+	if !ok || created.IsZero() {
+		t.Errorf("created_at default = %v, want the current time", fields["created_at"].Default)
+	}
+	delete(fields, "created_at")
+
+	want := map[string]Field{
+		"id":         {Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true, AutoIncrement: true},
+		"email":      {Name: "email", Type: reflect.TypeOf(""), Unique: true, NotNull: true, MaxLength: 255},
+		"status":     {Name: "status", Type: reflect.TypeOf(""), Default: "it's new"},
+		"score":      {Name: "score", Type: reflect.TypeOf(float32(0)), Default: float32(1.5)},
+		"retries":    {Name: "retries", Type: reflect.TypeOf(uint8(0)), Default: uint8(3)},
+		"active":     {Name: "active", Type: reflect.TypeOf(false), Default: true},
+		"updated_at": {Name: "updated_at", Type: reflect.TypeOf(time.Time{}), AutoNow: true},
+		"nickname":   {Name: "nickname", Type: reflect.TypeOf("")},
+		"parent_id": {Name: "parent_id", Type: reflect.TypeOf(0),
+			ForeignKey: &ForeignKey{Model: "struct_fixtures", Field: "id", OnDelete: "SET NULL", OnUpdate: "CASCADE"}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %+v\nwant %+v", fields, want)
+	}
+}
+
+func TestFromStructTableName(t *testing.T) {
+	type Category struct {
+		ID int `db:"id,pk"`
+	}
+	model, err := FromStruct(Category{})
+	if err != nil {
+		t.Fatalf("FromStruct() error = %v", err)
+	}
+	if model.GetTableName() != "categories" {
+		t.Errorf("table = %s, want categories", model.GetTableName())
+	}
+}
+
+func TestFromStructInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "not a struct", value: 42},
+		{name: "nil", value: nil},
+		{name: "unknown option", value: &struct {
+			ID int `db:"id,pk,index"`
+		}{}},
+		{name: "invalid maxlen", value: &struct {
+			ID   int    `db:"id,pk"`
+			Name string `db:"name,maxlen=long"`
+		}{}},
+		{name: "invalid fk", value: &struct {
+			ID     int `db:"id,pk"`
+			UserID int `db:"user_id,fk=users"`
+		}{}},
+		{name: "ondelete without fk", value: &struct {
+			ID     int `db:"id,pk"`
+			UserID int `db:"user_id,ondelete=CASCADE"`
+		}{}},
+		{name: "invalid default", value: &struct {
+			ID    int  `db:"id,pk"`
+			Count int8 `db:"count,default=300"`
+		}{}},
+		{name: "invalid time default", value: &struct {
+			ID      int       `db:"id,pk"`
+			Created time.Time `db:"created,default=yesterday"`
+		}{}},
+		{name: "auto_now on a string", value: &struct {
+			ID      int    `db:"id,pk"`
+			Updated string `db:"updated,auto_now"`
+		}{}},
+		{name: "not null soft delete", value: &struct {
+			ID      int       `db:"id,pk"`
+			Deleted time.Time `db:"deleted,notnull,softdelete"`
+		}{}},
+		{name: "two soft delete fields", value: &struct {
+			ID       int       `db:"id,pk"`
+			Deleted  time.Time `db:"deleted,softdelete"`
+			Archived time.Time `db:"archived,softdelete"`
+		}{}},
+		{name: "no primary key", value: &struct {
+			Name string `db:"name"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if model, err := FromStruct(tt.value); err == nil {
+				t.Errorf("FromStruct() = %v, want an error", model)
+			}
+		})
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"ID":           "id",
+		"UserID":       "user_id",
+		"HTTPServer":   "http_server",
+		"OrderItem":    "order_item",
+		"Address2Line": "address2_line",
+		"already_done": "already_done",
+	}
+	for name, want := range tests {
+		if got := ToSnakeCase(name); got != want {
+			t.Errorf("ToSnakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := map[string]string{
+		"user":     "users",
+		"address":  "addresses",
+		"box":      "boxes",
+		"match":    "matches",
+		"category": "categories",
+		"day":      "days",
+	}
+	for name, want := range tests {
+		if got := pluralize(name); got != want {
+			t.Errorf("pluralize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultBulkBatchSize is used by BulkCreate when no batch size is given
+const defaultBulkBatchSize = 100
+
+// maxBindParams keeps a single statement below the bind parameter limit of
+// every supported database (SQLite allows 32766, PostgreSQL and MySQL 65535)
+const maxBindParams = 32766
+
+// BulkCreate inserts rows using multi-row INSERT statements of up to batchSize
+// rows each and returns the number of inserted rows. Rows setting the same
+// columns are batched together, so columns a row omits keep their defaults.
+// All batches run in one transaction. Automatic timestamps are set, but
+// lifecycle hooks are not called.
+func (o *ORM) BulkCreate(tableName string, rows []map[string]interface{}, batchSize int) (int64, error) {
+	return o.BulkCreateContext(context.Background(), tableName, rows, batchSize)
+}
+
+// BulkCreateContext inserts rows in batches using the given context
+func (o *ORM) BulkCreateContext(ctx context.Context, tableName string, rows []map[string]interface{}, batchSize int) (int64, error) {
+	db := o.using(tableName)
+	var count int64
+	err := db.Transaction(ctx, func(tx *Tx) error {
+		var err error
+		count, err = db.bulkCreate(tx.ctx, tx.tx, tableName, rows, batchSize)
+		return err
+	})
+	return count, err
+}
+
+// bulkCreate inserts rows in batches using the given executor
+func (o *ORM) bulkCreate(ctx context.Context, ex executor, tableName string, rows []map[string]interface{}, batchSize int) (int64, error) {
+	model, ok := o.models[tableName]
+	if !ok {
+		return 0, fmt.Errorf("model %s not registered", tableName)
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBulkBatchSize
+	}
+
+	// Group rows by the columns they set, keeping the order of first appearance
+	var signatures []string
+	groups := make(map[string][]map[string]interface{})
+	columnsOf := make(map[string][]string)
+	fields := model.GetFields()
+	for _, row := range rows {
+		row = copyData(row)
+		setTimestamps(model, row, true)
+
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			if _, ok := fields[column]; !ok {
+				return 0, fmt.Errorf("field %s not found in model %s", column, tableName)
+			}
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		signature := strings.Join(columns, ",")
+		if _, seen := groups[signature]; !seen {
+			signatures = append(signatures, signature)
+			columnsOf[signature] = columns
+		}
+		groups[signature] = append(groups[signature], row)
+	}
+
+	var count int64
+	for _, signature := range signatures {
+		columns := columnsOf[signature]
+		group := groups[signature]
+
+		size := batchSize
+		if len(columns) > 0 && size*len(columns) > maxBindParams {
+			size = maxBindParams / len(columns)
+		}
+
+		for start := 0; start < len(group); start += size {
+			end := start + size
+			if end > len(group) {
+				end = len(group)
+			}
+			query, values := o.multiInsertSQL(tableName, columns, group[start:end])
+			n, err := o.exec(ctx, ex, query, values...)
+			if err != nil {
+				return count, err
+			}
+			count += n
+		}
+	}
+
+	return count, nil
+}
+
+// BulkUpdate updates rows by primary key and returns the number of updated rows.
+// Every row must contain its primary key; the other columns in the row are
+// updated. All updates run in one transaction. Automatic timestamps are set,
+// but lifecycle hooks are not called. Soft-deleted rows are not updated.
+func (o *ORM) BulkUpdate(tableName string, rows []map[string]interface{}) (int64, error) {
+	return o.BulkUpdateContext(context.Background(), tableName, rows)
+}
+
+// BulkUpdateContext updates rows by primary key using the given context
+func (o *ORM) BulkUpdateContext(ctx context.Context, tableName string, rows []map[string]interface{}) (int64, error) {
+	db := o.using(tableName)
+	var count int64
+	err := db.Transaction(ctx, func(tx *Tx) error {
+		var err error
+		count, err = db.bulkUpdate(tx.ctx, tx.tx, tableName, rows)
+		return err
+	})
+	return count, err
+}
+
+// bulkUpdate updates rows by primary key using the given executor
+func (o *ORM) bulkUpdate(ctx context.Context, ex executor, tableName string, rows []map[string]interface{}) (int64, error) {
+	model, ok := o.models[tableName]
+	if !ok {
+		return 0, fmt.Errorf("model %s not registered", tableName)
+	}
+
+	fields := model.GetFields()
+	primaryKeys := primaryKeysOf(model)
+
+	var count int64
+	for i, row := range rows {
+		row = copyData(row)
+		setTimestamps(model, row, false)
+
+		key := make(map[string]interface{}, len(primaryKeys))
+		for _, name := range primaryKeys {
+			value, ok := row[name]
+			if !ok {
+				return count, fmt.Errorf("row %d has no value for primary key %s.%s", i, tableName, name)
+			}
+			key[name] = value
+		}
+
+		var columns []string
+		for column := range row {
+			field, ok := fields[column]
+			if !ok {
+				return count, fmt.Errorf("field %s not found in model %s", column, tableName)
+			}
+			if !field.PrimaryKey {
+				columns = append(columns, column)
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		sort.Strings(columns)
+
+		var sets []string
+		var values []interface{}
+		for _, column := range columns {
+			values = append(values, row[column])
+			sets = append(sets, fmt.Sprintf("%s = %s", o.Quote(column), o.placeholder(len(values))))
+		}
+
+		var id interface{} = key
+		if len(primaryKeys) == 1 {
+			id = key[primaryKeys[0]]
+		}
+		where, args, err := o.primaryKeyCondition(model, id, len(values)+1)
+		if err != nil {
+			return count, err
+		}
+
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s%s",
+			o.Quote(tableName), strings.Join(sets, ", "), where, o.notTrashed(model))
+		n, err := o.exec(ctx, ex, query, append(values, args...)...)
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+
+	return count, nil
+}
+
+// Upsert inserts row, or updates the existing row that conflicts with it on
+// conflictColumns. Only updateColumns are overwritten on conflict; when
+// updateColumns is nil every column of row except the conflict columns is.
+// auto_now fields are always updated and auto_now_add fields only set on insert.
+// MySQL resolves conflicts against any unique key regardless of conflictColumns.
+func (o *ORM) Upsert(tableName string, row map[string]interface{}, conflictColumns, updateColumns []string) error {
+	db := o.using(tableName)
+	return db.upsert(context.Background(), db.db, tableName, row, conflictColumns, updateColumns)
+}
+
+// UpsertContext inserts or updates a row using the given context
+func (o *ORM) UpsertContext(ctx context.Context, tableName string, row map[string]interface{}, conflictColumns, updateColumns []string) error {
+	db := o.using(tableName)
+	return db.upsert(ctx, db.db, tableName, row, conflictColumns, updateColumns)
+}
+
+// upsert inserts or updates a row using the given executor
+func (o *ORM) upsert(ctx context.Context, ex executor, tableName string, row map[string]interface{}, conflictColumns, updateColumns []string) error {
+	model, ok := o.models[tableName]
+	if !ok {
+		return fmt.Errorf("model %s not registered", tableName)
+	}
+	if len(conflictColumns) == 0 {
+		return fmt.Errorf("upsert on %s requires conflict columns", tableName)
+	}
+
+	fields := model.GetFields()
+	for _, column := range append(append([]string(nil), conflictColumns...), updateColumns...) {
+		if _, ok := fields[column]; !ok {
+			return fmt.Errorf("field %s not found in model %s", column, tableName)
+		}
+	}
+
+	row = copyData(row)
+	setTimestamps(model, row, true)
+
+	if updateColumns == nil {
+		conflict := make(map[string]bool, len(conflictColumns))
+		for _, column := range conflictColumns {
+			conflict[column] = true
+		}
+		for column := range row {
+			if !conflict[column] && !fields[column].AutoNowAdd {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+		sort.Strings(updateColumns)
+	} else if len(updateColumns) > 0 {
+		updateColumns = append([]string(nil), updateColumns...)
+		for name, field := range fields {
+			if field.AutoNow && !containsString(updateColumns, name) {
+				updateColumns = append(updateColumns, name)
+			}
+		}
+	}
+
+	query, values, err := o.insertSQL(model, row)
+	if err != nil {
+		return err
+	}
+	query += " " + o.dialect.Upsert(conflictColumns, updateColumns)
+
+	_, err = o.exec(ctx, ex, query, values...)
+	return err
+}
+
+// multiInsertSQL builds an INSERT statement with one VALUES tuple per row
+func (o *ORM) multiInsertSQL(tableName string, columns []string, rows []map[string]interface{}) (string, []interface{}) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = o.Quote(column)
+	}
+
+	tuples := make([]string, len(rows))
+	values := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		placeholders := make([]string, len(columns))
+		for j, column := range columns {
+			values = append(values, row[column])
+			placeholders[j] = o.placeholder(len(values))
+		}
+		tuples[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		o.Quote(tableName), strings.Join(quoted, ", "), strings.Join(tuples, ", "))
+	return query, values
+}
+
+// exec runs a statement with the default query timeout and returns the number of affected rows
+func (o *ORM) exec(ctx context.Context, ex executor, query string, args ...interface{}) (int64, error) {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+
+	result, err := ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, constraintError(err)
+	}
+	return result.RowsAffected()
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// openProductsORM returns a test ORM with a products model whose sku is unique
+func openProductsORM(t *testing.T) *ORM {
+	t.Helper()
+	o := openTestORM(t,
+		"CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, sku TEXT NOT NULL UNIQUE, name TEXT, stock INTEGER DEFAULT 5)",
+	)
+	model := models.NewModel("products")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("sku", reflect.TypeOf(""), models.WithNotNull(), models.WithUnique())
+	model.AddField("name", reflect.TypeOf(""))
+	model.AddField("stock", reflect.TypeOf(0))
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestMultiInsertSQL(t *testing.T) {
+	rows := []map[string]interface{}{{"sku": "a", "stock": 1}, {"sku": "b", "stock": 2}}
+
+	tests := []struct {
+		dialect Dialect
+		sql     string
+	}{
+		{dialect: SQLiteDialect{}, sql: `INSERT INTO "products" ("sku", "stock") VALUES (?, ?), (?, ?)`},
+		{dialect: MySQLDialect{}, sql: "INSERT INTO `products` (`sku`, `stock`) VALUES (?, ?), (?, ?)"},
+		{dialect: PostgresDialect{}, sql: `INSERT INTO "products" ("sku", "stock") VALUES ($1, $2), ($3, $4)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			o := &ORM{dialect: tt.dialect}
+			sql, values := o.multiInsertSQL("products", []string{"sku", "stock"}, rows)
+			if sql != tt.sql {
+				t.Errorf("multiInsertSQL() = %s, want %s", sql, tt.sql)
+			}
+			if want := []interface{}{"a", 1, "b", 2}; !reflect.DeepEqual(values, want) {
+				t.Errorf("multiInsertSQL() values = %v, want %v", values, want)
+			}
+		})
+	}
+}
+
+func TestBulkCreate(t *testing.T) {
+	o := openProductsORM(t)
+
+	// Rows omitting stock are batched separately and keep the default
+	rows := []map[string]interface{}{
+		{"sku": "a", "stock": 1},
+		{"sku": "b"},
+		{"sku": "c", "stock": 3},
+		{"sku": "d", "stock": 4},
+		{"sku": "e"},
+	}
+	count, err := o.BulkCreate("products", rows, 2)
+	if err != nil {
+		t.Fatalf("BulkCreate() error = %v", err)
+	}
+	if count != 5 {
+		t.Errorf("BulkCreate() = %d, want 5", count)
+	}
+
+	stored, err := o.Table("products").OrderBy("sku").All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stock []int64
+	for _, row := range stored {
+		stock = append(stock, row["stock"].(int64))
+	}
+	if want := []int64{1, 5, 3, 4, 5}; !reflect.DeepEqual(stock, want) {
+		t.Errorf("stock = %v, want %v", stock, want)
+	}
+
+	// A failing batch rolls back the batches before it
+	_, err = o.BulkCreate("products", []map[string]interface{}{{"sku": "f"}, {"sku": "g"}, {"sku": "a"}}, 2)
+	if err == nil {
+		t.Fatal("BulkCreate() accepted a duplicate sku")
+	}
+	if n, _ := o.Table("products").Count(); n != 5 {
+		t.Errorf("%d products after a failed BulkCreate(), want 5", n)
+	}
+
+	if _, err := o.BulkCreate("products", []map[string]interface{}{{"sku": "h", "price": 1}}, 0); err == nil {
+		t.Error("BulkCreate() accepted an unknown field")
+	}
+}
+
+func TestBulkUpdate(t *testing.T) {
+	o := openProductsORM(t)
+	if _, err := o.BulkCreate("products", []map[string]interface{}{{"sku": "a"}, {"sku": "b"}, {"sku": "c"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := o.BulkUpdate("products", []map[string]interface{}{
+		{"id": 1, "stock": 10, "name": "Pen"},
+		{"id": 3, "stock": 30},
+		{"id": 2}, // Nothing to update
+		{"id": 9, "stock": 90},
+	})
+	if err != nil {
+		t.Fatalf("BulkUpdate() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("BulkUpdate() = %d, want 2", count)
+	}
+	if row, _ := o.Get("products", 1); row["stock"] != int64(10) || row["name"] != "Pen" {
+		t.Errorf("product 1 = %v", row)
+	}
+
+	// A row without its key rolls back the whole update
+	_, err = o.BulkUpdate("products", []map[string]interface{}{{"id": 2, "stock": 20}, {"stock": 0}})
+	if err == nil {
+		t.Fatal("BulkUpdate() accepted a row without a primary key")
+	}
+	if row, _ := o.Get("products", 2); row["stock"] != int64(5) {
+		t.Errorf("product 2 = %v after a failed BulkUpdate(), want stock 5", row)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	o := openProductsORM(t)
+	product := func() map[string]interface{} {
+		row, err := o.Table("products").Where("sku", "=", "a").First()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row
+	}
+
+	if err := o.Upsert("products", map[string]interface{}{"sku": "a", "name": "Pen", "stock": 1}, []string{"sku"}, nil); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if err := o.Upsert("products", map[string]interface{}{"sku": "a", "name": "Ink", "stock": 2}, []string{"sku"}, nil); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if row := product(); row["name"] != "Ink" || row["stock"] != int64(2) {
+		t.Errorf("product = %v, want every column updated", row)
+	}
+
+	if err := o.Upsert("products", map[string]interface{}{"sku": "a", "name": "Cap", "stock": 3}, []string{"sku"}, []string{"stock"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if row := product(); row["name"] != "Ink" || row["stock"] != int64(3) {
+		t.Errorf("product = %v, want only stock updated", row)
+	}
+
+	if err := o.Upsert("products", map[string]interface{}{"sku": "a", "stock": 4}, []string{"sku"}, []string{}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if row := product(); row["stock"] != int64(3) {
+		t.Errorf("product = %v, want the conflict ignored", row)
+	}
+	if n, _ := o.Table("products").Count(); n != 1 {
+		t.Errorf("%d products, want 1", n)
+	}
+
+	if err := o.Upsert("products", map[string]interface{}{"sku": "b"}, nil, nil); err == nil {
+		t.Error("Upsert() accepted no conflict columns")
+	}
+	if err := o.Upsert("products", map[string]interface{}{"sku": "b"}, []string{"code"}, nil); err == nil {
+		t.Error("Upsert() accepted an unknown conflict column")
+	}
+}
@@ -0,0 +1,361 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// Dialect describes the SQL differences between database engines.
+//
+// The ORM looks up the dialect registered under Config.Dialect, or under
+// Config.Driver when no dialect is set. Additional engines are supported by
+// registering a Dialect with RegisterDialect; the built-in dialects can be
+// embedded to override only what differs, e.g.
+//
+//	type CockroachDialect struct{ orm.PostgresDialect }
+//
+//	func (CockroachDialect) Name() string { return "cockroach" }
+//
+//	func init() { orm.RegisterDialect("cockroach", CockroachDialect{}) }
+type Dialect interface {
+	// Name returns the name the dialect is registered under
+	Name() string
+
+	// DSN builds the data source name passed to sql.Open
+	DSN(config Config) string
+
+	// Setup prepares a freshly opened connection pool
+	Setup(db *sql.DB) error
+
+	// Placeholder returns the bind parameter placeholder for the n-th argument (1-based)
+	Placeholder(n int) string
+
+	// Quote quotes a single identifier such as a table or column name
+	Quote(identifier string) string
+
+	// SQLType returns the column type for a field
+	SQLType(field models.Field) string
+
+	// AutoIncrement returns the column clause making a field auto-incrementing,
+	// or an empty string when SQLType already takes care of it
+	AutoIncrement(field models.Field) string
+
+	// SupportsReturning reports whether INSERT ... RETURNING is available
+	SupportsReturning() bool
+
+	// SupportsPartialIndexes reports whether CREATE INDEX accepts a WHERE clause
+	SupportsPartialIndexes() bool
+
+	// Upsert returns the clause appended to an INSERT that updates the given
+	// columns when a row with the same conflict columns already exists.
+	// Columns are passed unquoted.
+	Upsert(conflict, update []string) string
+
+	// LimitOffset returns the LIMIT/OFFSET clause, without a leading space,
+	// or an empty string when neither is set. Zero means not set.
+	LimitOffset(limit, offset int) string
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = make(map[string]Dialect)
+)
+
+func init() {
+	RegisterDialect("sqlite3", SQLiteDialect{})
+	RegisterDialect("mysql", MySQLDialect{})
+	RegisterDialect("postgres", PostgresDialect{})
+}
+
+// RegisterDialect makes a dialect available under the given name, replacing
+// any dialect previously registered under it
+func RegisterDialect(name string, dialect Dialect) {
+	if dialect == nil {
+		panic("orm: RegisterDialect dialect is nil")
+	}
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[name] = dialect
+}
+
+// GetDialect returns the dialect registered under the given name
+func GetDialect(name string) (Dialect, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	dialect, ok := dialects[name]
+	return dialect, ok
+}
+
+// Dialects returns the names of the registered dialects in sorted order
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// standardSQLType maps a field to the column types shared by the built-in dialects
+func standardSQLType(field models.Field) string {
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "INTEGER"
+	case reflect.Int64, reflect.Uint64:
+		return "BIGINT"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.String:
+		if field.MaxLength > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", field.MaxLength)
+		}
+		return "TEXT"
+	default:
+		if field.Type == reflect.TypeOf(time.Time{}) {
+			return "TIMESTAMP"
+		}
+		return "TEXT"
+	}
+}
+
+// standardLimitOffset renders "LIMIT n OFFSET m"; noLimit is used when only an offset is set
+func standardLimitOffset(limit, offset int, noLimit string) string {
+	var parts []string
+	if limit > 0 {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", limit))
+	} else if offset > 0 && noLimit != "" {
+		parts = append(parts, "LIMIT "+noLimit)
+	}
+	if offset > 0 {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", offset))
+	}
+	return strings.Join(parts, " ")
+}
+
+// onConflict renders the ON CONFLICT clause used by PostgreSQL and SQLite
+func onConflict(d Dialect, conflict, update []string) string {
+	clause := "ON CONFLICT"
+	if len(conflict) > 0 {
+		quoted := make([]string, len(conflict))
+		for i, column := range conflict {
+			quoted[i] = d.Quote(column)
+		}
+		clause += fmt.Sprintf(" (%s)", strings.Join(quoted, ", "))
+	}
+	if len(update) == 0 {
+		return clause + " DO NOTHING"
+	}
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = excluded.%s", d.Quote(column), d.Quote(column))
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// SQLiteDialect is the dialect for the sqlite3 driver
+type SQLiteDialect struct{}
+
+// Name implements Dialect
+func (SQLiteDialect) Name() string { return "sqlite3" }
+
+// DSN implements Dialect
+func (SQLiteDialect) DSN(config Config) string {
+	// Enable foreign keys on every pooled connection, not just the first
+	params := map[string]string{"_foreign_keys": "1"}
+	for key, value := range config.Params {
+		params[key] = value
+	}
+	separator := "?"
+	if strings.Contains(config.Database, "?") {
+		separator = "&"
+	}
+	return config.Database + separator + encodeParams(params)
+}
+
+// Setup implements Dialect
+func (SQLiteDialect) Setup(db *sql.DB) error {
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		return fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+	return nil
+}
+
+// Placeholder implements Dialect
+func (SQLiteDialect) Placeholder(n int) string { return "?" }
+
+// Quote implements Dialect
+func (SQLiteDialect) Quote(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+// SQLType implements Dialect
+func (SQLiteDialect) SQLType(field models.Field) string {
+	// Only INTEGER primary keys alias the rowid and auto-increment
+	if field.AutoIncrement {
+		return "INTEGER"
+	}
+	return standardSQLType(field)
+}
+
+// AutoIncrement implements Dialect
+func (SQLiteDialect) AutoIncrement(field models.Field) string { return "" }
+
+// SupportsReturning implements Dialect; RETURNING requires SQLite 3.35, which go-sqlite3 bundles
+func (SQLiteDialect) SupportsReturning() bool { return true }
+
+// SupportsPartialIndexes implements Dialect
+func (SQLiteDialect) SupportsPartialIndexes() bool { return true }
+
+// Upsert implements Dialect
+func (d SQLiteDialect) Upsert(conflict, update []string) string {
+	return onConflict(d, conflict, update)
+}
+
+// LimitOffset implements Dialect
+func (SQLiteDialect) LimitOffset(limit, offset int) string {
+	// SQLite does not accept OFFSET without LIMIT
+	return standardLimitOffset(limit, offset, "-1")
+}
+
+// MySQLDialect is the dialect for the mysql driver
+type MySQLDialect struct{}
+
+// Name implements Dialect
+func (MySQLDialect) Name() string { return "mysql" }
+
+// DSN implements Dialect
+func (MySQLDialect) DSN(config Config) string {
+	// clientFoundRows makes RowsAffected count matched rows, so that updating
+	// a record with its current values is not mistaken for a missing record
+	params := map[string]string{"parseTime": "true", "clientFoundRows": "true"}
+	for key, value := range config.Params {
+		params[key] = value
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s",
+		config.User, config.Password, config.Host, config.Port, config.Database, encodeParams(params))
+}
+
+// Setup implements Dialect
+func (MySQLDialect) Setup(db *sql.DB) error { return nil }
+
+// Placeholder implements Dialect
+func (MySQLDialect) Placeholder(n int) string { return "?" }
+
+// Quote implements Dialect
+func (MySQLDialect) Quote(identifier string) string {
+	return "`" + strings.Replace(identifier, "`", "``", -1) + "`"
+}
+
+// SQLType implements Dialect
+func (MySQLDialect) SQLType(field models.Field) string {
+	if field.Type == reflect.TypeOf(time.Time{}) {
+		return "DATETIME"
+	}
+	return standardSQLType(field)
+}
+
+// AutoIncrement implements Dialect
+func (MySQLDialect) AutoIncrement(field models.Field) string { return "AUTO_INCREMENT" }
+
+// SupportsReturning implements Dialect
+func (MySQLDialect) SupportsReturning() bool { return false }
+
+// SupportsPartialIndexes implements Dialect
+func (MySQLDialect) SupportsPartialIndexes() bool { return false }
+
+// Upsert implements Dialect
+func (d MySQLDialect) Upsert(conflict, update []string) string {
+	// MySQL resolves conflicts against every unique key, so the conflict columns are unused
+	if len(update) == 0 {
+		if len(conflict) == 0 {
+			return ""
+		}
+		update = conflict[:1] // Assigning a column to itself turns the insert into a no-op
+	}
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", d.Quote(column), d.Quote(column))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// LimitOffset implements Dialect
+func (MySQLDialect) LimitOffset(limit, offset int) string {
+	// MySQL does not accept OFFSET without LIMIT
+	return standardLimitOffset(limit, offset, "18446744073709551615")
+}
+
+// PostgresDialect is the dialect for the postgres driver
+type PostgresDialect struct{}
+
+// Name implements Dialect
+func (PostgresDialect) Name() string { return "postgres" }
+
+// DSN implements Dialect
+func (PostgresDialect) DSN(config Config) string {
+	params := map[string]string{"sslmode": "disable"}
+	for key, value := range config.Params {
+		params[key] = value
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+		quotePostgresValue(config.Host), config.Port, quotePostgresValue(config.User),
+		quotePostgresValue(config.Password), quotePostgresValue(config.Database))
+	for _, key := range sortedKeys(params) {
+		dsn += fmt.Sprintf(" %s=%s", key, quotePostgresValue(params[key]))
+	}
+	return dsn
+}
+
+// Setup implements Dialect
+func (PostgresDialect) Setup(db *sql.DB) error { return nil }
+
+// Placeholder implements Dialect
+func (PostgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// Quote implements Dialect
+func (PostgresDialect) Quote(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+// SQLType implements Dialect
+func (PostgresDialect) SQLType(field models.Field) string {
+	if field.AutoIncrement {
+		switch field.Type.Kind() {
+		case reflect.Int64, reflect.Uint64:
+			return "BIGSERIAL"
+		default:
+			return "SERIAL"
+		}
+	}
+	return standardSQLType(field)
+}
+
+// AutoIncrement implements Dialect
+func (PostgresDialect) AutoIncrement(field models.Field) string { return "" }
+
+// SupportsReturning implements Dialect
+func (PostgresDialect) SupportsReturning() bool { return true }
+
+// SupportsPartialIndexes implements Dialect
+func (PostgresDialect) SupportsPartialIndexes() bool { return true }
+
+// Upsert implements Dialect
+func (d PostgresDialect) Upsert(conflict, update []string) string {
+	return onConflict(d, conflict, update)
+}
+
+// LimitOffset implements Dialect
+func (PostgresDialect) LimitOffset(limit, offset int) string {
+	return standardLimitOffset(limit, offset, "")
+}
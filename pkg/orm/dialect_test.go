@@ -0,0 +1,254 @@
+package orm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+func TestDialectDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name:   "mysql",
+			config: Config{Driver: "mysql", Host: "db", Port: 3306, User: "app", Password: "secret", Database: "shop"},
+			want:   "app:secret@tcp(db:3306)/shop?clientFoundRows=true&parseTime=true",
+		},
+		{
+			name: "mysql params",
+			config: Config{Driver: "mysql", Host: "db", Port: 3306, User: "app", Password: "secret", Database: "shop",
+				Params: map[string]string{"tls": "true", "charset": "utf8mb4"}},
+			want: "app:secret@tcp(db:3306)/shop?charset=utf8mb4&clientFoundRows=true&parseTime=true&tls=true",
+		},
+		{
+			name:   "postgres",
+			config: Config{Driver: "postgres", Host: "db", Port: 5432, User: "app", Password: "secret", Database: "shop"},
+			want:   "host=db port=5432 user=app password=secret dbname=shop sslmode=disable",
+		},
+		{
+			name: "postgres quoting and params",
+			config: Config{Driver: "postgres", Host: "db", Port: 5432, User: "app", Password: `it's a \secret`, Database: "shop",
+				Params: map[string]string{"sslmode": "require", "application_name": "my app"}},
+			want: `host=db port=5432 user=app password='it\'s a \\secret' dbname=shop application_name='my app' sslmode=require`,
+		},
+		{
+			name:   "postgres empty password",
+			config: Config{Driver: "postgres", Host: "db", Port: 5432, User: "app", Database: "shop"},
+			want:   "host=db port=5432 user=app password='' dbname=shop sslmode=disable",
+		},
+		{
+			name:   "sqlite",
+			config: Config{Driver: "sqlite3", Database: "app.db", Params: map[string]string{"_busy_timeout": "5000"}},
+			want:   "app.db?_busy_timeout=5000&_foreign_keys=1",
+		},
+		{
+			name:   "sqlite with query",
+			config: Config{Driver: "sqlite3", Database: "file:app.db?mode=ro"},
+			want:   "file:app.db?mode=ro&_foreign_keys=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect, ok := GetDialect(tt.config.Driver)
+			if !ok {
+				t.Fatalf("dialect %s not registered", tt.config.Driver)
+			}
+			if got := dialect.DSN(tt.config); got != tt.want {
+				t.Errorf("DSN() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialects(t *testing.T) {
+	if got, want := Dialects(), []string{"mysql", "postgres", "sqlite3"}; !reflect.DeepEqual(got[:3], want) {
+		t.Errorf("Dialects() = %q, want %q first", got, want)
+	}
+
+	id := models.Field{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true, AutoIncrement: true}
+	name := models.Field{Name: "name", Type: reflect.TypeOf(""), NotNull: true, Unique: true, MaxLength: 50, Default: "it's"}
+	created := models.Field{Name: "created_at", Type: reflect.TypeOf(time.Time{}), Default: "now"}
+	score := models.Field{Name: "score", Type: reflect.TypeOf(0.0), Default: 1.5}
+
+	tests := []struct {
+		dialect     Dialect
+		placeholder string
+		identifier  string
+		quote       string
+		columns     []string // Definitions of id, name, created_at and score
+		upsert      string
+		doNothing   string
+		limit       string
+	}{
+		{
+			dialect:     SQLiteDialect{},
+			placeholder: "?",
+			identifier:  `a"b.c`,
+			quote:       `"a""b"."c"`,
+			columns: []string{
+				`"id" INTEGER`,
+				`"name" VARCHAR(50) NOT NULL UNIQUE DEFAULT 'it''s'`,
+				`"created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP`,
+				`"score" REAL DEFAULT 1.5`,
+			},
+			upsert:    `ON CONFLICT ("email") DO UPDATE SET "name" = excluded."name", "age" = excluded."age"`,
+			doNothing: `ON CONFLICT ("email") DO NOTHING`,
+			limit:     "LIMIT -1 OFFSET 5",
+		},
+		{
+			dialect:     MySQLDialect{},
+			placeholder: "?",
+			identifier:  "a`b.c",
+			quote:       "`a``b`.`c`",
+			columns: []string{
+				"`id` BIGINT AUTO_INCREMENT",
+				"`name` VARCHAR(50) NOT NULL UNIQUE DEFAULT 'it''s'",
+				"`created_at` DATETIME DEFAULT CURRENT_TIMESTAMP",
+				"`score` REAL DEFAULT 1.5",
+			},
+			upsert:    "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `age` = VALUES(`age`)",
+			doNothing: "ON DUPLICATE KEY UPDATE `email` = VALUES(`email`)",
+			limit:     "LIMIT 18446744073709551615 OFFSET 5",
+		},
+		{
+			dialect:     PostgresDialect{},
+			placeholder: "$3",
+			identifier:  `a"b.c`,
+			quote:       `"a""b"."c"`,
+			columns: []string{
+				`"id" BIGSERIAL`,
+				`"name" VARCHAR(50) NOT NULL UNIQUE DEFAULT 'it''s'`,
+				`"created_at" TIMESTAMP DEFAULT CURRENT_TIMESTAMP`,
+				`"score" REAL DEFAULT 1.5`,
+			},
+			upsert:    `ON CONFLICT ("email") DO UPDATE SET "name" = excluded."name", "age" = excluded."age"`,
+			doNothing: `ON CONFLICT ("email") DO NOTHING`,
+			limit:     "OFFSET 5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			o := &ORM{dialect: tt.dialect}
+			if got := o.placeholder(3); got != tt.placeholder {
+				t.Errorf("placeholder(3) = %s, want %s", got, tt.placeholder)
+			}
+			if got := o.Quote(tt.identifier); got != tt.quote {
+				t.Errorf("Quote(%s) = %s, want %s", tt.identifier, got, tt.quote)
+			}
+
+			var columns []string
+			for _, field := range []models.Field{id, name, created, score} {
+				columns = append(columns, o.ColumnDefinition(field.Name, field))
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("ColumnDefinition() =\n%q\nwant\n%q", columns, tt.columns)
+			}
+
+			if got := tt.dialect.Upsert([]string{"email"}, []string{"name", "age"}); got != tt.upsert {
+				t.Errorf("Upsert() = %s, want %s", got, tt.upsert)
+			}
+			if got := tt.dialect.Upsert([]string{"email"}, nil); got != tt.doNothing {
+				t.Errorf("Upsert() without updates = %s, want %s", got, tt.doNothing)
+			}
+			if got := tt.dialect.LimitOffset(0, 5); got != tt.limit {
+				t.Errorf("LimitOffset(0, 5) = %s, want %s", got, tt.limit)
+			}
+			if got := tt.dialect.LimitOffset(10, 0); got != "LIMIT 10" {
+				t.Errorf("LimitOffset(10, 0) = %s, want LIMIT 10", got)
+			}
+		})
+	}
+}
+
+func TestForeignKeyDefinition(t *testing.T) {
+	o := &ORM{dialect: PostgresDialect{}}
+	fk := &models.ForeignKey{Model: "users", Field: "id", OnDelete: "SET-NULL", OnUpdate: "CASCADE"}
+	want := `FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE`
+	if got := o.ForeignKeyDefinition("user_id", fk); got != want {
+		t.Errorf("ForeignKeyDefinition() = %s, want %s", got, want)
+	}
+}
+
+// upperDialect is a custom dialect that only changes identifier quoting
+type upperDialect struct{ SQLiteDialect }
+
+func (upperDialect) Name() string { return "upper" }
+
+func (upperDialect) Quote(identifier string) string { return "[" + identifier + "]" }
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect("upper", upperDialect{})
+
+	o, err := New(Config{Driver: "sqlite3", Dialect: "upper", Database: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer o.Close()
+
+	if o.Dialect().Name() != "upper" {
+		t.Errorf("Dialect() = %s, want upper", o.Dialect().Name())
+	}
+	if sql, _, _ := o.Table("users").Where("id", "=", 1).ToSQL(); sql != "SELECT * FROM [users] WHERE [id] = ?" {
+		t.Errorf("ToSQL() = %s", sql)
+	}
+
+	if _, err := New(Config{Driver: "sqlite3", Dialect: "oracle"}); err == nil {
+		t.Error("New() accepted an unknown dialect")
+	}
+}
+
+func TestIndexDefinition(t *testing.T) {
+	index := models.Index{Name: "items_order_product", Columns: []string{"order_id", "product_id"}, Unique: true}
+	partial := models.Index{Name: "items_open", Columns: []string{"order_id"}, Where: "shipped = 0"}
+
+	tests := []struct {
+		dialect Dialect
+		index   string
+		partial string // Empty if partial indexes are not supported
+	}{
+		{
+			dialect: SQLiteDialect{},
+			index:   `CREATE UNIQUE INDEX "items_order_product" ON "items" ("order_id", "product_id")`,
+			partial: `CREATE INDEX "items_open" ON "items" ("order_id") WHERE shipped = 0`,
+		},
+		{
+			dialect: MySQLDialect{},
+			index:   "CREATE UNIQUE INDEX `items_order_product` ON `items` (`order_id`, `product_id`)",
+		},
+		{
+			dialect: PostgresDialect{},
+			index:   `CREATE UNIQUE INDEX "items_order_product" ON "items" ("order_id", "product_id")`,
+			partial: `CREATE INDEX "items_open" ON "items" ("order_id") WHERE shipped = 0`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			o := &ORM{dialect: tt.dialect}
+			if got, err := o.IndexDefinition("items", index); err != nil || got != tt.index {
+				t.Errorf("IndexDefinition() = %s, %v, want %s", got, err, tt.index)
+			}
+			got, err := o.IndexDefinition("items", partial)
+			if tt.partial == "" {
+				if err == nil {
+					t.Errorf("IndexDefinition() = %s, want an error for a partial index", got)
+				}
+			} else if err != nil || got != tt.partial {
+				t.Errorf("IndexDefinition() = %s, %v, want %s", got, err, tt.partial)
+			}
+		})
+	}
+
+	o := &ORM{dialect: MySQLDialect{}}
+	if got, want := o.CheckDefinition(models.Check{Name: "positive", Expression: "quantity > 0"}), "CONSTRAINT `positive` CHECK (quantity > 0)"; got != want {
+		t.Errorf("CheckDefinition() = %s, want %s", got, want)
+	}
+}
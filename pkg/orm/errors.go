@@ -0,0 +1,88 @@
+package orm
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors matched, with errors.Is, by the errors the ORM returns when a
+// statement violates a constraint. The driver's error stays in the chain.
+var (
+	ErrUniqueViolation     = errors.New("unique constraint violation")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	ErrNotNullViolation    = errors.New("not null constraint violation")
+	ErrCheckViolation      = errors.New("check constraint violation")
+)
+
+// ConstraintError is a driver error reporting a violated constraint
+type ConstraintError struct {
+	Kind error // One of ErrUniqueViolation, ErrForeignKeyViolation, ErrNotNullViolation or ErrCheckViolation
+	Err  error // Error returned by the driver
+}
+
+// Error implements error
+func (e *ConstraintError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the kind of violation and the driver's error
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// sqlStates maps SQLSTATE codes to the violations they report
+var sqlStates = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23502": ErrNotNullViolation,
+	"23514": ErrCheckViolation,
+}
+
+// violationMessages maps fragments of driver error messages to the violations
+// they report: SQLite's, MySQL's error numbers and PostgreSQL's (lib/pq)
+var violationMessages = []struct {
+	fragment string
+	kind     error
+}{
+	{"UNIQUE constraint failed", ErrUniqueViolation},
+	{"FOREIGN KEY constraint failed", ErrForeignKeyViolation},
+	{"NOT NULL constraint failed", ErrNotNullViolation},
+	{"CHECK constraint failed", ErrCheckViolation},
+	{"Error 1062", ErrUniqueViolation},
+	{"Error 1451", ErrForeignKeyViolation},
+	{"Error 1452", ErrForeignKeyViolation},
+	{"Error 1048", ErrNotNullViolation},
+	{"Error 3819", ErrCheckViolation},
+	{"violates unique constraint", ErrUniqueViolation},
+	{"violates foreign key constraint", ErrForeignKeyViolation},
+	{"violates not-null constraint", ErrNotNullViolation},
+	{"violates check constraint", ErrCheckViolation},
+}
+
+// constraintError wraps a driver error reporting a violated constraint in a
+// ConstraintError and returns other errors unchanged
+func constraintError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return err
+	}
+
+	// Drivers such as pgx expose the SQLSTATE
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		if kind, ok := sqlStates[state.SQLState()]; ok {
+			return &ConstraintError{Kind: kind, Err: err}
+		}
+	}
+
+	message := err.Error()
+	for _, violation := range violationMessages {
+		if strings.Contains(message, violation.fragment) {
+			return &ConstraintError{Kind: violation.kind, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// sqlStateError is a driver error exposing its SQLSTATE, as pgx errors do
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "driver error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestConstraintError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error // nil when the error is returned unchanged
+	}{
+		{name: "sqlite unique", err: errors.New("UNIQUE constraint failed: users.email"), kind: ErrUniqueViolation},
+		{name: "mysql foreign key", err: errors.New("Error 1452 (23000): Cannot add or update a child row"), kind: ErrForeignKeyViolation},
+		{name: "postgres not null", err: errors.New(`pq: null value in column "name" violates not-null constraint`), kind: ErrNotNullViolation},
+		{name: "postgres check", err: errors.New(`pq: new row violates check constraint "positive_total"`), kind: ErrCheckViolation},
+		{name: "sqlstate", err: fmt.Errorf("insert: %w", sqlStateError("23505")), kind: ErrUniqueViolation},
+		{name: "other sqlstate", err: sqlStateError("42P01")},
+		{name: "other error", err: errors.New("no such table: users")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := constraintError(tt.err)
+			if tt.kind == nil {
+				if err != tt.err {
+					t.Errorf("constraintError() = %v, want the error unchanged", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("constraintError() = %v, want %v", err, tt.kind)
+			}
+			if !errors.Is(err, tt.err) || err.Error() != tt.err.Error() {
+				t.Errorf("constraintError() = %v, want the driver's error kept", err)
+			}
+		})
+	}
+
+	if constraintError(nil) != nil {
+		t.Error("constraintError(nil) != nil")
+	}
+}
+
+func TestConstraintViolations(t *testing.T) {
+	o := openTestORM(t,
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers (id), total REAL CHECK (total >= 0))",
+		"INSERT INTO customers (id, email) VALUES (1, 'a@example.com')",
+	)
+	customers := models.NewModel("customers")
+	customers.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	customers.AddField("email", reflect.TypeOf(""), models.WithNotNull(), models.WithUnique())
+	orders := models.NewModel("orders")
+	orders.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	orders.AddField("customer_id", reflect.TypeOf(0))
+	orders.AddField("total", reflect.TypeOf(0.0))
+	for _, model := range []*models.Model{customers, orders} {
+		if err := o.RegisterModel(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		kind error
+	}{
+		{
+			name: "unique",
+			call: func() error {
+				_, err := o.Create("customers", map[string]interface{}{"email": "a@example.com"})
+				return err
+			},
+			kind: ErrUniqueViolation,
+		},
+		{
+			name: "not null",
+			call: func() error {
+				_, err := o.Insert("customers", map[string]interface{}{"email": nil})
+				return err
+			},
+			kind: ErrNotNullViolation,
+		},
+		{
+			name: "foreign key",
+			call: func() error {
+				_, err := o.Create("orders", map[string]interface{}{"customer_id": 9, "total": 1.0})
+				return err
+			},
+			kind: ErrForeignKeyViolation,
+		},
+		{
+			name: "check",
+			call: func() error {
+				_, err := o.BulkCreate("orders", []map[string]interface{}{{"customer_id": 1, "total": -1.0}}, 0)
+				return err
+			},
+			kind: ErrCheckViolation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("error = %v, want %v", err, tt.kind)
+			}
+			var constraintErr *ConstraintError
+			if !errors.As(err, &constraintErr) || constraintErr.Kind != tt.kind {
+				t.Errorf("error = %#v, want a ConstraintError", err)
+			}
+		})
+	}
+}
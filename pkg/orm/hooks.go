@@ -0,0 +1,85 @@
+package orm
+
+import (
+	"context"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// beforeCreate returns a copy of data with automatic timestamps set and the
+// model's BeforeCreate hook applied
+func beforeCreate(ctx context.Context, model models.ModelInterface, data map[string]interface{}) (map[string]interface{}, error) {
+	data = copyData(data)
+	setTimestamps(model, data, true)
+	if hook, ok := model.(models.BeforeCreateHook); ok {
+		if err := hook.BeforeCreate(ctx, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// afterCreate runs the model's AfterCreate hook
+func afterCreate(ctx context.Context, model models.ModelInterface, data map[string]interface{}) error {
+	if hook, ok := model.(models.AfterCreateHook); ok {
+		return hook.AfterCreate(ctx, data)
+	}
+	return nil
+}
+
+// beforeUpdate returns a copy of data with auto_now timestamps set and the
+// model's BeforeUpdate hook applied
+func beforeUpdate(ctx context.Context, model models.ModelInterface, id interface{}, data map[string]interface{}) (map[string]interface{}, error) {
+	data = copyData(data)
+	setTimestamps(model, data, false)
+	if hook, ok := model.(models.BeforeUpdateHook); ok {
+		if err := hook.BeforeUpdate(ctx, id, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// afterUpdate runs the model's AfterUpdate hook
+func afterUpdate(ctx context.Context, model models.ModelInterface, id interface{}, data map[string]interface{}) error {
+	if hook, ok := model.(models.AfterUpdateHook); ok {
+		return hook.AfterUpdate(ctx, id, data)
+	}
+	return nil
+}
+
+// beforeDelete runs the model's BeforeDelete hook
+func beforeDelete(ctx context.Context, model models.ModelInterface, id interface{}) error {
+	if hook, ok := model.(models.BeforeDeleteHook); ok {
+		return hook.BeforeDelete(ctx, id)
+	}
+	return nil
+}
+
+// afterDelete runs the model's AfterDelete hook
+func afterDelete(ctx context.Context, model models.ModelInterface, id interface{}) error {
+	if hook, ok := model.(models.AfterDeleteHook); ok {
+		return hook.AfterDelete(ctx, id)
+	}
+	return nil
+}
+
+// setTimestamps sets auto_now fields, and auto_now_add fields when creating, to the current time
+func setTimestamps(model models.ModelInterface, data map[string]interface{}, creating bool) {
+	now := time.Now()
+	for name, field := range model.GetFields() {
+		if field.AutoNow || (creating && field.AutoNowAdd) {
+			data[name] = now
+		}
+	}
+}
+
+// copyData returns a shallow copy of data so that callers' maps are not modified
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,233 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+var errRejected = errors.New("rejected")
+
+// hookedModel records the lifecycle hooks the ORM calls
+type hookedModel struct {
+	*models.Model
+	calls []string
+	fail  string // Hook that returns errRejected
+}
+
+func (m *hookedModel) record(hook string, detail interface{}) error {
+	m.calls = append(m.calls, fmt.Sprintf("%s %v", hook, detail))
+	if m.fail == hook {
+		return errRejected
+	}
+	return nil
+}
+
+func (m *hookedModel) BeforeCreate(ctx context.Context, data map[string]interface{}) error {
+	if name, ok := data["name"].(string); ok {
+		data["name"] = strings.ToLower(name)
+	}
+	return m.record("BeforeCreate", data["name"])
+}
+
+func (m *hookedModel) AfterCreate(ctx context.Context, data map[string]interface{}) error {
+	return m.record("AfterCreate", data["id"])
+}
+
+func (m *hookedModel) BeforeUpdate(ctx context.Context, id interface{}, data map[string]interface{}) error {
+	return m.record("BeforeUpdate", id)
+}
+
+func (m *hookedModel) AfterUpdate(ctx context.Context, id interface{}, data map[string]interface{}) error {
+	return m.record("AfterUpdate", id)
+}
+
+func (m *hookedModel) BeforeDelete(ctx context.Context, id interface{}) error {
+	return m.record("BeforeDelete", id)
+}
+
+func (m *hookedModel) AfterDelete(ctx context.Context, id interface{}) error {
+	return m.record("AfterDelete", id)
+}
+
+// openHookedORM returns a test ORM with a hooked notes model that has
+// automatic timestamps
+func openHookedORM(t *testing.T) (*ORM, *hookedModel) {
+	t.Helper()
+	o := openTestORM(t)
+	model := models.NewModel("notes")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("name", reflect.TypeOf(""), models.WithNotNull())
+	model.AddField("created_at", reflect.TypeOf(time.Time{}), models.WithAutoNowAdd())
+	model.AddField("updated_at", reflect.TypeOf(time.Time{}), models.WithAutoNow())
+	hooked := &hookedModel{Model: model}
+	if err := o.RegisterModel(hooked); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	return o, hooked
+}
+
+func TestHooks(t *testing.T) {
+	o, model := openHookedORM(t)
+
+	data := map[string]interface{}{"name": "Ann"}
+	id, err := o.Create("notes", data)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if len(data) != 1 {
+		t.Errorf("Create() modified the caller's data: %v", data)
+	}
+	if _, err := o.Insert("notes", map[string]interface{}{"name": "Bob"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := o.Update("notes", id, map[string]interface{}{"name": "ann"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := o.Delete("notes", 2); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	want := []string{
+		"BeforeCreate ann", "AfterCreate 1",
+		"BeforeCreate bob", "AfterCreate 2",
+		"BeforeUpdate 1", "AfterUpdate 1",
+		"BeforeDelete 2", "AfterDelete 2",
+	}
+	if !reflect.DeepEqual(model.calls, want) {
+		t.Errorf("hooks = %q, want %q", model.calls, want)
+	}
+	if row, _ := o.Get("notes", id); row["name"] != "ann" {
+		t.Errorf("Get() = %v, want the name lowercased", row)
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	tests := []struct {
+		hook  string
+		call  func(o *ORM) error
+		names []string // Notes stored afterwards
+	}{
+		{
+			hook: "BeforeCreate",
+			call: func(o *ORM) error {
+				_, err := o.Create("notes", map[string]interface{}{"name": "b"})
+				return err
+			},
+			names: []string{"a"},
+		},
+		{
+			hook: "BeforeUpdate",
+			call: func(o *ORM) error {
+				return o.Update("notes", 1, map[string]interface{}{"name": "b"})
+			},
+			names: []string{"a"},
+		},
+		{
+			hook:  "BeforeDelete",
+			call:  func(o *ORM) error { return o.Delete("notes", 1) },
+			names: []string{"a"},
+		},
+		{
+			hook: "AfterCreate",
+			call: func(o *ORM) error {
+				return o.Transaction(context.Background(), func(tx *Tx) error {
+					_, err := tx.Create("notes", map[string]interface{}{"name": "b"})
+					return err
+				})
+			},
+			names: []string{"a"},
+		},
+		{
+			hook: "AfterDelete",
+			call: func(o *ORM) error { return o.Delete("notes", 1) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hook, func(t *testing.T) {
+			o, model := openHookedORM(t)
+			if _, err := o.Create("notes", map[string]interface{}{"name": "a"}); err != nil {
+				t.Fatal(err)
+			}
+			model.fail = tt.hook
+
+			if err := tt.call(o); !errors.Is(err, errRejected) {
+				t.Errorf("error = %v, want %v", err, errRejected)
+			}
+			rows, err := o.Table("notes").OrderBy("id").All()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, row := range rows {
+				names = append(names, row["name"].(string))
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("notes = %q, want %q", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	o, _ := openHookedORM(t)
+	timestamps := func(id interface{}) (created, updated time.Time) {
+		t.Helper()
+		row, err := o.Get("notes", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var note struct {
+			Created time.Time `db:"created_at"`
+			Updated time.Time `db:"updated_at"`
+		}
+		if err := ScanMap(row, &note); err != nil {
+			t.Fatal(err)
+		}
+		return note.Created, note.Updated
+	}
+
+	start := time.Now().Add(-time.Second)
+	id, err := o.Create("notes", map[string]interface{}{"name": "a", "created_at": time.Time{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, updated := timestamps(id)
+	if created.Before(start) || !updated.Equal(created) {
+		t.Errorf("after Create() created_at = %v, updated_at = %v", created, updated)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := o.Update("notes", id, map[string]interface{}{"name": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, u := timestamps(id); !c.Equal(created) || !u.After(updated) {
+		t.Errorf("after Update() created_at = %v, updated_at = %v", c, u)
+	}
+
+	// Bulk operations and upserts set timestamps too, and keep created_at on conflict
+	if _, err := o.BulkCreate("notes", []map[string]interface{}{{"id": 2, "name": "c"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := timestamps(2); c.Before(start) {
+		t.Errorf("after BulkCreate() created_at = %v", c)
+	}
+	created, updated = timestamps(2)
+	time.Sleep(10 * time.Millisecond)
+	if err := o.Upsert("notes", map[string]interface{}{"id": 2, "name": "d"}, []string{"id"}, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, u := timestamps(2); !c.Equal(created) || !u.After(updated) {
+		t.Errorf("after Upsert() created_at = %v, updated_at = %v", c, u)
+	}
+}
@@ -0,0 +1,327 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms are the words generated Go identifiers spell in capitals
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true, "json": true,
+	"sql": true, "uid": true, "uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// reservedFields are struct field names that would clash with generated methods
+var reservedFields = map[string]bool{
+	"TableName": true,
+}
+
+// typeLength matches the length of types such as varchar(100)
+var typeLength = regexp.MustCompile(`\((\d+)\)`)
+
+// nowDefaults are the default expressions meaning the time of insertion
+var nowDefaults = map[string]bool{
+	"current_timestamp":   true,
+	"current_timestamp()": true,
+	"now()":               true,
+	"localtimestamp":      true,
+	"datetime('now')":     true,
+}
+
+// GenerateModels returns Go source declaring a struct and a model constructor
+// for each table, e.g. the output of Inspect. Struct fields carry the `db` tags
+// understood by models.FromStruct; indexes spanning several columns become
+// AddIndex calls and single-column foreign keys on *_id columns BelongsTo
+// relations. The result is a starting point meant to be reviewed: defaults
+// that cannot be expressed in a tag are left as comments.
+func GenerateModels(packageName string, tables []*TableInfo) ([]byte, error) {
+	var body bytes.Buffer
+	usesTime := false
+
+	names := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		name := singularize(goIdentifier(table.Name))
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s%d", singularize(goIdentifier(table.Name)), n)
+		}
+		names[name] = true
+
+		primaryKeys := make(map[string]bool, len(table.PrimaryKey))
+		for _, column := range table.PrimaryKey {
+			primaryKeys[column] = true
+		}
+		foreignKeys := make(map[string]ForeignKeyInfo)
+		for _, fk := range table.ForeignKeys {
+			if len(fk.Columns) == 1 {
+				foreignKeys[fk.Columns[0]] = fk
+			}
+		}
+		// Single-column unique indexes become the unique option of the column
+		unique := make(map[string]bool)
+		var indexes []IndexInfo
+		for _, index := range table.Indexes {
+			if index.Unique && len(index.Columns) == 1 && index.Where == "" {
+				unique[index.Columns[0]] = true
+				continue
+			}
+			indexes = append(indexes, index)
+		}
+
+		fmt.Fprintf(&body, "\n// %s maps the %s table\ntype %s struct {\n", name, table.Name, name)
+		used := make(map[string]bool)
+		for _, column := range table.Columns {
+			goType, maxLength := goTypeOf(column.Type)
+			if goType == "time.Time" {
+				usesTime = true
+			}
+			if column.Nullable && !primaryKeys[column.Name] && goType != "[]byte" {
+				goType = "*" + goType
+			}
+
+			options := []string{column.Name}
+			if primaryKeys[column.Name] {
+				options = append(options, "pk")
+			}
+			if column.AutoIncrement {
+				options = append(options, "autoincrement")
+			}
+			if unique[column.Name] {
+				options = append(options, "unique")
+			}
+			if !column.Nullable && !primaryKeys[column.Name] {
+				options = append(options, "notnull")
+			}
+			comment := ""
+			if column.Default != nil {
+				if value, ok := tagDefault(*column.Default, strings.TrimPrefix(goType, "*")); ok {
+					options = append(options, "default="+value)
+				} else {
+					comment = " // default " + strings.Join(strings.Fields(*column.Default), " ")
+				}
+			}
+			if maxLength > 0 {
+				options = append(options, fmt.Sprintf("maxlen=%d", maxLength))
+			}
+			if fk, ok := foreignKeys[column.Name]; ok {
+				options = append(options, fmt.Sprintf("fk=%s.%s", fk.RefTable, fk.RefColumns[0]))
+				if action := fkAction(fk.OnDelete); action != "" {
+					options = append(options, "ondelete="+action)
+				}
+				if action := fkAction(fk.OnUpdate); action != "" {
+					options = append(options, "onupdate="+action)
+				}
+			}
+
+			field := goIdentifier(column.Name)
+			if reservedFields[field] {
+				field += "Column"
+			}
+			for n := 2; used[field]; n++ {
+				field = fmt.Sprintf("%s%d", goIdentifier(column.Name), n)
+			}
+			used[field] = true
+
+			fmt.Fprintf(&body, "\t%s %s `db:%q`%s\n", field, goType, strings.Join(options, ","), comment)
+		}
+		fmt.Fprintf(&body, "}\n")
+
+		fmt.Fprintf(&body, "\n// TableName returns the name of the %s table\n", table.Name)
+		fmt.Fprintf(&body, "func (%s) TableName() string { return %q }\n", name, table.Name)
+
+		fmt.Fprintf(&body, "\n// New%sModel returns the model for the %s table\n", name, table.Name)
+		fmt.Fprintf(&body, "func New%sModel() *models.Model {\n\tmodel := models.MustFromStruct(&%s{})\n", name, name)
+		for _, index := range indexes {
+			columns := make([]string, len(index.Columns))
+			for i, column := range index.Columns {
+				columns[i] = strconv.Quote(column)
+			}
+			var opts []string
+			if index.Unique {
+				opts = append(opts, "Unique: true")
+			}
+			if index.Where != "" {
+				opts = append(opts, "Where: "+strconv.Quote(index.Where))
+			}
+			fmt.Fprintf(&body, "\tif err := model.AddIndex(%q, []string{%s}, models.IndexOptions{%s}); err != nil {\n\t\tpanic(err)\n\t}\n",
+				index.Name, strings.Join(columns, ", "), strings.Join(opts, ", "))
+		}
+		for _, fk := range table.ForeignKeys {
+			if len(fk.Columns) != 1 {
+				fmt.Fprintf(&body, "\t// Composite foreign key %s (%s) references %s (%s)\n",
+					fk.Name, strings.Join(fk.Columns, ", "), fk.RefTable, strings.Join(fk.RefColumns, ", "))
+				continue
+			}
+			if relation := strings.TrimSuffix(fk.Columns[0], "_id"); relation != fk.Columns[0] {
+				fmt.Fprintf(&body, "\tif err := model.BelongsTo(%q, %q, %q); err != nil {\n\t\tpanic(err)\n\t}\n",
+					relation, fk.RefTable, fk.Columns[0])
+			}
+		}
+		fmt.Fprintf(&body, "\treturn model\n}\n")
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by inspectdb. Review the models before using them.\n\n")
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", packageName)
+	if usesTime {
+		fmt.Fprintf(&src, "\t\"time\"\n\n")
+	}
+	fmt.Fprintf(&src, "\t\"github.com/baxromov/framego/pkg/models\"\n)\n")
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated models: %w", err)
+	}
+	return formatted, nil
+}
+
+// goTypeOf maps a database column type to a Go type and the maximum length of
+// character types
+func goTypeOf(dbType string) (string, int) {
+	lower := strings.ToLower(strings.TrimSpace(dbType))
+	base := lower
+	if i := strings.Index(base, "("); i >= 0 {
+		base = base[:i]
+	}
+	base = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(base), "unsigned"))
+
+	switch base {
+	case "bool", "boolean":
+		return "bool", 0
+	case "tinyint":
+		if strings.HasPrefix(lower, "tinyint(1)") {
+			return "bool", 0
+		}
+		return "int", 0
+	case "bigint", "int8", "bigserial", "serial8":
+		return "int64", 0
+	case "int", "integer", "smallint", "mediumint", "int2", "int4", "serial", "serial4", "smallserial":
+		return "int", 0
+	case "real", "float", "double", "double precision", "numeric", "decimal", "float4", "float8":
+		return "float64", 0
+	case "date", "datetime", "timestamp", "timestamptz",
+		"timestamp without time zone", "timestamp with time zone":
+		return "time.Time", 0
+	case "blob", "tinyblob", "mediumblob", "longblob", "bytea", "binary", "varbinary":
+		return "[]byte", 0
+	case "varchar", "char", "character", "character varying", "nvarchar", "nchar":
+		if match := typeLength.FindStringSubmatch(lower); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			return "string", n
+		}
+		return "string", 0
+	default:
+		return "string", 0
+	}
+}
+
+// tagDefault converts a default expression to the value of a default= tag
+// option, and false when the tag cannot express it
+func tagDefault(expr, goType string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+
+	// Drop PostgreSQL casts such as 'draft'::character varying
+	if i := strings.LastIndex(expr, "::"); i > 0 && strings.HasPrefix(expr, "'") {
+		expr = expr[:i]
+	}
+
+	value := expr
+	if len(expr) >= 2 && strings.HasPrefix(expr, "'") && strings.HasSuffix(expr, "'") {
+		value = strings.Replace(expr[1:len(expr)-1], "''", "'", -1)
+	} else if strings.EqualFold(expr, "NULL") {
+		return "", false
+	}
+
+	// A comma would end the option, and a backtick the struct tag
+	if strings.ContainsAny(value, ",`") {
+		return "", false
+	}
+
+	switch goType {
+	case "time.Time":
+		if nowDefaults[strings.ToLower(value)] {
+			return "now", true
+		}
+		return "", false
+	case "bool":
+		b, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case "int", "int64":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return value, err == nil
+	case "float64":
+		_, err := strconv.ParseFloat(value, 64)
+		return value, err == nil
+	case "string":
+		// Unquoted defaults are only literals when MySQL reports them; leave
+		// function calls such as gen_random_uuid() out
+		if value == expr && strings.Contains(value, "(") {
+			return "", false
+		}
+		return value, true
+	default:
+		return "", false
+	}
+}
+
+// fkAction normalises a referential action, omitting the default NO ACTION
+func fkAction(action string) string {
+	action = strings.ToUpper(strings.TrimSpace(action))
+	if action == "" || action == "NO ACTION" {
+		return ""
+	}
+	return action
+}
+
+// goIdentifier converts a table or column name such as "user_id" to an
+// exported Go identifier such as "UserID"
+func goIdentifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var sb strings.Builder
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			sb.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+
+	identifier := sb.String()
+	if identifier == "" || unicode.IsDigit([]rune(identifier)[0]) {
+		identifier = "X" + identifier
+	}
+	return identifier
+}
+
+// singularize returns a naive English singular of an identifier, the inverse
+// of the pluralisation FromStruct applies to struct names
+func singularize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	default:
+		return name
+	}
+}
@@ -0,0 +1,494 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TableInfo describes a table as read from the database catalog
+type TableInfo struct {
+	Name        string
+	Columns     []ColumnInfo
+	PrimaryKey  []string // Primary key columns in key order
+	ForeignKeys []ForeignKeyInfo
+	Indexes     []IndexInfo // Secondary indexes, excluding the primary key
+}
+
+// ColumnInfo describes a column as read from the database catalog
+type ColumnInfo struct {
+	Name          string
+	Type          string // Type as reported by the database, e.g. "varchar(100)"
+	Nullable      bool
+	Default       *string // Default expression, nil when the column has none
+	AutoIncrement bool
+}
+
+// ForeignKeyInfo describes a foreign key constraint. Columns and RefColumns
+// have the same length; both hold more than one column for composite keys.
+type ForeignKeyInfo struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+// IndexInfo describes a secondary index
+type IndexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Where   string // Predicate of a partial index
+}
+
+// Column returns the named column and whether it exists
+func (t *TableInfo) Column(name string) (ColumnInfo, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return ColumnInfo{}, false
+}
+
+// Introspector is implemented by dialects that can read the schema of an
+// existing database. All built-in dialects implement it.
+type Introspector interface {
+	// Tables returns the names of the user tables in sorted order
+	Tables(ctx context.Context, db *sql.DB) ([]string, error)
+
+	// InspectTable reads the columns, keys and indexes of a table
+	InspectTable(ctx context.Context, db *sql.DB, table string) (*TableInfo, error)
+}
+
+// introspector returns the dialect's Introspector
+func (o *ORM) introspector() (Introspector, error) {
+	introspector, ok := o.dialect.(Introspector)
+	if !ok {
+		return nil, fmt.Errorf("dialect %s does not support introspection", o.dialect.Name())
+	}
+	return introspector, nil
+}
+
+// Tables returns the names of the tables in the database
+func (o *ORM) Tables(ctx context.Context) ([]string, error) {
+	introspector, err := o.introspector()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	return introspector.Tables(ctx, o.db)
+}
+
+// InspectTable reads the schema of a table from the database catalog
+func (o *ORM) InspectTable(ctx context.Context, table string) (*TableInfo, error) {
+	introspector, err := o.introspector()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	info, err := introspector.InspectTable(ctx, o.db, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	return info, nil
+}
+
+// Inspect reads the schema of the given tables, or of every table in the
+// database when none are given
+func (o *ORM) Inspect(ctx context.Context, tables ...string) ([]*TableInfo, error) {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = o.Tables(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	infos := make([]*TableInfo, 0, len(tables))
+	for _, table := range tables {
+		info, err := o.InspectTable(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// queryStrings runs a query returning a single string column
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// nullableString converts a scanned NullString to a pointer
+func nullableString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// appendForeignKey adds a column pair to the foreign key with the given name,
+// creating it if this is its first column
+func appendForeignKey(fks []ForeignKeyInfo, fk ForeignKeyInfo) []ForeignKeyInfo {
+	if n := len(fks); n > 0 && fks[n-1].Name == fk.Name {
+		fks[n-1].Columns = append(fks[n-1].Columns, fk.Columns...)
+		fks[n-1].RefColumns = append(fks[n-1].RefColumns, fk.RefColumns...)
+		return fks
+	}
+	return append(fks, fk)
+}
+
+// appendIndex adds a column to the index with the given name, creating it if
+// this is its first column
+func appendIndex(indexes []IndexInfo, index IndexInfo) []IndexInfo {
+	if n := len(indexes); n > 0 && indexes[n-1].Name == index.Name {
+		indexes[n-1].Columns = append(indexes[n-1].Columns, index.Columns...)
+		return indexes
+	}
+	return append(indexes, index)
+}
+
+// Tables implements Introspector
+func (SQLiteDialect) Tables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return queryStrings(ctx, db,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+}
+
+// InspectTable implements Introspector
+func (SQLiteDialect) InspectTable(ctx context.Context, db *sql.DB, table string) (*TableInfo, error) {
+	info := &TableInfo{Name: table}
+
+	rows, err := db.QueryContext(ctx, "SELECT name, type, \"notnull\", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keyOrder := make(map[int]string)
+	for rows.Next() {
+		var column ColumnInfo
+		var notNull bool
+		var def sql.NullString
+		var pk int
+		if err := rows.Scan(&column.Name, &column.Type, &notNull, &def, &pk); err != nil {
+			return nil, err
+		}
+		column.Nullable = !notNull && pk == 0
+		column.Default = nullableString(def)
+		if pk > 0 {
+			keyOrder[pk] = column.Name
+		}
+		info.Columns = append(info.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+	for i := 1; i <= len(keyOrder); i++ {
+		info.PrimaryKey = append(info.PrimaryKey, keyOrder[i])
+	}
+
+	// A single INTEGER primary key aliases the rowid and auto-increments
+	if len(info.PrimaryKey) == 1 {
+		for i, column := range info.Columns {
+			if column.Name == info.PrimaryKey[0] && strings.EqualFold(column.Type, "INTEGER") {
+				info.Columns[i].AutoIncrement = true
+			}
+		}
+	}
+
+	fkRows, err := db.QueryContext(ctx,
+		"SELECT id, \"table\", \"from\", \"to\", on_delete, on_update FROM pragma_foreign_key_list(?) ORDER BY id, seq", table)
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		var id int
+		var column, refColumn string
+		var fk ForeignKeyInfo
+		if err := fkRows.Scan(&id, &fk.RefTable, &column, &refColumn, &fk.OnDelete, &fk.OnUpdate); err != nil {
+			return nil, err
+		}
+		fk.Name = fmt.Sprintf("fk_%s_%d", table, id)
+		fk.Columns, fk.RefColumns = []string{column}, []string{refColumn}
+		info.ForeignKeys = appendForeignKey(info.ForeignKeys, fk)
+	}
+	if err := fkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Indexes backing the primary key are skipped; the WHERE clause of a
+	// partial index is only available from the CREATE INDEX statement
+	indexRows, err := db.QueryContext(ctx, `SELECT il.name, ii.name, il."unique", il.partial, COALESCE(m.sql, '')
+		FROM pragma_index_list(?) AS il
+		JOIN pragma_index_info(il.name) AS ii
+		LEFT JOIN sqlite_master AS m ON m.type = 'index' AND m.name = il.name
+		WHERE il.origin <> 'pk'
+		ORDER BY il.name, ii.seqno`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var index IndexInfo
+		var column, statement string
+		var partial bool
+		if err := indexRows.Scan(&index.Name, &column, &index.Unique, &partial, &statement); err != nil {
+			return nil, err
+		}
+		if partial {
+			if i := strings.LastIndex(strings.ToUpper(statement), " WHERE "); i >= 0 {
+				index.Where = strings.TrimSpace(statement[i+len(" WHERE "):])
+			}
+		}
+		index.Columns = []string{column}
+		info.Indexes = appendIndex(info.Indexes, index)
+	}
+	return info, indexRows.Err()
+}
+
+// Tables implements Introspector
+func (MySQLDialect) Tables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return queryStrings(ctx, db, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`)
+}
+
+// InspectTable implements Introspector
+func (MySQLDialect) InspectTable(ctx context.Context, db *sql.DB, table string) (*TableInfo, error) {
+	info := &TableInfo{Name: table}
+
+	rows, err := db.QueryContext(ctx, `SELECT column_name, column_type, is_nullable, column_default, extra
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = ?
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column ColumnInfo
+		var nullable, extra string
+		var def sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &nullable, &def, &extra); err != nil {
+			return nil, err
+		}
+		column.Nullable = nullable == "YES"
+		column.Default = nullableString(def)
+		column.AutoIncrement = strings.Contains(strings.ToLower(extra), "auto_increment")
+		info.Columns = append(info.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+
+	info.PrimaryKey, err = queryStrings(ctx, db, `SELECT column_name FROM information_schema.key_column_usage
+		WHERE table_schema = DATABASE() AND table_name = ? AND constraint_name = 'PRIMARY'
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	fkRows, err := db.QueryContext(ctx, `SELECT k.constraint_name, k.column_name, k.referenced_table_name,
+			k.referenced_column_name, r.delete_rule, r.update_rule
+		FROM information_schema.key_column_usage AS k
+		JOIN information_schema.referential_constraints AS r
+			ON r.constraint_schema = k.constraint_schema AND r.constraint_name = k.constraint_name
+		WHERE k.table_schema = DATABASE() AND k.table_name = ? AND k.referenced_table_name IS NOT NULL
+		ORDER BY k.constraint_name, k.ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	foreignKeys := make(map[string]bool)
+	for fkRows.Next() {
+		var column, refColumn string
+		var fk ForeignKeyInfo
+		if err := fkRows.Scan(&fk.Name, &column, &fk.RefTable, &refColumn, &fk.OnDelete, &fk.OnUpdate); err != nil {
+			return nil, err
+		}
+		fk.Columns, fk.RefColumns = []string{column}, []string{refColumn}
+		info.ForeignKeys = appendForeignKey(info.ForeignKeys, fk)
+		foreignKeys[fk.Name] = true
+	}
+	if err := fkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	indexRows, err := db.QueryContext(ctx, `SELECT index_name, column_name, non_unique
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = ? AND index_name <> 'PRIMARY'
+		ORDER BY index_name, seq_in_index`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var index IndexInfo
+		var column string
+		var nonUnique bool
+		if err := indexRows.Scan(&index.Name, &column, &nonUnique); err != nil {
+			return nil, err
+		}
+		// MySQL creates an index named after each foreign key constraint
+		if nonUnique && foreignKeys[index.Name] {
+			continue
+		}
+		index.Columns, index.Unique = []string{column}, !nonUnique
+		info.Indexes = appendIndex(info.Indexes, index)
+	}
+	return info, indexRows.Err()
+}
+
+// postgresActions maps the action codes of pg_constraint to their SQL names
+var postgresActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+// Tables implements Introspector
+func (PostgresDialect) Tables(ctx context.Context, db *sql.DB) ([]string, error) {
+	return queryStrings(ctx, db, `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name`)
+}
+
+// InspectTable implements Introspector
+func (PostgresDialect) InspectTable(ctx context.Context, db *sql.DB, table string) (*TableInfo, error) {
+	info := &TableInfo{Name: table}
+
+	rows, err := db.QueryContext(ctx, `SELECT column_name, data_type, character_maximum_length,
+			is_nullable, column_default, is_identity
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column ColumnInfo
+		var length sql.NullInt64
+		var nullable, identity string
+		var def sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &length, &nullable, &def, &identity); err != nil {
+			return nil, err
+		}
+		if length.Valid {
+			column.Type = fmt.Sprintf("%s(%d)", column.Type, length.Int64)
+		}
+		column.Nullable = nullable == "YES"
+		// Serial columns default to the next value of their sequence
+		if (def.Valid && strings.HasPrefix(def.String, "nextval(")) || identity == "YES" {
+			column.AutoIncrement = true
+		} else {
+			column.Default = nullableString(def)
+		}
+		info.Columns = append(info.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+
+	info.PrimaryKey, err = queryStrings(ctx, db, `SELECT kcu.column_name
+		FROM information_schema.table_constraints AS tc
+		JOIN information_schema.key_column_usage AS kcu
+			ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type = 'PRIMARY KEY'
+		ORDER BY kcu.ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	fkRows, err := db.QueryContext(ctx, `SELECT c.conname, a.attname, rt.relname, ra.attname, c.confdeltype, c.confupdtype
+		FROM pg_constraint AS c
+		JOIN pg_class AS t ON t.oid = c.conrelid
+		JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		JOIN pg_class AS rt ON rt.oid = c.confrelid
+		CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord)
+		JOIN pg_attribute AS a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		JOIN pg_attribute AS ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refattnum
+		WHERE c.contype = 'f' AND n.nspname = current_schema() AND t.relname = $1
+		ORDER BY c.conname, k.ord`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer fkRows.Close()
+
+	for fkRows.Next() {
+		var column, refColumn, onDelete, onUpdate string
+		var fk ForeignKeyInfo
+		if err := fkRows.Scan(&fk.Name, &column, &fk.RefTable, &refColumn, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fk.Columns, fk.RefColumns = []string{column}, []string{refColumn}
+		fk.OnDelete, fk.OnUpdate = postgresActions[onDelete], postgresActions[onUpdate]
+		info.ForeignKeys = appendForeignKey(info.ForeignKeys, fk)
+	}
+	if err := fkRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Expression indexes have no attribute for their expressions and are skipped
+	indexRows, err := db.QueryContext(ctx, `SELECT i.relname, a.attname, ix.indisunique,
+			COALESCE(pg_get_expr(ix.indpred, ix.indrelid), '')
+		FROM pg_index AS ix
+		JOIN pg_class AS t ON t.oid = ix.indrelid
+		JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		JOIN pg_class AS i ON i.oid = ix.indexrelid
+		CROSS JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute AS a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = current_schema() AND t.relname = $1 AND NOT ix.indisprimary
+		ORDER BY i.relname, k.ord`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer indexRows.Close()
+
+	for indexRows.Next() {
+		var index IndexInfo
+		var column string
+		if err := indexRows.Scan(&index.Name, &column, &index.Unique, &index.Where); err != nil {
+			return nil, err
+		}
+		index.Columns = []string{column}
+		info.Indexes = appendIndex(info.Indexes, index)
+	}
+	return info, indexRows.Err()
+}
@@ -0,0 +1,246 @@
+package orm
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openInspectORM returns a test ORM with customers and orders tables
+func openInspectORM(t *testing.T) *ORM {
+	t.Helper()
+	return openTestORM(t,
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, email VARCHAR(200) NOT NULL UNIQUE)",
+		`CREATE TABLE orders (
+			id INTEGER PRIMARY KEY,
+			customer_id INTEGER NOT NULL REFERENCES customers (id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL DEFAULT 'draft',
+			total REAL,
+			placed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		"CREATE INDEX idx_orders_status_total ON orders (status, total)",
+		"CREATE UNIQUE INDEX idx_orders_open ON orders (customer_id) WHERE status = 'open'",
+	)
+}
+
+func TestInspect(t *testing.T) {
+	o := openInspectORM(t)
+	ctx := context.Background()
+
+	tables, err := o.Tables(ctx)
+	if err != nil {
+		t.Fatalf("Tables() error = %v", err)
+	}
+	if want := []string{"customers", "orders"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("Tables() = %q, want %q", tables, want)
+	}
+
+	info, err := o.InspectTable(ctx, "orders")
+	if err != nil {
+		t.Fatalf("InspectTable() error = %v", err)
+	}
+	if want := []string{"id"}; !reflect.DeepEqual(info.PrimaryKey, want) {
+		t.Errorf("PrimaryKey = %q, want %q", info.PrimaryKey, want)
+	}
+
+	tests := []struct {
+		name     string
+		typ      string
+		nullable bool
+		def      string
+		auto     bool
+	}{
+		{name: "id", typ: "INTEGER", auto: true},
+		{name: "customer_id", typ: "INTEGER"},
+		{name: "status", typ: "VARCHAR(20)", def: "'draft'"},
+		{name: "total", typ: "REAL", nullable: true},
+		{name: "placed_at", typ: "DATETIME", nullable: true, def: "CURRENT_TIMESTAMP"},
+	}
+	if len(info.Columns) != len(tests) {
+		t.Fatalf("%d columns, want %d", len(info.Columns), len(tests))
+	}
+	for i, tt := range tests {
+		column := info.Columns[i]
+		def := ""
+		if column.Default != nil {
+			def = *column.Default
+		}
+		if column.Name != tt.name || column.Type != tt.typ || column.Nullable != tt.nullable ||
+			def != tt.def || column.AutoIncrement != tt.auto {
+			t.Errorf("column %d = %+v (default %q), want %+v", i, column, def, tt)
+		}
+	}
+	if _, ok := info.Column("status"); !ok {
+		t.Error("Column() did not find status")
+	}
+	if _, ok := info.Column("price"); ok {
+		t.Error("Column() found an unknown column")
+	}
+
+	wantFKs := []ForeignKeyInfo{{
+		Name: "fk_orders_0", Columns: []string{"customer_id"}, RefTable: "customers",
+		RefColumns: []string{"id"}, OnDelete: "CASCADE", OnUpdate: "NO ACTION",
+	}}
+	if !reflect.DeepEqual(info.ForeignKeys, wantFKs) {
+		t.Errorf("ForeignKeys = %+v, want %+v", info.ForeignKeys, wantFKs)
+	}
+	wantIndexes := []IndexInfo{
+		{Name: "idx_orders_open", Columns: []string{"customer_id"}, Unique: true, Where: "status = 'open'"},
+		{Name: "idx_orders_status_total", Columns: []string{"status", "total"}},
+	}
+	if !reflect.DeepEqual(info.Indexes, wantIndexes) {
+		t.Errorf("Indexes = %+v, want %+v", info.Indexes, wantIndexes)
+	}
+
+	infos, err := o.Inspect(ctx)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "customers" || len(infos[0].Indexes) != 1 || !infos[0].Indexes[0].Unique {
+		t.Errorf("Inspect() = %+v", infos)
+	}
+
+	if _, err := o.InspectTable(ctx, "payments"); err == nil {
+		t.Error("InspectTable() accepted an unknown table")
+	}
+}
+
+func TestGenerateModels(t *testing.T) {
+	o := openInspectORM(t)
+	infos, err := o.Inspect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := GenerateModels("shop", infos)
+	if err != nil {
+		t.Fatalf("GenerateModels() error = %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"package shop",
+		`"time"`,
+		"type Customer struct {",
+		"Email string `db:\"email,unique,notnull,maxlen=200\"`",
+		"type Order struct {",
+		"ID int `db:\"id,pk,autoincrement\"`",
+		"CustomerID int `db:\"customer_id,notnull,fk=customers.id,ondelete=CASCADE\"`",
+		"Status string `db:\"status,notnull,default=draft,maxlen=20\"`",
+		"Total *float64 `db:\"total\"`",
+		"PlacedAt *time.Time `db:\"placed_at,default=now\"`",
+		`func (Order) TableName() string { return "orders" }`,
+		`model.AddIndex("idx_orders_status_total", []string{"status", "total"}, models.IndexOptions{})`,
+		`model.AddIndex("idx_orders_open", []string{"customer_id"}, models.IndexOptions{Unique: true, Where: "status = 'open'"})`,
+		`model.BelongsTo("customer", "customers", "customer_id")`,
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(string(src)), " "), strings.Join(strings.Fields(want), " ")) {
+			t.Errorf("generated source does not contain %s\n%s", want, src)
+		}
+	}
+}
+
+func TestGoTypeOf(t *testing.T) {
+	tests := []struct {
+		dbType    string
+		goType    string
+		maxLength int
+	}{
+		{dbType: "INTEGER", goType: "int"},
+		{dbType: "bigint unsigned", goType: "int64"},
+		{dbType: "tinyint(1)", goType: "bool"},
+		{dbType: "tinyint(4)", goType: "int"},
+		{dbType: "boolean", goType: "bool"},
+		{dbType: "double precision", goType: "float64"},
+		{dbType: "numeric(10,2)", goType: "float64"},
+		{dbType: "timestamp with time zone", goType: "time.Time"},
+		{dbType: "bytea", goType: "[]byte"},
+		{dbType: "varchar(100)", goType: "string", maxLength: 100},
+		{dbType: "character varying", goType: "string"},
+		{dbType: "jsonb", goType: "string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			goType, maxLength := goTypeOf(tt.dbType)
+			if goType != tt.goType || maxLength != tt.maxLength {
+				t.Errorf("goTypeOf() = %s, %d, want %s, %d", goType, maxLength, tt.goType, tt.maxLength)
+			}
+		})
+	}
+}
+
+func TestTagDefault(t *testing.T) {
+	tests := []struct {
+		expr   string
+		goType string
+		value  string
+		ok     bool
+	}{
+		{expr: "'draft'", goType: "string", value: "draft", ok: true},
+		{expr: "'draft'::character varying", goType: "string", value: "draft", ok: true},
+		{expr: "'it''s'", goType: "string", value: "it's", ok: true},
+		{expr: "'a,b'", goType: "string"},
+		{expr: "gen_random_uuid()", goType: "string"},
+		{expr: "(0)", goType: "int", value: "0", ok: true},
+		{expr: "1.5", goType: "float64", value: "1.5", ok: true},
+		{expr: "'x'", goType: "int"},
+		{expr: "true", goType: "bool", value: "true", ok: true},
+		{expr: "CURRENT_TIMESTAMP", goType: "time.Time", value: "now", ok: true},
+		{expr: "now()", goType: "time.Time", value: "now", ok: true},
+		{expr: "'2020-01-01'", goType: "time.Time"},
+		{expr: "NULL", goType: "string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			value, ok := tagDefault(tt.expr, tt.goType)
+			if ok != tt.ok || (ok && value != tt.value) {
+				t.Errorf("tagDefault() = %q, %v, want %q, %v", value, ok, tt.value, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "user_id", want: "UserID"},
+		{name: "api_url", want: "APIURL"},
+		{name: "order-items", want: "OrderItems"},
+		{name: "2fa", want: "X2fa"},
+	}
+
+	for _, tt := range tests {
+		if got := goIdentifier(tt.name); got != tt.want {
+			t.Errorf("goIdentifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSingularize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Orders", want: "Order"},
+		{name: "Categories", want: "Category"},
+		{name: "Boxes", want: "Box"},
+		{name: "Addresses", want: "Address"},
+		{name: "Access", want: "Access"},
+		{name: "Person", want: "Person"},
+	}
+
+	for _, tt := range tests {
+		if got := singularize(tt.name); got != tt.want {
+			t.Errorf("singularize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
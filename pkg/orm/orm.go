@@ -1,87 +1,333 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"reflect"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/baxromov/framego/pkg/models"
 )
 
+// ErrNoRows is returned when a query expected a row but found none
+var ErrNoRows = sql.ErrNoRows
+
+// DefaultDatabase is the name of the database configured directly on the ORM
+const DefaultDatabase = "default"
+
 // ORM represents the object-relational mapper
 type ORM struct {
-	db        *sql.DB
-	driver    string
-	models    map[string]models.ModelInterface
-	connected bool
+	db           *sql.DB
+	driver       string
+	dialect      Dialect
+	models       map[string]models.ModelInterface
+	connected    bool
+	queryTimeout time.Duration
+	replicas     []*replica
+	next         atomic.Uint32 // Round-robin position among the replicas
+	stop         chan struct{}
+	databases    map[string]*ORM
+	routes       map[string]*ORM // Tables of models registered in a named database
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // Config represents the configuration for the ORM
 type Config struct {
-	Driver   string
+	Driver string
+
+	// Dialect names the registered dialect used to generate SQL; it defaults to Driver
+	Dialect string
+
 	Host     string
 	Port     int
 	User     string
 	Password string
 	Database string
+
+	// QueryTimeout bounds every query that runs without an earlier deadline; zero means no limit
+	QueryTimeout time.Duration
+
+	// Connection pool settings; zero values keep the database/sql defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Params are extra driver parameters appended to the DSN, e.g. sslmode,
+	// tls, charset, or _busy_timeout and _journal_mode for SQLite
+	Params map[string]string
+
+	// DSN is used verbatim instead of building one from the fields above
+	DSN string
+
+	// Replicas are read-only copies of the database. Get, Query and query sets
+	// read from a healthy replica in round-robin order, falling back to the
+	// primary; writes and transactions always use the primary. Fields a
+	// replica leaves empty, except DSN, are taken from this configuration.
+	Replicas []Config
+
+	// HealthCheckInterval is how often replicas are pinged; it defaults to 30 seconds
+	HealthCheckInterval time.Duration
+
+	// Databases are additional named databases, e.g. an analytics database.
+	// Models placed in one with models.WithDatabase are read and written there.
+	Databases map[string]Config
 }
 
 // New creates a new ORM instance
 func New(config Config) (*ORM, error) {
-	dsn := buildDSN(config)
-	db, err := sql.Open(config.Driver, dsn)
+	dialectName := config.Dialect
+	if dialectName == "" {
+		dialectName = config.Driver
+	}
+	dialect, ok := GetDialect(dialectName)
+	if !ok {
+		return nil, fmt.Errorf("unsupported dialect %q", dialectName)
+	}
+
+	db, err := open(config, dialect)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Enable foreign keys for SQLite3
-	if config.Driver == "sqlite3" {
-		if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-			return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	if err := dialect.Setup(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	o := &ORM{
+		db:           db,
+		driver:       config.Driver,
+		dialect:      dialect,
+		models:       make(map[string]models.ModelInterface),
+		connected:    true,
+		queryTimeout: config.QueryTimeout,
+		stop:         make(chan struct{}),
+		databases:    make(map[string]*ORM),
+		routes:       make(map[string]*ORM),
+	}
+
+	// Unreachable replicas are skipped until a health check succeeds
+	for i, replicaConf := range config.Replicas {
+		replicaDB, err := open(replicaConfig(config, replicaConf), dialect)
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		r := &replica{db: replicaDB}
+		r.check(context.Background())
+		o.replicas = append(o.replicas, r)
+	}
+	if len(o.replicas) > 0 {
+		interval := config.HealthCheckInterval
+		if interval <= 0 {
+			interval = defaultHealthCheckInterval
+		}
+		go o.monitorReplicas(interval)
+	}
+
+	for _, name := range sortedKeys(config.Databases) {
+		if name == DefaultDatabase {
+			o.Close()
+			return nil, fmt.Errorf("database name %q is reserved", name)
+		}
+		database, err := New(config.Databases[name])
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("database %s: %w", name, err)
 		}
+		o.databases[name] = database
+	}
+
+	return o, nil
+}
+
+// open opens a connection pool with the pool settings of the configuration
+func open(config Config, dialect Dialect) (*sql.DB, error) {
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = dialect.DSN(config)
+	}
+	db, err := sql.Open(config.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Configure the connection pool
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
 	}
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	return db, nil
+}
+
+// encodeParams encodes parameters as a URL query string in key order
+func encodeParams(params map[string]string) string {
+	var parts []string
+	for _, key := range sortedKeys(params) {
+		parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(params[key]))
+	}
+	return strings.Join(parts, "&")
+}
+
+// quotePostgresValue quotes a value for a PostgreSQL key=value connection string when needed
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `'`, `\'`, -1)
+	return "'" + value + "'"
+}
 
-	return &ORM{
-		db:        db,
-		driver:    config.Driver,
-		models:    make(map[string]models.ModelInterface),
-		connected: true,
-	}, nil
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Stats returns the connection pool statistics
+func (o *ORM) Stats() sql.DBStats {
+	return o.db.Stats()
+}
+
+// placeholder returns the bind parameter placeholder for the n-th argument (1-based)
+func (o *ORM) placeholder(n int) string {
+	return o.dialect.Placeholder(n)
 }
 
-// buildDSN builds the data source name for the database connection
-func buildDSN(config Config) string {
-	switch config.Driver {
-	case "mysql":
-		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-			config.User, config.Password, config.Host, config.Port, config.Database)
-	case "postgres":
-		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			config.Host, config.Port, config.User, config.Password, config.Database)
-	case "sqlite3":
-		return config.Database
-	default:
-		return ""
+// Quote quotes a table or column name, quoting each part of a qualified "table.column" name
+func (o *ORM) Quote(identifier string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = o.dialect.Quote(part)
 	}
+	return strings.Join(parts, ".")
 }
 
-// Close closes the database connection
+// withTimeout applies the default query timeout to ctx unless it already has an earlier deadline
+func (o *ORM) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= o.queryTimeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.queryTimeout)
+}
+
+// Close closes the database connection, its replicas and the named databases
 func (o *ORM) Close() error {
+	if o.stop != nil {
+		select {
+		case <-o.stop:
+		default:
+			close(o.stop)
+		}
+	}
+	for _, r := range o.replicas {
+		r.db.Close()
+	}
+	for _, database := range o.databases {
+		database.Close()
+	}
 	if o.db != nil {
 		return o.db.Close()
 	}
 	return nil
 }
 
-// RegisterModel registers a model with the ORM
+// DB returns the underlying handle of the primary database
+func (o *ORM) DB() *sql.DB {
+	return o.db
+}
+
+// Database returns the ORM of a named database configured in Config.Databases;
+// DefaultDatabase returns o itself
+func (o *ORM) Database(name string) (*ORM, bool) {
+	if name == DefaultDatabase {
+		return o, true
+	}
+	database, ok := o.databases[name]
+	return database, ok
+}
+
+// using returns the ORM of the database the table's model is registered in
+func (o *ORM) using(tableName string) *ORM {
+	if database, ok := o.routes[tableName]; ok {
+		return database
+	}
+	return o
+}
+
+// Driver returns the name of the database driver
+func (o *ORM) Driver() string {
+	return o.driver
+}
+
+// Dialect returns the SQL dialect of the database
+func (o *ORM) Dialect() Dialect {
+	return o.dialect
+}
+
+// Models returns the models registered in this database keyed by table name.
+// Models of a named database are returned by that database's ORM.
+func (o *ORM) Models() map[string]models.ModelInterface {
+	return o.models
+}
+
+// RegisterModel registers a model with the ORM. A model declaring a named
+// database is registered with that database instead.
 func (o *ORM) RegisterModel(model models.ModelInterface) error {
+	if provider, ok := model.(models.DatabaseProvider); ok {
+		if name := provider.GetDatabase(); name != "" && name != DefaultDatabase {
+			database, ok := o.databases[name]
+			if !ok {
+				return fmt.Errorf("model %s uses unknown database %q", model.GetTableName(), name)
+			}
+			if err := database.registerModel(model); err != nil {
+				return err
+			}
+			o.routes[model.GetTableName()] = database
+			return nil
+		}
+	}
+
+	return o.registerModel(model)
+}
+
+// registerModel validates a model and registers it with this database
+func (o *ORM) registerModel(model models.ModelInterface) error {
 	if err := model.Validate(); err != nil {
 		return err
 	}
@@ -90,340 +336,617 @@ func (o *ORM) RegisterModel(model models.ModelInterface) error {
 	return nil
 }
 
-// CreateTables creates tables for all registered models
+// CreateTables creates tables for all registered models, including those of named databases
 func (o *ORM) CreateTables() error {
 	for _, model := range o.models {
 		if err := o.createTable(model); err != nil {
 			return err
 		}
 	}
+	for _, name := range sortedKeys(o.databases) {
+		if err := o.databases[name].CreateTables(); err != nil {
+			return fmt.Errorf("database %s: %w", name, err)
+		}
+	}
 	return nil
 }
 
-// createTable creates a table for the given model
+// createTable creates a table for the given model, together with its indexes.
+// Existing tables are left unchanged.
 func (o *ORM) createTable(model models.ModelInterface) error {
 	tableName := model.GetTableName()
 	fields := model.GetFields()
 
+	// Probe for the table, since not every database supports CREATE INDEX IF NOT EXISTS
+	if _, err := o.db.Exec(fmt.Sprintf("SELECT 1 FROM %s WHERE 1 = 0", o.Quote(tableName))); err == nil {
+		return nil
+	}
+
 	var columns []string
 	var primaryKeys []string
 	var foreignKeys []string
 
 	for name, field := range fields {
-		column := fmt.Sprintf("%s %s", name, getSQLType(field, o.driver))
+		columns = append(columns, o.ColumnDefinition(name, field))
 
-		if field.NotNull {
-			column += " NOT NULL"
+		if field.PrimaryKey {
+			primaryKeys = append(primaryKeys, o.Quote(name))
 		}
 
-		if field.Unique {
-			column += " UNIQUE"
+		if field.ForeignKey != nil {
+			foreignKeys = append(foreignKeys, o.ForeignKeyDefinition(name, field.ForeignKey))
 		}
+	}
 
-		if field.Default != nil {
-			// Handle default values for different types
-			switch field.Type.Kind() {
-			case reflect.String:
-				column += fmt.Sprintf(" DEFAULT '%v'", field.Default)
-			default:
-				// Handle time.Time specially for SQLite3
-				if field.Type == reflect.TypeOf(time.Time{}) && o.driver == "sqlite3" {
-					column += " DEFAULT CURRENT_TIMESTAMP"
-				} else {
-					column += fmt.Sprintf(" DEFAULT %v", field.Default)
-				}
-			}
+	if len(primaryKeys) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
+	}
+
+	columns = append(columns, foreignKeys...)
+
+	var indexes []models.Index
+	if provider, ok := model.(models.ConstraintProvider); ok {
+		for _, check := range provider.GetChecks() {
+			columns = append(columns, o.CheckDefinition(check))
 		}
+		indexes = provider.GetIndexes()
+	}
 
-		if field.PrimaryKey {
-			primaryKeys = append(primaryKeys, name)
+	statements := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		o.Quote(tableName), strings.Join(columns, ", "))}
+
+	for _, index := range indexes {
+		statement, err := o.IndexDefinition(tableName, index)
+		if err != nil {
+			return err
 		}
+		statements = append(statements, statement)
+	}
 
-		if field.AutoIncrement && o.driver == "mysql" {
-			column += " AUTO_INCREMENT"
+	// Create the table and its indexes together where DDL is transactional
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
 		}
+	}
+	return tx.Commit()
+}
 
-		columns = append(columns, column)
+// IndexDefinition returns the CREATE INDEX statement for an index on the given table
+func (o *ORM) IndexDefinition(tableName string, index models.Index) (string, error) {
+	columns := make([]string, len(index.Columns))
+	for i, column := range index.Columns {
+		columns[i] = o.Quote(column)
+	}
 
-		if field.ForeignKey != nil {
-			fk := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)",
-				name, field.ForeignKey.Model, field.ForeignKey.Field)
-
-			if field.ForeignKey.OnDelete != "" {
-				// Handle SQLite3 specific syntax for ON DELETE
-				if o.driver == "sqlite3" {
-					fk += fmt.Sprintf(" ON DELETE %s", strings.Replace(field.ForeignKey.OnDelete, "-", " ", -1))
-				} else {
-					fk += fmt.Sprintf(" ON DELETE %s", field.ForeignKey.OnDelete)
-				}
-			}
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
 
-			if field.ForeignKey.OnUpdate != "" {
-				// Handle SQLite3 specific syntax for ON UPDATE
-				if o.driver == "sqlite3" {
-					fk += fmt.Sprintf(" ON UPDATE %s", strings.Replace(field.ForeignKey.OnUpdate, "-", " ", -1))
-				} else {
-					fk += fmt.Sprintf(" ON UPDATE %s", field.ForeignKey.OnUpdate)
-				}
-			}
+	statement := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)",
+		unique, o.Quote(index.Name), o.Quote(tableName), strings.Join(columns, ", "))
 
-			foreignKeys = append(foreignKeys, fk)
+	if index.Where != "" {
+		if !o.dialect.SupportsPartialIndexes() {
+			return "", fmt.Errorf("index %s: %s does not support partial indexes", index.Name, o.dialect.Name())
 		}
+		statement += " WHERE " + index.Where
 	}
 
-	if len(primaryKeys) > 0 {
-		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
-	}
-
-	columns = append(columns, foreignKeys...)
+	return statement, nil
+}
 
-	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
-		tableName, strings.Join(columns, ", "))
+// CheckDefinition returns the CHECK table constraint for the given check
+func (o *ORM) CheckDefinition(check models.Check) string {
+	return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", o.Quote(check.Name), check.Expression)
+}
 
-	_, err := o.db.Exec(query)
-	return err
+// ColumnDefinition returns the column definition used in CREATE TABLE for the given field.
+// Primary keys and foreign keys are table constraints and are not included.
+func (o *ORM) ColumnDefinition(name string, field models.Field) string {
+	return columnDefinition(o.dialect, name, field)
 }
 
-// getSQLType returns the SQL type for the given field
-func getSQLType(field models.Field, driver string) string {
-	switch field.Type.Kind() {
-	case reflect.Bool:
-		return "BOOLEAN"
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
-		return "INTEGER"
-	case reflect.Int64, reflect.Uint64:
-		return "BIGINT"
-	case reflect.Float32, reflect.Float64:
-		return "REAL"
-	case reflect.String:
-		if field.MaxLength > 0 {
-			return fmt.Sprintf("VARCHAR(%d)", field.MaxLength)
-		}
-		return "TEXT"
-	default:
-		if field.Type == reflect.TypeOf(time.Time{}) {
-			if driver == "mysql" {
-				return "DATETIME"
+// columnDefinition renders the column definition of a field in the given dialect
+func columnDefinition(d Dialect, name string, field models.Field) string {
+	column := fmt.Sprintf("%s %s", d.Quote(name), d.SQLType(field))
+
+	if field.NotNull {
+		column += " NOT NULL"
+	}
+
+	if field.Unique {
+		column += " UNIQUE"
+	}
+
+	if field.Default != nil {
+		// Handle default values for different types
+		switch field.Type.Kind() {
+		case reflect.String:
+			column += fmt.Sprintf(" DEFAULT '%s'", strings.Replace(fmt.Sprint(field.Default), "'", "''", -1))
+		default:
+			// Timestamps default to the time of insertion
+			if field.Type == reflect.TypeOf(time.Time{}) {
+				column += " DEFAULT CURRENT_TIMESTAMP"
+			} else {
+				column += fmt.Sprintf(" DEFAULT %v", field.Default)
 			}
-			return "TIMESTAMP"
 		}
-		return "TEXT"
 	}
+
+	if field.AutoIncrement {
+		if clause := d.AutoIncrement(field); clause != "" {
+			column += " " + clause
+		}
+	}
+
+	return column
+}
+
+// ForeignKeyDefinition returns the FOREIGN KEY table constraint for the given column
+func (o *ORM) ForeignKeyDefinition(column string, foreignKey *models.ForeignKey) string {
+	fk := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s)",
+		o.Quote(column), o.Quote(foreignKey.Model), o.Quote(foreignKey.Field))
+
+	// Actions may be written with dashes, e.g. SET-NULL
+	if foreignKey.OnDelete != "" {
+		fk += fmt.Sprintf(" ON DELETE %s", strings.Replace(foreignKey.OnDelete, "-", " ", -1))
+	}
+
+	if foreignKey.OnUpdate != "" {
+		fk += fmt.Sprintf(" ON UPDATE %s", strings.Replace(foreignKey.OnUpdate, "-", " ", -1))
+	}
+
+	return fk
 }
 
-// Create inserts a new record into the database
+// SQLType returns the column type used for the given field
+func (o *ORM) SQLType(field models.Field) string {
+	return o.dialect.SQLType(field)
+}
+
+// Create inserts a new record into the database and returns its integer primary key.
+// Use Insert for tables whose primary key is not an integer.
 func (o *ORM) Create(tableName string, data map[string]interface{}) (int64, error) {
+	db := o.using(tableName)
+	return db.create(context.Background(), db.db, tableName, data)
+}
+
+// CreateContext inserts a new record into the database using the given context
+func (o *ORM) CreateContext(ctx context.Context, tableName string, data map[string]interface{}) (int64, error) {
+	db := o.using(tableName)
+	return db.create(ctx, db.db, tableName, data)
+}
+
+// create inserts a new record using the given executor
+func (o *ORM) create(ctx context.Context, ex executor, tableName string, data map[string]interface{}) (int64, error) {
 	model, ok := o.models[tableName]
 	if !ok {
 		return 0, fmt.Errorf("model %s not registered", tableName)
 	}
 
-	fields := model.GetFields()
-	var columns []string
-	var placeholders []string
-	var values []interface{}
+	primaryKeys := primaryKeysOf(model)
+	if len(primaryKeys) != 1 || !isInteger(model.GetFields()[primaryKeys[0]]) {
+		return 0, fmt.Errorf("model %s does not have an integer primary key, use Insert instead", tableName)
+	}
 
-	i := 1
-	for column, value := range data {
-		if _, ok := fields[column]; !ok {
-			return 0, fmt.Errorf("field %s not found in model %s", column, tableName)
-		}
+	data, err := beforeCreate(ctx, model, data)
+	if err != nil {
+		return 0, err
+	}
+
+	query, values, err := o.insertSQL(model, data)
+	if err != nil {
+		return 0, err
+	}
 
-		columns = append(columns, column)
+	execCtx, cancel := o.withTimeout(ctx)
+	defer cancel()
 
-		if o.driver == "postgres" {
-			placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-		} else {
-			placeholders = append(placeholders, "?")
+	var id int64
+	if o.dialect.SupportsReturning() {
+		query += " RETURNING " + o.Quote(primaryKeys[0])
+		if err := ex.QueryRowContext(execCtx, query, values...).Scan(&id); err != nil {
+			return 0, constraintError(err)
 		}
+	} else {
+		result, err := ex.ExecContext(execCtx, query, values...)
+		if err != nil {
+			return 0, constraintError(err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
 
-		values = append(values, value)
-		i++
+	data[primaryKeys[0]] = id
+	if err := afterCreate(ctx, model, data); err != nil {
+		return id, err
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
-		tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	return id, nil
+}
 
-	result, err := o.db.Exec(query, values...)
-	if err != nil {
-		return 0, err
-	}
+// Insert inserts a new record into the database and returns the stored row,
+// including generated keys and column defaults
+func (o *ORM) Insert(tableName string, data map[string]interface{}) (map[string]interface{}, error) {
+	db := o.using(tableName)
+	return db.insert(context.Background(), db.db, tableName, data)
+}
 
-	return result.LastInsertId()
+// InsertContext inserts a new record using the given context and returns the stored row
+func (o *ORM) InsertContext(ctx context.Context, tableName string, data map[string]interface{}) (map[string]interface{}, error) {
+	db := o.using(tableName)
+	return db.insert(ctx, db.db, tableName, data)
 }
 
-// Get retrieves a record from the database
-func (o *ORM) Get(tableName string, id interface{}) (map[string]interface{}, error) {
+// insert inserts a new record using the given executor and returns the stored row
+func (o *ORM) insert(ctx context.Context, ex executor, tableName string, data map[string]interface{}) (map[string]interface{}, error) {
 	model, ok := o.models[tableName]
 	if !ok {
 		return nil, fmt.Errorf("model %s not registered", tableName)
 	}
 
-	fields := model.GetFields()
-	var primaryKey string
-	for name, field := range fields {
-		if field.PrimaryKey {
-			primaryKey = name
-			break
-		}
+	data, err := beforeCreate(ctx, model, data)
+	if err != nil {
+		return nil, err
 	}
 
-	if primaryKey == "" {
-		return nil, fmt.Errorf("model %s has no primary key", tableName)
+	row, err := o.insertRow(ctx, ex, model, data)
+	if err != nil {
+		return nil, err
 	}
 
-	var placeholder string
-	if o.driver == "postgres" {
-		placeholder = "$1"
-	} else {
-		placeholder = "?"
+	if err := afterCreate(ctx, model, row); err != nil {
+		return row, err
 	}
 
-	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = %s", tableName, primaryKey, placeholder)
+	return row, nil
+}
 
-	row := o.db.QueryRow(query, id)
+// insertRow inserts data and reads back the stored row
+func (o *ORM) insertRow(ctx context.Context, ex executor, model models.ModelInterface, data map[string]interface{}) (map[string]interface{}, error) {
+	tableName := model.GetTableName()
 
-	columns, err := getColumns(tableName, o.db)
+	query, values, err := o.insertSQL(model, data)
 	if err != nil {
 		return nil, err
 	}
 
-	values := make([]interface{}, len(columns))
-	valuePtrs := make([]interface{}, len(columns))
+	if o.dialect.SupportsReturning() {
+		rows, err := o.query(ctx, ex, query+" RETURNING *", values...)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, ErrNoRows
+		}
+		return rows[0], nil
+	}
 
-	for i := range columns {
-		valuePtrs[i] = &values[i]
+	execCtx, cancel := o.withTimeout(ctx)
+	defer cancel()
+
+	result, err := ex.ExecContext(execCtx, query, values...)
+	if err != nil {
+		return nil, constraintError(err)
 	}
 
-	if err := row.Scan(valuePtrs...); err != nil {
-		return nil, err
+	// Read the row back by the primary key that was given or generated
+	primaryKeys := primaryKeysOf(model)
+	key := make(map[string]interface{}, len(primaryKeys))
+	for _, name := range primaryKeys {
+		if value, ok := data[name]; ok {
+			key[name] = value
+			continue
+		}
+		if len(primaryKeys) > 1 {
+			return nil, fmt.Errorf("value for primary key %s.%s is required", tableName, name)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		key[name] = id
 	}
 
-	result := make(map[string]interface{})
-	for i, column := range columns {
-		result[column] = values[i]
+	if len(primaryKeys) == 1 {
+		return o.get(ctx, ex, tableName, key[primaryKeys[0]])
+	}
+	return o.get(ctx, ex, tableName, key)
+}
+
+// insertSQL builds the INSERT statement for a record
+func (o *ORM) insertSQL(model models.ModelInterface, data map[string]interface{}) (string, []interface{}, error) {
+	tableName := model.GetTableName()
+	fields := model.GetFields()
+	var columns []string
+	var placeholders []string
+	var values []interface{}
+
+	i := 1
+	for column, value := range data {
+		if _, ok := fields[column]; !ok {
+			return "", nil, fmt.Errorf("field %s not found in model %s", column, tableName)
+		}
+
+		columns = append(columns, o.Quote(column))
+		placeholders = append(placeholders, o.placeholder(i))
+		values = append(values, value)
+		i++
 	}
 
-	return result, nil
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		o.Quote(tableName), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	return query, values, nil
 }
 
-// getColumns returns the column names for the given table
-func getColumns(tableName string, db *sql.DB) ([]string, error) {
-	query := fmt.Sprintf("SELECT * FROM %s LIMIT 1", tableName)
+// isInteger reports whether a field holds an integer
+func isInteger(field models.Field) bool {
+	switch field.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// primaryKeysOf returns the primary key columns of a model in sorted order
+func primaryKeysOf(model models.ModelInterface) []string {
+	var primaryKeys []string
+	for name, field := range model.GetFields() {
+		if field.PrimaryKey {
+			primaryKeys = append(primaryKeys, name)
+		}
+	}
+	sort.Strings(primaryKeys)
+	return primaryKeys
+}
 
-	rows, err := db.Query(query)
+// primaryKeyCondition renders the WHERE condition matching a record by primary key.
+// Composite keys are given as a map from column to value. Placeholders are
+// numbered from start.
+func (o *ORM) primaryKeyCondition(model models.ModelInterface, id interface{}, start int) (string, []interface{}, error) {
+	tableName := model.GetTableName()
+	primaryKeys := primaryKeysOf(model)
+	if len(primaryKeys) == 0 {
+		return "", nil, fmt.Errorf("model %s has no primary key", tableName)
+	}
+
+	key, composite := id.(map[string]interface{})
+	if len(primaryKeys) > 1 && !composite {
+		return "", nil, fmt.Errorf("model %s has a composite primary key (%s); pass the key as a map",
+			tableName, strings.Join(primaryKeys, ", "))
+	}
+
+	var conditions []string
+	var values []interface{}
+	for i, name := range primaryKeys {
+		value := id
+		if composite {
+			var ok bool
+			if value, ok = key[name]; !ok {
+				return "", nil, fmt.Errorf("missing value for primary key %s.%s", tableName, name)
+			}
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = %s", o.Quote(name), o.placeholder(start+i)))
+		values = append(values, value)
+	}
+
+	return strings.Join(conditions, " AND "), values, nil
+}
+
+// Get retrieves a record from the database by primary key.
+// Records with a composite primary key are identified by a map from column to value.
+func (o *ORM) Get(tableName string, id interface{}) (map[string]interface{}, error) {
+	db := o.using(tableName)
+	return db.get(context.Background(), db.reader(), tableName, id)
+}
+
+// GetContext retrieves a record from the database using the given context
+func (o *ORM) GetContext(ctx context.Context, tableName string, id interface{}) (map[string]interface{}, error) {
+	db := o.using(tableName)
+	return db.get(ctx, db.reader(), tableName, id)
+}
+
+// get retrieves a record using the given executor
+func (o *ORM) get(ctx context.Context, ex executor, tableName string, id interface{}) (map[string]interface{}, error) {
+	model, ok := o.models[tableName]
+	if !ok {
+		return nil, fmt.Errorf("model %s not registered", tableName)
+	}
+
+	where, args, err := o.primaryKeyCondition(model, id, 1)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s%s", o.Quote(tableName), where, o.notTrashed(model))
+
+	results, err := o.query(ctx, ex, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return columns, nil
+	if len(results) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return results[0], nil
 }
 
-// Update updates a record in the database
+// Update updates a record in the database. It returns ErrNoRows if no record
+// has the id.
 func (o *ORM) Update(tableName string, id interface{}, data map[string]interface{}) error {
+	db := o.using(tableName)
+	return db.update(context.Background(), db.db, tableName, id, data)
+}
+
+// UpdateContext updates a record in the database using the given context
+func (o *ORM) UpdateContext(ctx context.Context, tableName string, id interface{}, data map[string]interface{}) error {
+	db := o.using(tableName)
+	return db.update(ctx, db.db, tableName, id, data)
+}
+
+// update updates a record using the given executor
+func (o *ORM) update(ctx context.Context, ex executor, tableName string, id interface{}, data map[string]interface{}) error {
 	model, ok := o.models[tableName]
 	if !ok {
 		return fmt.Errorf("model %s not registered", tableName)
 	}
 
-	fields := model.GetFields()
-	var primaryKey string
-	for name, field := range fields {
-		if field.PrimaryKey {
-			primaryKey = name
-			break
-		}
+	data, err := beforeUpdate(ctx, model, id, data)
+	if err != nil {
+		return err
 	}
 
-	if primaryKey == "" {
-		return fmt.Errorf("model %s has no primary key", tableName)
-	}
+	fields := model.GetFields()
 
 	var setStatements []string
 	var values []interface{}
 
 	i := 1
 	for column, value := range data {
-		if _, ok := fields[column]; !ok {
+		field, ok := fields[column]
+		if !ok {
 			return fmt.Errorf("field %s not found in model %s", column, tableName)
 		}
 
-		if column == primaryKey {
+		if field.PrimaryKey {
 			continue
 		}
 
-		var placeholder string
-		if o.driver == "postgres" {
-			placeholder = fmt.Sprintf("$%d", i)
-		} else {
-			placeholder = "?"
-		}
-
-		setStatements = append(setStatements, fmt.Sprintf("%s = %s", column, placeholder))
+		setStatements = append(setStatements, fmt.Sprintf("%s = %s", o.Quote(column), o.placeholder(i)))
 		values = append(values, value)
 		i++
 	}
 
-	var placeholder string
-	if o.driver == "postgres" {
-		placeholder = fmt.Sprintf("$%d", i)
-	} else {
-		placeholder = "?"
+	if len(setStatements) == 0 {
+		return nil
 	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s",
-		tableName, strings.Join(setStatements, ", "), primaryKey, placeholder)
+	where, args, err := o.primaryKeyCondition(model, id, i)
+	if err != nil {
+		return err
+	}
 
-	values = append(values, id)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s%s",
+		o.Quote(tableName), strings.Join(setStatements, ", "), where, o.notTrashed(model))
 
-	_, err := o.db.Exec(query, values...)
-	return err
+	values = append(values, args...)
+
+	n, err := o.exec(ctx, ex, query, values...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRows
+	}
+
+	return afterUpdate(ctx, model, id, data)
 }
 
-// Delete deletes a record from the database
+// Delete deletes a record from the database.
+// Records of soft-deleted models are marked as deleted instead of being removed.
+// It returns ErrNoRows if no record has the id.
 func (o *ORM) Delete(tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.delete(context.Background(), db.db, tableName, id)
+}
+
+// DeleteContext deletes a record from the database using the given context
+func (o *ORM) DeleteContext(ctx context.Context, tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.delete(ctx, db.db, tableName, id)
+}
+
+// delete deletes a record using the given executor
+func (o *ORM) delete(ctx context.Context, ex executor, tableName string, id interface{}) error {
+	return o.deleteRecord(ctx, ex, tableName, id, false)
+}
+
+// deleteRecord deletes a record, or soft-deletes it unless force is set
+func (o *ORM) deleteRecord(ctx context.Context, ex executor, tableName string, id interface{}, force bool) error {
 	model, ok := o.models[tableName]
 	if !ok {
 		return fmt.Errorf("model %s not registered", tableName)
 	}
 
-	fields := model.GetFields()
-	var primaryKey string
-	for name, field := range fields {
-		if field.PrimaryKey {
-			primaryKey = name
-			break
-		}
+	column, soft := models.SoftDeleteField(model)
+	soft = soft && !force
+
+	// A soft delete binds the deletion time before the primary key
+	start := 1
+	if soft {
+		start = 2
+	}
+
+	where, args, err := o.primaryKeyCondition(model, id, start)
+	if err != nil {
+		return err
 	}
 
-	if primaryKey == "" {
-		return fmt.Errorf("model %s has no primary key", tableName)
+	if err := beforeDelete(ctx, model, id); err != nil {
+		return err
 	}
 
-	var placeholder string
-	if o.driver == "postgres" {
-		placeholder = "$1"
-	} else {
-		placeholder = "?"
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", o.Quote(tableName), where)
+	if soft {
+		query = fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s%s",
+			o.Quote(tableName), o.Quote(column), o.placeholder(1), where, o.notTrashed(model))
+		args = append([]interface{}{time.Now()}, args...)
 	}
 
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", tableName, primaryKey, placeholder)
+	n, err := o.exec(ctx, ex, query, args...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRows
+	}
 
-	_, err := o.db.Exec(query, id)
-	return err
+	return afterDelete(ctx, model, id)
 }
 
-// Query executes a custom query and returns the results
+// Query executes a custom query and returns the results. Custom queries may
+// write or lock rows, so they run on the primary; use QueryReplica for reads
+// that may be served by a replica.
 func (o *ORM) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := o.db.Query(query, args...)
+	return o.query(context.Background(), o.db, query, args...)
+}
+
+// QueryContext executes a custom query on the primary using the given context
+func (o *ORM) QueryContext(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return o.query(ctx, o.db, query, args...)
+}
+
+// QueryReplica executes a custom read-only query on a replica when replicas
+// are configured, and on the primary otherwise
+func (o *ORM) QueryReplica(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return o.query(context.Background(), o.reader(), query, args...)
+}
+
+// QueryReplicaContext executes a custom read-only query on a replica using the
+// given context; a context from WithPrimary sends it to the primary
+func (o *ORM) QueryReplicaContext(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return o.query(ctx, o.reader(), query, args...)
+}
+
+// query executes a custom query using the given executor
+func (o *ORM) query(ctx context.Context, ex executor, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := ex.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, constraintError(err)
 	}
 	defer rows.Close()
 
@@ -455,7 +978,7 @@ func (o *ORM) Query(query string, args ...interface{}) ([]map[string]interface{}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, constraintError(err)
 	}
 
 	return results, nil
@@ -0,0 +1,364 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+func TestContextMethods(t *testing.T) {
+	o := openAccountsORM(t)
+	ctx := context.Background()
+
+	id, err := o.CreateContext(ctx, "accounts", map[string]interface{}{"name": "a"})
+	if err != nil {
+		t.Fatalf("CreateContext() error = %v", err)
+	}
+	if err := o.UpdateContext(ctx, "accounts", id, map[string]interface{}{"name": "b"}); err != nil {
+		t.Fatalf("UpdateContext() error = %v", err)
+	}
+	if row, err := o.GetContext(ctx, "accounts", id); err != nil || row["name"] != "b" {
+		t.Fatalf("GetContext() = %v, %v", row, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls := map[string]func() error{
+		"CreateContext": func() error {
+			_, err := o.CreateContext(canceled, "accounts", map[string]interface{}{"name": "c"})
+			return err
+		},
+		"GetContext": func() error {
+			_, err := o.GetContext(canceled, "accounts", id)
+			return err
+		},
+		"UpdateContext": func() error {
+			return o.UpdateContext(canceled, "accounts", id, map[string]interface{}{"name": "c"})
+		},
+		"DeleteContext": func() error { return o.DeleteContext(canceled, "accounts", id) },
+		"QueryContext": func() error {
+			_, err := o.QueryContext(canceled, "SELECT * FROM accounts")
+			return err
+		},
+		"All": func() error {
+			_, err := o.Table("accounts").WithContext(canceled).All()
+			return err
+		},
+		"Count": func() error {
+			_, err := o.Table("accounts").WithContext(canceled).Count()
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s() error = %v, want context.Canceled", name, err)
+		}
+	}
+
+	if err := o.DeleteContext(ctx, "accounts", id); err != nil {
+		t.Fatalf("DeleteContext() error = %v", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	soon, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	later, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     context.Context
+		want    time.Duration // Expected time left, zero for no deadline
+	}{
+		{name: "no timeout", ctx: context.Background()},
+		{name: "default timeout", timeout: time.Minute, ctx: context.Background(), want: time.Minute},
+		{name: "earlier deadline kept", timeout: time.Minute, ctx: soon, want: time.Second},
+		{name: "later deadline shortened", timeout: time.Minute, ctx: later, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ORM{queryTimeout: tt.timeout}
+			ctx, cancel := o.withTimeout(tt.ctx)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if tt.want == 0 {
+				if ok {
+					t.Errorf("deadline = %v, want none", deadline)
+				}
+				return
+			}
+			if left := time.Until(deadline); !ok || left > tt.want || left < tt.want-10*time.Second {
+				t.Errorf("time left = %v, want about %v", left, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	o := openTestORM(t)
+	o.queryTimeout = time.Millisecond
+
+	// A recursive query that runs far longer than the timeout
+	_, err := o.Query("WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT COUNT(*) FROM n")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Query() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	o, err := New(Config{
+		Driver:          "sqlite3",
+		Database:        filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns:    3,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer o.Close()
+
+	if got := o.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+
+	// Every pooled connection enforces foreign keys
+	rows, err := o.Query("PRAGMA foreign_keys")
+	if err != nil || len(rows) != 1 || rows[0]["foreign_keys"] != int64(1) {
+		t.Errorf("PRAGMA foreign_keys = %v, %v", rows, err)
+	}
+
+	if _, err := New(Config{Driver: "sqlite3", DSN: filepath.Join(t.TempDir(), "missing", "test.db")}); err == nil {
+		t.Error("New() connected to a database in a missing directory")
+	}
+}
+
+// noReturningDialect is SQLite without INSERT ... RETURNING, to cover the
+// fallback used by MySQL
+type noReturningDialect struct{ SQLiteDialect }
+
+func (noReturningDialect) SupportsReturning() bool { return false }
+
+// openKeysORM returns a test ORM with a tokens table keyed by a UUID string
+// and a memberships table with a composite primary key
+func openKeysORM(t *testing.T) *ORM {
+	t.Helper()
+	o := openTestORM(t,
+		"CREATE TABLE tokens (id TEXT PRIMARY KEY, name TEXT NOT NULL, uses INTEGER DEFAULT 0)",
+		"CREATE TABLE memberships (user_id INTEGER, group_id INTEGER, role TEXT, PRIMARY KEY (user_id, group_id))",
+	)
+	tokens := models.NewModel("tokens")
+	tokens.AddField("id", reflect.TypeOf(""), models.WithPrimaryKey())
+	tokens.AddField("name", reflect.TypeOf(""), models.WithNotNull())
+	tokens.AddField("uses", reflect.TypeOf(0))
+	memberships := models.NewModel("memberships")
+	memberships.AddField("user_id", reflect.TypeOf(0), models.WithPrimaryKey())
+	memberships.AddField("group_id", reflect.TypeOf(0), models.WithPrimaryKey())
+	memberships.AddField("role", reflect.TypeOf(""))
+	for _, model := range []*models.Model{tokens, memberships} {
+		if err := o.RegisterModel(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return o
+}
+
+func TestInsert(t *testing.T) {
+	for _, dialect := range []Dialect{SQLiteDialect{}, noReturningDialect{}} {
+		t.Run(fmt.Sprintf("returning=%v", dialect.SupportsReturning()), func(t *testing.T) {
+			o := openKeysORM(t)
+			o.dialect = dialect
+			accounts := models.NewModel("accounts")
+			accounts.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+			accounts.AddField("name", reflect.TypeOf(""), models.WithNotNull())
+			if err := o.RegisterModel(accounts); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.CreateTables(); err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := o.Create("accounts", map[string]interface{}{"name": "a"})
+			if err != nil || id != 1 {
+				t.Fatalf("Create() = %d, %v, want 1", id, err)
+			}
+
+			account, err := o.Insert("accounts", map[string]interface{}{"name": "b"})
+			if err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+			if account["id"] != int64(2) || account["name"] != "b" {
+				t.Errorf("Insert() = %v, want id 2", account)
+			}
+
+			// The row comes back with the column default
+			token, err := o.Insert("tokens", map[string]interface{}{"id": "6f1c0e9e-2d1f-4a55-9a8e-0d0c1b2a3f4e", "name": "api"})
+			if err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+			if token["uses"] != int64(0) {
+				t.Errorf("Insert() = %v, want uses 0", token)
+			}
+			if _, err := o.Create("tokens", map[string]interface{}{"id": "x", "name": "web"}); err == nil {
+				t.Error("Create() accepted a table with a string primary key")
+			}
+
+			member, err := o.Insert("memberships", map[string]interface{}{"user_id": 1, "group_id": 2, "role": "admin"})
+			if err != nil || member["role"] != "admin" {
+				t.Errorf("Insert() = %v, %v", member, err)
+			}
+			if _, err := o.Insert("tokens", map[string]interface{}{"id": "y", "secret": "z"}); err == nil {
+				t.Error("Insert() accepted an unknown field")
+			}
+		})
+	}
+}
+
+func TestMissingRecord(t *testing.T) {
+	o := openAccountsORM(t)
+	if _, err := o.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{name: "update", call: func() error { return o.Update("accounts", 9, map[string]interface{}{"name": "b"}) }, want: ErrNoRows},
+		{name: "delete", call: func() error { return o.Delete("accounts", 9) }, want: ErrNoRows},
+		{name: "update with current values", call: func() error { return o.Update("accounts", 1, map[string]interface{}{"name": "a"}) }},
+		{
+			name: "update in transaction",
+			call: func() error {
+				return o.Transaction(context.Background(), func(tx *Tx) error {
+					return tx.Update("accounts", 9, map[string]interface{}{"name": "b"})
+				})
+			},
+			want: ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	o := openKeysORM(t)
+	key := map[string]interface{}{"user_id": 1, "group_id": 2}
+
+	if _, err := o.Insert("memberships", map[string]interface{}{"user_id": 1, "group_id": 2, "role": "member"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Insert("memberships", map[string]interface{}{"user_id": 1, "group_id": 3, "role": "member"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Update("memberships", key, map[string]interface{}{"role": "admin", "user_id": 9}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	row, err := o.Get("memberships", key)
+	if err != nil || row["role"] != "admin" {
+		t.Fatalf("Get() = %v, %v, want role admin", row, err)
+	}
+	other, err := o.Get("memberships", map[string]interface{}{"user_id": 1, "group_id": 3})
+	if err != nil || other["role"] != "member" {
+		t.Errorf("Get() = %v, %v, want the other membership unchanged", other, err)
+	}
+
+	if err := o.Delete("memberships", key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := o.Get("memberships", key); err != ErrNoRows {
+		t.Errorf("Get() after Delete() error = %v, want ErrNoRows", err)
+	}
+
+	if _, err := o.Get("memberships", 1); err == nil {
+		t.Error("Get() accepted a single value for a composite key")
+	}
+	if _, err := o.Get("memberships", map[string]interface{}{"user_id": 1}); err == nil {
+		t.Error("Get() accepted a partial composite key")
+	}
+}
+
+func TestPrimaryKeyCondition(t *testing.T) {
+	o := &ORM{dialect: PostgresDialect{}}
+	o.models = openKeysORM(t).models
+
+	where, args, err := o.primaryKeyCondition(o.models["memberships"], map[string]interface{}{"user_id": 1, "group_id": 2}, 3)
+	if err != nil {
+		t.Fatalf("primaryKeyCondition() error = %v", err)
+	}
+	if want := `"group_id" = $3 AND "user_id" = $4`; where != want {
+		t.Errorf("primaryKeyCondition() = %s, want %s", where, want)
+	}
+	if want := []interface{}{2, 1}; !reflect.DeepEqual(args, want) {
+		t.Errorf("primaryKeyCondition() args = %v, want %v", args, want)
+	}
+
+	if _, _, err := o.primaryKeyCondition(models.NewModel("logs"), 1, 1); err == nil {
+		t.Error("primaryKeyCondition() accepted a model without a primary key")
+	}
+}
+
+func TestCreateTablesConstraints(t *testing.T) {
+	o := openTestORM(t)
+	model := models.NewModel("items")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("order_id", reflect.TypeOf(0), models.WithNotNull())
+	model.AddField("product_id", reflect.TypeOf(0), models.WithNotNull())
+	model.AddField("quantity", reflect.TypeOf(0), models.WithNotNull())
+	model.AddField("shipped", reflect.TypeOf(false))
+	if err := model.AddIndex("items_order_product", []string{"order_id", "product_id"}, models.IndexOptions{Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.AddIndex("items_open", []string{"order_id"}, models.IndexOptions{Where: "shipped = 0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.AddCheck("items_quantity_positive", "quantity > 0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating the tables again leaves them unchanged
+	for i := 0; i < 2; i++ {
+		if err := o.CreateTables(); err != nil {
+			t.Fatalf("CreateTables() error = %v", err)
+		}
+	}
+
+	rows, err := o.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'items' AND sql IS NOT NULL ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["name"] != "items_open" || rows[1]["name"] != "items_order_product" {
+		t.Errorf("indexes = %v", rows)
+	}
+
+	if _, err := o.Create("items", map[string]interface{}{"order_id": 1, "product_id": 1, "quantity": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Create("items", map[string]interface{}{"order_id": 1, "product_id": 1, "quantity": 3}); err == nil {
+		t.Error("Create() accepted a duplicate order and product")
+	}
+	if _, err := o.Create("items", map[string]interface{}{"order_id": 1, "product_id": 2, "quantity": 0}); err == nil {
+		t.Error("Create() accepted a quantity that fails the check")
+	}
+}
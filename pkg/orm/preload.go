@@ -0,0 +1,191 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// preloadBatchSize limits the number of keys in a single IN clause
+const preloadBatchSize = 500
+
+// Preload loads the named relations of every row returned by the query.
+// Nested relations are given as dotted paths, e.g. "items.product".
+// Each relation is loaded with one query per batch of keys rather than one per row.
+func (q *QuerySet) Preload(relations ...string) *QuerySet {
+	q.preload = append(q.preload, relations...)
+	return q
+}
+
+// preloadRelations attaches the named relations to rows of the given table
+func (o *ORM) preloadRelations(ctx context.Context, ex executor, tableName string, rows []map[string]interface{}, paths []string) error {
+	if len(rows) == 0 || len(paths) == 0 {
+		return nil
+	}
+
+	model, ok := o.models[tableName]
+	if !ok {
+		return fmt.Errorf("model %s not registered", tableName)
+	}
+
+	var relations map[string]models.Relation
+	if provider, ok := model.(models.RelationProvider); ok {
+		relations = provider.GetRelations()
+	}
+
+	// Group nested paths by their first segment, keeping the declared order
+	var names []string
+	nested := make(map[string][]string)
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if _, seen := nested[parts[0]]; !seen {
+			names = append(names, parts[0])
+			nested[parts[0]] = nil
+		}
+		if len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		}
+	}
+
+	for _, name := range names {
+		relation, ok := relations[name]
+		if !ok {
+			return fmt.Errorf("model %s has no relation %s", tableName, name)
+		}
+
+		related, err := o.loadRelation(ctx, ex, relation, rows)
+		if err != nil {
+			return fmt.Errorf("failed to preload %s.%s: %w", tableName, name, err)
+		}
+
+		if err := o.preloadRelations(ctx, ex, relation.Model, related, nested[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadRelation fetches the related rows of a relation, attaches them to rows
+// under the relation name and returns every related row that was loaded
+func (o *ORM) loadRelation(ctx context.Context, ex executor, relation models.Relation, rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	switch relation.Type {
+	case models.BelongsTo:
+		related, err := o.fetchIn(ctx, ex, relation.Model, relation.References, collectKeys(rows, relation.ForeignKey))
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string]map[string]interface{}, len(related))
+		for _, r := range related {
+			byKey[keyOf(r[relation.References])] = r
+		}
+		for _, row := range rows {
+			if r, ok := byKey[keyOf(row[relation.ForeignKey])]; ok && row[relation.ForeignKey] != nil {
+				row[relation.Name] = r
+			} else {
+				row[relation.Name] = nil
+			}
+		}
+		return related, nil
+
+	case models.HasMany:
+		related, err := o.fetchIn(ctx, ex, relation.Model, relation.ForeignKey, collectKeys(rows, relation.References))
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string][]map[string]interface{})
+		for _, r := range related {
+			key := keyOf(r[relation.ForeignKey])
+			byKey[key] = append(byKey[key], r)
+		}
+		for _, row := range rows {
+			children := byKey[keyOf(row[relation.References])]
+			if children == nil {
+				children = []map[string]interface{}{}
+			}
+			row[relation.Name] = children
+		}
+		return related, nil
+
+	case models.ManyToMany:
+		links, err := o.fetchIn(ctx, ex, relation.JoinTable, relation.ForeignKey, collectKeys(rows, relation.References))
+		if err != nil {
+			return nil, err
+		}
+		related, err := o.fetchIn(ctx, ex, relation.Model, relation.References, collectKeys(links, relation.JoinForeignKey))
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string]map[string]interface{}, len(related))
+		for _, r := range related {
+			byKey[keyOf(r[relation.References])] = r
+		}
+		linked := make(map[string][]map[string]interface{})
+		for _, link := range links {
+			if r, ok := byKey[keyOf(link[relation.JoinForeignKey])]; ok {
+				key := keyOf(link[relation.ForeignKey])
+				linked[key] = append(linked[key], r)
+			}
+		}
+		for _, row := range rows {
+			children := linked[keyOf(row[relation.References])]
+			if children == nil {
+				children = []map[string]interface{}{}
+			}
+			row[relation.Name] = children
+		}
+		return related, nil
+	}
+
+	return nil, fmt.Errorf("unknown relation type %q", relation.Type)
+}
+
+// fetchIn returns the rows of a table whose column matches any of the keys,
+// querying in batches of preloadBatchSize
+func (o *ORM) fetchIn(ctx context.Context, ex executor, tableName, column string, keys []interface{}) ([]map[string]interface{}, error) {
+	var results []map[string]interface{}
+	for start := 0; start < len(keys); start += preloadBatchSize {
+		end := start + preloadBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch, err := o.table(ctx, ex, tableName).WhereIn(column, keys[start:end]).All()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+// collectKeys returns the distinct non-NULL values of a column
+func collectKeys(rows []map[string]interface{}, column string) []interface{} {
+	seen := make(map[string]bool)
+	var keys []interface{}
+	for _, row := range rows {
+		value := row[column]
+		if value == nil {
+			continue
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		key := keyOf(value)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, value)
+		}
+	}
+	return keys
+}
+
+// keyOf returns a comparable representation of a key value, so that keys
+// returned as int64 and as []byte by different drivers still match
+func keyOf(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
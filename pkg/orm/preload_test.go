@@ -0,0 +1,182 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// openShopORM returns a test ORM with customers, orders, items, products and
+// tags, and the relations between them
+func openShopORM(t *testing.T) *ORM {
+	t.Helper()
+	o := openTestORM(t,
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER)",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, order_id INTEGER, product_id INTEGER)",
+		"CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE product_tags (product_id INTEGER, tag_id INTEGER)",
+		"INSERT INTO customers (id, name) VALUES (1, 'Ann'), (2, 'Bob')",
+		"INSERT INTO orders (id, customer_id) VALUES (1, 1), (2, 1), (3, NULL)",
+		"INSERT INTO items (id, order_id, product_id) VALUES (1, 1, 1), (2, 1, 2), (3, 2, 1)",
+		"INSERT INTO products (id, name) VALUES (1, 'Pen'), (2, 'Ink')",
+		"INSERT INTO tags (id, name) VALUES (1, 'office'), (2, 'sale')",
+		"INSERT INTO product_tags (product_id, tag_id) VALUES (1, 1), (1, 2), (2, 1)",
+	)
+
+	model := func(name string, relate func(m *models.Model) error) {
+		m := models.NewModel(name)
+		m.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey())
+		if relate != nil {
+			if err := relate(m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := o.RegisterModel(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	model("customers", func(m *models.Model) error { return m.HasMany("orders", "orders", "customer_id") })
+	model("orders", func(m *models.Model) error {
+		if err := m.BelongsTo("customer", "customers", "customer_id"); err != nil {
+			return err
+		}
+		return m.HasMany("items", "items", "order_id")
+	})
+	model("items", func(m *models.Model) error { return m.BelongsTo("product", "products", "product_id") })
+	model("products", func(m *models.Model) error {
+		return m.ManyToMany("tags", "tags", "product_tags", "product_id", "tag_id")
+	})
+	model("tags", nil)
+	return o
+}
+
+// names returns the name column of each row
+func names(rows interface{}) []string {
+	var result []string
+	for _, row := range rows.([]map[string]interface{}) {
+		result = append(result, row["name"].(string))
+	}
+	return result
+}
+
+func TestPreload(t *testing.T) {
+	o := openShopORM(t)
+
+	orders, err := o.Table("orders").OrderBy("id").Preload("customer", "items.product.tags").All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("All() returned %d orders, want 3", len(orders))
+	}
+
+	// Belongs to, with a NULL foreign key on the last order
+	if customer, ok := orders[0]["customer"].(map[string]interface{}); !ok || customer["name"] != "Ann" {
+		t.Errorf("orders[0].customer = %v, want Ann", orders[0]["customer"])
+	}
+	if customer, ok := orders[2]["customer"]; !ok || customer != nil {
+		t.Errorf("orders[2].customer = %v, want nil", customer)
+	}
+
+	// Has many, with an empty list for the order without items
+	items := orders[0]["items"].([]map[string]interface{})
+	if len(items) != 2 || items[0]["product"].(map[string]interface{})["name"] != "Pen" {
+		t.Errorf("orders[0].items = %v", items)
+	}
+	if items := orders[2]["items"].([]map[string]interface{}); len(items) != 0 {
+		t.Errorf("orders[2].items = %v, want none", items)
+	}
+
+	// Many to many, two levels down
+	product := items[0]["product"].(map[string]interface{})
+	if got, want := names(product["tags"]), []string{"office", "sale"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("product tags = %q, want %q", got, want)
+	}
+
+	customers, err := o.Table("customers").OrderBy("id").Preload("orders").All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if n := len(customers[0]["orders"].([]map[string]interface{})); n != 2 {
+		t.Errorf("Ann has %d orders, want 2", n)
+	}
+	if n := len(customers[1]["orders"].([]map[string]interface{})); n != 0 {
+		t.Errorf("Bob has %d orders, want 0", n)
+	}
+}
+
+func TestPreloadErrors(t *testing.T) {
+	o := openShopORM(t)
+
+	for _, path := range []string{"owner", "items.owner"} {
+		_, err := o.Table("orders").Preload(path).All()
+		if err == nil || !strings.Contains(err.Error(), "has no relation owner") {
+			t.Errorf("Preload(%q) error = %v, want an unknown relation", path, err)
+		}
+	}
+
+	// Nothing to preload when no rows match
+	rows, err := o.Table("orders").Where("id", "=", 99).Preload("owner").All()
+	if err != nil || len(rows) != 0 {
+		t.Errorf("All() = %v, %v for no rows", rows, err)
+	}
+}
+
+func TestPreloadScan(t *testing.T) {
+	type product struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+	type item struct {
+		ID      int64    `db:"id"`
+		Product *product `db:"product"`
+	}
+	type order struct {
+		ID    int64  `db:"id"`
+		Items []item `db:"items"`
+	}
+
+	o := openShopORM(t)
+	orders, err := AllAs[order](o.Table("orders").OrderBy("id").Preload("items.product"))
+	if err != nil {
+		t.Fatalf("AllAs() error = %v", err)
+	}
+	want := []order{
+		{ID: 1, Items: []item{{ID: 1, Product: &product{1, "Pen"}}, {ID: 2, Product: &product{2, "Ink"}}}},
+		{ID: 2, Items: []item{{ID: 3, Product: &product{1, "Pen"}}}},
+		{ID: 3, Items: []item{}},
+	}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("AllAs() = %+v, want %+v", orders, want)
+	}
+}
+
+func TestPreloadBatches(t *testing.T) {
+	o := openShopORM(t)
+	last := 10 + preloadBatchSize
+	for id := 10; id <= last; id++ {
+		if _, err := o.db.Exec("INSERT INTO orders (id) VALUES (?)", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := o.db.Exec("INSERT INTO items (id, order_id) VALUES (4, ?)", last); err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys of the last order fall in the second batch
+	orders, err := o.Table("orders").OrderBy("id").Preload("items").All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if items := orders[len(orders)-1]["items"].([]map[string]interface{}); len(items) != 1 {
+		t.Errorf("items of order %d = %v, want one", last, items)
+	}
+
+	if keys := collectKeys([]map[string]interface{}{{"id": int64(1)}, {"id": []byte("1")}, {"id": nil}}, "id"); len(keys) != 1 {
+		t.Errorf("collectKeys() = %v, want one key", keys)
+	}
+}
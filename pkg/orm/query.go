@@ -0,0 +1,456 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// identifierPattern matches column and table names accepted by the query builder
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// operators lists the comparison operators accepted by Where and OrWhere
+var operators = map[string]bool{
+	"=":           true,
+	"!=":          true,
+	"<>":          true,
+	"<":           true,
+	"<=":          true,
+	">":           true,
+	">=":          true,
+	"LIKE":        true,
+	"NOT LIKE":    true,
+	"IN":          true,
+	"NOT IN":      true,
+	"IS NULL":     true,
+	"IS NOT NULL": true,
+	"BETWEEN":     true,
+}
+
+// condition represents a single node of a WHERE clause
+type condition interface {
+	render(b *sqlBuilder) (string, error)
+}
+
+// sqlBuilder collects query arguments and renders dialect-correct placeholders
+type sqlBuilder struct {
+	orm  *ORM
+	args []interface{}
+}
+
+// bind appends a value to the argument list and returns its placeholder
+func (b *sqlBuilder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	return b.orm.placeholder(len(b.args))
+}
+
+// likeEscape is the escape character of patterns built by WhereContains
+const likeEscape = `\`
+
+// likeEscaper escapes the escape character and the wildcards of LIKE
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// escapedPattern is a LIKE pattern whose literal parts have been escaped with
+// likeEscape; it is rendered with an ESCAPE clause
+type escapedPattern string
+
+// comparison represents a "column op value" condition
+type comparison struct {
+	column   string
+	operator string
+	value    interface{}
+}
+
+// render renders the comparison as SQL
+func (c comparison) render(b *sqlBuilder) (string, error) {
+	switch c.operator {
+	case "IS NULL", "IS NOT NULL":
+		return fmt.Sprintf("%s %s", b.orm.Quote(c.column), c.operator), nil
+	case "IN", "NOT IN":
+		values, err := toSlice(c.value)
+		if err != nil {
+			return "", fmt.Errorf("operator %s on %s: %w", c.operator, c.column, err)
+		}
+		if len(values) == 0 {
+			// An empty IN list matches nothing, an empty NOT IN list matches everything
+			if c.operator == "IN" {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = b.bind(value)
+		}
+		return fmt.Sprintf("%s %s (%s)", b.orm.Quote(c.column), c.operator, strings.Join(placeholders, ", ")), nil
+	case "BETWEEN":
+		values, err := toSlice(c.value)
+		if err != nil || len(values) != 2 {
+			return "", fmt.Errorf("operator BETWEEN on %s requires exactly two values", c.column)
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", b.orm.Quote(c.column), b.bind(values[0]), b.bind(values[1])), nil
+	case "LIKE", "NOT LIKE":
+		// The escape character is bound, as backslashes in string literals
+		// are escapes themselves on MySQL
+		if pattern, ok := c.value.(escapedPattern); ok {
+			return fmt.Sprintf("%s %s %s ESCAPE %s", b.orm.Quote(c.column), c.operator, b.bind(string(pattern)), b.bind(likeEscape)), nil
+		}
+		return fmt.Sprintf("%s %s %s", b.orm.Quote(c.column), c.operator, b.bind(c.value)), nil
+	default:
+		return fmt.Sprintf("%s %s %s", b.orm.Quote(c.column), c.operator, b.bind(c.value)), nil
+	}
+}
+
+// group represents a parenthesised set of conditions joined by AND/OR
+type group struct {
+	conditions []condition
+	connectors []string
+}
+
+// add appends a condition joined with the given connector
+func (g *group) add(connector string, cond condition) {
+	g.conditions = append(g.conditions, cond)
+	g.connectors = append(g.connectors, connector)
+}
+
+// render renders the group as SQL
+func (g *group) render(b *sqlBuilder) (string, error) {
+	var sb strings.Builder
+	for i, cond := range g.conditions {
+		sql, err := cond.render(b)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			sb.WriteString(" " + g.connectors[i] + " ")
+		}
+		if sub, ok := cond.(*group); ok && len(sub.conditions) > 1 {
+			sql = "(" + sql + ")"
+		}
+		sb.WriteString(sql)
+	}
+	return sb.String(), nil
+}
+
+// QuerySet represents a chainable query against a single table
+type QuerySet struct {
+	orm     *ORM
+	ctx     context.Context
+	exec    executor
+	table   string
+	columns []string
+	where   *group
+	orderBy []string
+	limit   int
+	offset  int
+	preload []string
+	trashed trashedScope
+	err     error
+}
+
+// Table starts a new query against the given table
+func (o *ORM) Table(tableName string) *QuerySet {
+	db := o.using(tableName)
+	return db.table(context.Background(), db.reader(), tableName)
+}
+
+// table starts a new query that runs on the given executor
+func (o *ORM) table(ctx context.Context, ex executor, tableName string) *QuerySet {
+	q := &QuerySet{
+		orm:   o,
+		ctx:   ctx,
+		exec:  ex,
+		table: tableName,
+		where: &group{},
+	}
+	if !identifierPattern.MatchString(tableName) {
+		q.err = fmt.Errorf("invalid table name %q", tableName)
+	}
+	return q
+}
+
+// WithContext binds the query to the given context
+func (q *QuerySet) WithContext(ctx context.Context) *QuerySet {
+	q.ctx = ctx
+	return q
+}
+
+// Select restricts the columns returned by the query
+func (q *QuerySet) Select(columns ...string) *QuerySet {
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			q.setErr(fmt.Errorf("invalid column name %q", column))
+			return q
+		}
+	}
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+// Where adds a condition joined to the previous ones with AND
+func (q *QuerySet) Where(column, operator string, value interface{}) *QuerySet {
+	return q.addComparison("AND", column, operator, value)
+}
+
+// OrWhere adds a condition joined to the previous ones with OR
+func (q *QuerySet) OrWhere(column, operator string, value interface{}) *QuerySet {
+	return q.addComparison("OR", column, operator, value)
+}
+
+// WhereIn adds a "column IN (...)" condition
+func (q *QuerySet) WhereIn(column string, values interface{}) *QuerySet {
+	return q.Where(column, "IN", values)
+}
+
+// WhereNotIn adds a "column NOT IN (...)" condition
+func (q *QuerySet) WhereNotIn(column string, values interface{}) *QuerySet {
+	return q.Where(column, "NOT IN", values)
+}
+
+// WhereNull adds a "column IS NULL" condition
+func (q *QuerySet) WhereNull(column string) *QuerySet {
+	return q.Where(column, "IS NULL", nil)
+}
+
+// WhereNotNull adds a "column IS NOT NULL" condition
+func (q *QuerySet) WhereNotNull(column string) *QuerySet {
+	return q.Where(column, "IS NOT NULL", nil)
+}
+
+// WhereLike adds a "column LIKE pattern" condition
+func (q *QuerySet) WhereLike(column, pattern string) *QuerySet {
+	return q.Where(column, "LIKE", pattern)
+}
+
+// WhereContains adds a condition matching rows whose column contains value.
+// Unlike WhereLike, the wildcards % and _ in value match themselves.
+func (q *QuerySet) WhereContains(column, value string) *QuerySet {
+	return q.Where(column, "LIKE", escapedPattern("%"+likeEscaper.Replace(value)+"%"))
+}
+
+// OrWhereContains adds a WhereContains condition joined with OR
+func (q *QuerySet) OrWhereContains(column, value string) *QuerySet {
+	return q.OrWhere(column, "LIKE", escapedPattern("%"+likeEscaper.Replace(value)+"%"))
+}
+
+// WhereBetween adds a "column BETWEEN low AND high" condition
+func (q *QuerySet) WhereBetween(column string, low, high interface{}) *QuerySet {
+	return q.Where(column, "BETWEEN", []interface{}{low, high})
+}
+
+// WhereGroup adds a parenthesised group of conditions joined with AND
+func (q *QuerySet) WhereGroup(fn func(q *QuerySet)) *QuerySet {
+	return q.addGroup("AND", fn)
+}
+
+// OrWhereGroup adds a parenthesised group of conditions joined with OR
+func (q *QuerySet) OrWhereGroup(fn func(q *QuerySet)) *QuerySet {
+	return q.addGroup("OR", fn)
+}
+
+// OrderBy sets the ordering of the results; prefix a column with "-" for descending order
+func (q *QuerySet) OrderBy(columns ...string) *QuerySet {
+	for _, column := range columns {
+		direction := "ASC"
+		if strings.HasPrefix(column, "-") {
+			direction = "DESC"
+			column = column[1:]
+		}
+		if !identifierPattern.MatchString(column) {
+			q.setErr(fmt.Errorf("invalid order column %q", column))
+			return q
+		}
+		q.orderBy = append(q.orderBy, fmt.Sprintf("%s %s", q.orm.Quote(column), direction))
+	}
+	return q
+}
+
+// Limit limits the number of returned rows
+func (q *QuerySet) Limit(limit int) *QuerySet {
+	q.limit = limit
+	return q
+}
+
+// Offset skips the given number of rows
+func (q *QuerySet) Offset(offset int) *QuerySet {
+	q.offset = offset
+	return q
+}
+
+// All executes the query and returns every matching row
+func (q *QuerySet) All() ([]map[string]interface{}, error) {
+	query, args, err := q.ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := q.orm.query(q.ctx, q.exec, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.orm.preloadRelations(q.ctx, q.exec, q.table, results, q.preload); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// First executes the query and returns the first matching row, or sql.ErrNoRows
+func (q *QuerySet) First() (map[string]interface{}, error) {
+	clone := *q
+	clone.limit = 1
+	results, err := clone.All()
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNoRows
+	}
+	return results[0], nil
+}
+
+// Count returns the number of rows matching the query, ignoring limit and offset
+func (q *QuerySet) Count() (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+
+	b := &sqlBuilder{orm: q.orm}
+	where, err := q.renderWhere(b)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", q.orm.Quote(q.table), where)
+
+	ctx, cancel := q.orm.withTimeout(q.ctx)
+	defer cancel()
+
+	var count int64
+	if err := q.exec.QueryRowContext(ctx, query, b.args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Exists reports whether at least one row matches the query
+func (q *QuerySet) Exists() (bool, error) {
+	_, err := q.First()
+	if err == ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ToSQL renders the query and returns it together with its arguments
+func (q *QuerySet) ToSQL() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	b := &sqlBuilder{orm: q.orm}
+
+	columns := "*"
+	if len(q.columns) > 0 {
+		quoted := make([]string, len(q.columns))
+		for i, column := range q.columns {
+			quoted[i] = q.orm.Quote(column)
+		}
+		columns = strings.Join(quoted, ", ")
+	}
+
+	where, err := q.renderWhere(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s", columns, q.orm.Quote(q.table), where)
+
+	if len(q.orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(q.orderBy, ", ")
+	}
+
+	if clause := q.orm.dialect.LimitOffset(q.limit, q.offset); clause != "" {
+		query += " " + clause
+	}
+
+	return query, b.args, nil
+}
+
+// renderWhere renders the WHERE clause, including the leading keyword
+func (q *QuerySet) renderWhere(b *sqlBuilder) (string, error) {
+	conditions := q.where
+	if scope := q.trashedCondition(); scope != nil {
+		conditions = &group{}
+		conditions.add("AND", scope)
+		if len(q.where.conditions) > 0 {
+			conditions.add("AND", q.where)
+		}
+	}
+	if len(conditions.conditions) == 0 {
+		return "", nil
+	}
+	where, err := conditions.render(b)
+	if err != nil {
+		return "", err
+	}
+	return " WHERE " + where, nil
+}
+
+// addComparison validates and appends a comparison to the WHERE clause
+func (q *QuerySet) addComparison(connector, column, operator string, value interface{}) *QuerySet {
+	operator = strings.ToUpper(strings.TrimSpace(operator))
+	if !identifierPattern.MatchString(column) {
+		q.setErr(fmt.Errorf("invalid column name %q", column))
+		return q
+	}
+	if !operators[operator] {
+		q.setErr(fmt.Errorf("unsupported operator %q", operator))
+		return q
+	}
+	q.where.add(connector, comparison{column: column, operator: operator, value: value})
+	return q
+}
+
+// addGroup builds a nested group with fn and appends it to the WHERE clause
+func (q *QuerySet) addGroup(connector string, fn func(q *QuerySet)) *QuerySet {
+	sub := &QuerySet{orm: q.orm, table: q.table, where: &group{}}
+	fn(sub)
+	if sub.err != nil {
+		q.setErr(sub.err)
+		return q
+	}
+	if len(sub.where.conditions) > 0 {
+		q.where.add(connector, sub.where)
+	}
+	return q
+}
+
+// setErr records the first error raised while building the query
+func (q *QuerySet) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// toSlice converts a slice or array of any element type to []interface{}
+func toSlice(value interface{}) ([]interface{}, error) {
+	if values, ok := value.([]interface{}); ok {
+		return values, nil
+	}
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a slice, got %T", value)
+	}
+	values := make([]interface{}, val.Len())
+	for i := range values {
+		values[i] = val.Index(i).Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,269 @@
+package orm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestORM returns an ORM on a new SQLite database on which the given
+// statements have been run
+func openTestORM(t *testing.T, stmts ...string) *ORM {
+	t.Helper()
+	o, err := New(Config{Driver: "sqlite3", Database: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	for _, stmt := range stmts {
+		if _, err := o.db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return o
+}
+
+func TestQuerySetToSQL(t *testing.T) {
+	o := &ORM{dialect: SQLiteDialect{}}
+
+	tests := []struct {
+		name  string
+		query func(q *QuerySet) *QuerySet
+		sql   string
+		args  []interface{}
+	}{
+		{
+			name:  "all",
+			query: func(q *QuerySet) *QuerySet { return q },
+			sql:   `SELECT * FROM "users"`,
+		},
+		{
+			name:  "columns",
+			query: func(q *QuerySet) *QuerySet { return q.Select("id", "users.name") },
+			sql:   `SELECT "id", "users"."name" FROM "users"`,
+		},
+		{
+			name:  "and",
+			query: func(q *QuerySet) *QuerySet { return q.Where("age", ">=", 18).Where("active", "=", true) },
+			sql:   `SELECT * FROM "users" WHERE "age" >= ? AND "active" = ?`,
+			args:  []interface{}{18, true},
+		},
+		{
+			name:  "or",
+			query: func(q *QuerySet) *QuerySet { return q.Where("role", "=", "admin").OrWhere("role", "=", "staff") },
+			sql:   `SELECT * FROM "users" WHERE "role" = ? OR "role" = ?`,
+			args:  []interface{}{"admin", "staff"},
+		},
+		{
+			name:  "operator case",
+			query: func(q *QuerySet) *QuerySet { return q.Where("name", " like ", "a%") },
+			sql:   `SELECT * FROM "users" WHERE "name" LIKE ?`,
+			args:  []interface{}{"a%"},
+		},
+		{
+			name:  "in",
+			query: func(q *QuerySet) *QuerySet { return q.WhereIn("id", []int{1, 2, 3}) },
+			sql:   `SELECT * FROM "users" WHERE "id" IN (?, ?, ?)`,
+			args:  []interface{}{1, 2, 3},
+		},
+		{
+			name:  "empty in",
+			query: func(q *QuerySet) *QuerySet { return q.WhereIn("id", []int{}) },
+			sql:   `SELECT * FROM "users" WHERE 1 = 0`,
+		},
+		{
+			name:  "empty not in",
+			query: func(q *QuerySet) *QuerySet { return q.WhereNotIn("id", []string{}) },
+			sql:   `SELECT * FROM "users" WHERE 1 = 1`,
+		},
+		{
+			name:  "null",
+			query: func(q *QuerySet) *QuerySet { return q.WhereNull("deleted_at").WhereNotNull("email") },
+			sql:   `SELECT * FROM "users" WHERE "deleted_at" IS NULL AND "email" IS NOT NULL`,
+		},
+		{
+			name:  "between and like",
+			query: func(q *QuerySet) *QuerySet { return q.WhereBetween("age", 18, 65).WhereLike("name", "J%") },
+			sql:   `SELECT * FROM "users" WHERE "age" BETWEEN ? AND ? AND "name" LIKE ?`,
+			args:  []interface{}{18, 65, "J%"},
+		},
+		{
+			name:  "contains",
+			query: func(q *QuerySet) *QuerySet { return q.WhereContains("name", `50%_\`).OrWhereContains("email", "a") },
+			sql:   `SELECT * FROM "users" WHERE "name" LIKE ? ESCAPE ? OR "email" LIKE ? ESCAPE ?`,
+			args:  []interface{}{`%50\%\_\\%`, `\`, "%a%", `\`},
+		},
+		{
+			name: "groups",
+			query: func(q *QuerySet) *QuerySet {
+				return q.Where("active", "=", true).
+					WhereGroup(func(g *QuerySet) { g.Where("role", "=", "admin").OrWhere("age", ">", 30) }).
+					OrWhereGroup(func(g *QuerySet) { g.Where("id", "=", 1).Where("name", "=", "root") })
+			},
+			sql:  `SELECT * FROM "users" WHERE "active" = ? AND ("role" = ? OR "age" > ?) OR ("id" = ? AND "name" = ?)`,
+			args: []interface{}{true, "admin", 30, 1, "root"},
+		},
+		{
+			name: "nested groups",
+			query: func(q *QuerySet) *QuerySet {
+				return q.WhereGroup(func(g *QuerySet) {
+					g.Where("a", "=", 1).OrWhereGroup(func(g *QuerySet) { g.Where("b", "=", 2).Where("c", "=", 3) })
+				}).Where("d", "=", 4)
+			},
+			sql:  `SELECT * FROM "users" WHERE ("a" = ? OR ("b" = ? AND "c" = ?)) AND "d" = ?`,
+			args: []interface{}{1, 2, 3, 4},
+		},
+		{
+			name: "single condition group",
+			query: func(q *QuerySet) *QuerySet {
+				return q.Where("a", "=", 1).OrWhereGroup(func(g *QuerySet) { g.Where("b", "=", 2) })
+			},
+			sql:  `SELECT * FROM "users" WHERE "a" = ? OR "b" = ?`,
+			args: []interface{}{1, 2},
+		},
+		{
+			name:  "empty group",
+			query: func(q *QuerySet) *QuerySet { return q.WhereGroup(func(g *QuerySet) {}) },
+			sql:   `SELECT * FROM "users"`,
+		},
+		{
+			name:  "ordering and limits",
+			query: func(q *QuerySet) *QuerySet { return q.OrderBy("-age", "name").Limit(10).Offset(20) },
+			sql:   `SELECT * FROM "users" ORDER BY "age" DESC, "name" ASC LIMIT 10 OFFSET 20`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := tt.query(o.Table("users")).ToSQL()
+			if err != nil {
+				t.Fatalf("ToSQL() error = %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("ToSQL() = %s, want %s", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("ToSQL() args = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestQuerySetToSQLDialects(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		sql     string
+	}{
+		{dialect: SQLiteDialect{}, sql: `SELECT * FROM "users" WHERE "id" IN (?, ?) OR "name" = ? LIMIT -1 OFFSET 5`},
+		{dialect: MySQLDialect{}, sql: "SELECT * FROM `users` WHERE `id` IN (?, ?) OR `name` = ? LIMIT 18446744073709551615 OFFSET 5"},
+		{dialect: PostgresDialect{}, sql: `SELECT * FROM "users" WHERE "id" IN ($1, $2) OR "name" = $3 OFFSET 5`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			o := &ORM{dialect: tt.dialect}
+			sql, _, err := o.Table("users").WhereIn("id", []int{1, 2}).OrWhere("name", "=", "a").Offset(5).ToSQL()
+			if err != nil {
+				t.Fatalf("ToSQL() error = %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("ToSQL() = %s, want %s", sql, tt.sql)
+			}
+		})
+	}
+}
+
+func TestQuerySetInvalid(t *testing.T) {
+	o := &ORM{dialect: SQLiteDialect{}}
+
+	tests := []struct {
+		name  string
+		query *QuerySet
+	}{
+		{name: "table", query: o.Table("users; DROP TABLE users")},
+		{name: "column", query: o.Table("users").Select("name, password")},
+		{name: "where column", query: o.Table("users").Where("1 = 1 OR id", "=", 1)},
+		{name: "operator", query: o.Table("users").Where("id", "==", 1)},
+		{name: "order column", query: o.Table("users").OrderBy("-id; --")},
+		{name: "group", query: o.Table("users").WhereGroup(func(g *QuerySet) { g.Where("id", "~", 1) })},
+		{name: "in without a slice", query: o.Table("users").WhereIn("id", 1)},
+		{name: "between one value", query: o.Table("users").Where("id", "BETWEEN", []int{1})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sql, _, err := tt.query.ToSQL(); err == nil {
+				t.Errorf("ToSQL() = %s, want an error", sql)
+			}
+		})
+	}
+}
+
+func TestQuerySet(t *testing.T) {
+	o := openTestORM(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER)",
+		"INSERT INTO users (id, name, age) VALUES (1, 'Ann', 30), (2, 'Bob', 17), (3, 'Cid', NULL), (4, 'Dee', 45)",
+	)
+	names := func(rows []map[string]interface{}) []string {
+		var names []string
+		for _, row := range rows {
+			names = append(names, row["name"].(string))
+		}
+		return names
+	}
+
+	rows, err := o.Table("users").
+		Where("age", ">=", 18).
+		OrWhereGroup(func(g *QuerySet) { g.WhereNull("age").Where("name", "LIKE", "C%") }).
+		OrderBy("-id").
+		All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if got, want := names(rows), []string{"Dee", "Cid", "Ann"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("All() = %q, want %q", got, want)
+	}
+
+	rows, err = o.Table("users").Select("name").OrderBy("name").Limit(2).Offset(1).All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if got, want := names(rows), []string{"Bob", "Cid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("All() with limit and offset = %q, want %q", got, want)
+	}
+
+	first, err := o.Table("users").WhereIn("id", []int{2, 4}).OrderBy("-age").First()
+	if err != nil {
+		t.Fatalf("First() error = %v", err)
+	}
+	if first["name"] != "Dee" {
+		t.Errorf("First() = %v, want Dee", first)
+	}
+	if _, err := o.Table("users").Where("id", "=", 5).First(); err != ErrNoRows {
+		t.Errorf("First() error = %v, want ErrNoRows", err)
+	}
+
+	// Count ignores limits
+	count, err := o.Table("users").WhereNotNull("age").Limit(1).Count()
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Count() = %d, want 3", count)
+	}
+
+	for _, tt := range []struct {
+		id   int
+		want bool
+	}{{1, true}, {5, false}} {
+		exists, err := o.Table("users").Where("id", "=", tt.id).Exists()
+		if err != nil {
+			t.Fatalf("Exists() error = %v", err)
+		}
+		if exists != tt.want {
+			t.Errorf("Exists() for id %d = %v, want %v", tt.id, exists, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// defaultHealthCheckInterval is used when Config.HealthCheckInterval is zero
+const defaultHealthCheckInterval = 30 * time.Second
+
+// pingTimeout bounds a single replica health check
+const pingTimeout = 2 * time.Second
+
+// primaryContextKey marks contexts whose reads must go to the primary
+type primaryContextKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary database rather
+// than a replica, e.g. to read a row back right after writing it
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// replica is a read-only connection pool together with its health
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// check pings the replica, records whether it is healthy and returns the result
+func (r *replica) check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	healthy := r.db.PingContext(ctx) == nil
+	r.healthy.Store(healthy)
+	return healthy
+}
+
+// replicaConfig fills the fields a replica configuration leaves empty from the
+// primary's. The driver and dialect are always the primary's; the DSN is not
+// inherited.
+func replicaConfig(primary, config Config) Config {
+	config.Driver = primary.Driver
+	config.Dialect = primary.Dialect
+	if config.Host == "" {
+		config.Host = primary.Host
+	}
+	if config.Port == 0 {
+		config.Port = primary.Port
+	}
+	if config.User == "" {
+		config.User = primary.User
+	}
+	if config.Password == "" {
+		config.Password = primary.Password
+	}
+	if config.Database == "" {
+		config.Database = primary.Database
+	}
+	if config.MaxOpenConns == 0 {
+		config.MaxOpenConns = primary.MaxOpenConns
+	}
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = primary.MaxIdleConns
+	}
+	if config.ConnMaxLifetime == 0 {
+		config.ConnMaxLifetime = primary.ConnMaxLifetime
+	}
+	if config.ConnMaxIdleTime == 0 {
+		config.ConnMaxIdleTime = primary.ConnMaxIdleTime
+	}
+	if config.Params == nil {
+		config.Params = primary.Params
+	}
+	return config
+}
+
+// reader returns the executor used for reads outside a transaction
+func (o *ORM) reader() executor {
+	if len(o.replicas) == 0 {
+		return o.db
+	}
+	return readRouter{orm: o}
+}
+
+// replica returns the next healthy replica in round-robin order, or nil when
+// the read must go to the primary
+func (o *ORM) replica(ctx context.Context) *replica {
+	if len(o.replicas) == 0 {
+		return nil
+	}
+	if primary, _ := ctx.Value(primaryContextKey{}).(bool); primary {
+		return nil
+	}
+
+	start := o.next.Add(1)
+	for i := range o.replicas {
+		r := o.replicas[(int(start)+i)%len(o.replicas)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// monitorReplicas pings every replica at the given interval until the ORM is closed
+func (o *ORM) monitorReplicas(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			for _, r := range o.replicas {
+				r.check(context.Background())
+			}
+		}
+	}
+}
+
+// readRouter is an executor sending queries to a healthy replica. A query that
+// fails on a replica which then fails its health check is retried on the
+// primary; statements always run on the primary.
+type readRouter struct {
+	orm *ORM
+}
+
+// ExecContext implements executor
+func (r readRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.orm.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements executor
+func (r readRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	replica := r.orm.replica(ctx)
+	if replica == nil {
+		return r.orm.db.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := replica.db.QueryContext(ctx, query, args...)
+	if err != nil && ctx.Err() == nil && !replica.check(ctx) {
+		return r.orm.db.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// QueryRowContext implements executor. Errors surface only when the row is
+// scanned, so there is no fallback; unhealthy replicas are still skipped.
+func (r readRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if replica := r.orm.replica(ctx); replica != nil {
+		return replica.db.QueryRowContext(ctx, query, args...)
+	}
+	return r.orm.db.QueryRowContext(ctx, query, args...)
+}
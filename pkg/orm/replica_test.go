@@ -0,0 +1,240 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// seed creates a users table holding the given name in a new SQLite database
+// and returns the database path
+func seed(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".db")
+	o, err := New(Config{Driver: "sqlite3", Database: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users (id, name) VALUES (1, '" + name + "')",
+	} {
+		if _, err := o.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestReplicaConfig(t *testing.T) {
+	primary := Config{
+		Driver: "postgres", Dialect: "postgres", Host: "db1", Port: 5432, User: "app", Password: "secret",
+		Database: "shop", MaxOpenConns: 10, DSN: "postgres://db1/shop",
+	}
+
+	got := replicaConfig(primary, Config{Driver: "mysql", Host: "db2", User: "reader"})
+	want := Config{
+		Driver: "postgres", Dialect: "postgres", Host: "db2", Port: 5432, User: "reader", Password: "secret",
+		Database: "shop", MaxOpenConns: 10,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replicaConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := seed(t, dir, "primary")
+	replicaPath := seed(t, dir, "replica")
+
+	o, err := New(Config{
+		Driver:              "sqlite3",
+		Database:            primaryPath,
+		Replicas:            []Config{{Database: replicaPath}},
+		HealthCheckInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer o.Close()
+	model := models.NewModel("users")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("name", reflect.TypeOf(""))
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+
+	name := func(row map[string]interface{}, err error) interface{} {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row["name"]
+	}
+
+	if got := name(o.Get("users", 1)); got != "replica" {
+		t.Errorf("Get() read %v, want the replica", got)
+	}
+	if got := name(o.Table("users").First()); got != "replica" {
+		t.Errorf("Table().First() read %v, want the replica", got)
+	}
+	if got := name(o.GetContext(WithPrimary(context.Background()), "users", 1)); got != "primary" {
+		t.Errorf("GetContext(WithPrimary()) read %v, want the primary", got)
+	}
+
+	// Raw queries may write, so only QueryReplica reads a replica
+	query := func(rows []map[string]interface{}, err error) interface{} {
+		t.Helper()
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("rows = %v, %v, want one", rows, err)
+		}
+		return rows[0]["name"]
+	}
+	if got := query(o.Query("SELECT name FROM users")); got != "primary" {
+		t.Errorf("Query() read %v, want the primary", got)
+	}
+	if got := query(o.QueryReplica("SELECT name FROM users")); got != "replica" {
+		t.Errorf("QueryReplica() read %v, want the replica", got)
+	}
+	if got := query(o.QueryReplicaContext(WithPrimary(context.Background()), "SELECT name FROM users")); got != "primary" {
+		t.Errorf("QueryReplicaContext(WithPrimary()) read %v, want the primary", got)
+	}
+
+	// Writes and transactions use the primary
+	if err := o.Update("users", 1, map[string]interface{}{"name": "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := name(o.GetContext(WithPrimary(context.Background()), "users", 1)); got != "updated" {
+		t.Errorf("primary = %v after Update(), want updated", got)
+	}
+	err = o.Transaction(context.Background(), func(tx *Tx) error {
+		if got := name(tx.Get("users", 1)); got != "updated" {
+			t.Errorf("tx.Get() read %v, want the primary", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unhealthy replicas are skipped
+	o.replicas[0].healthy.Store(false)
+	if got := name(o.Get("users", 1)); got != "updated" {
+		t.Errorf("Get() read %v with an unhealthy replica, want the primary", got)
+	}
+	if !o.replicas[0].check(context.Background()) {
+		t.Error("check() reported a reachable replica as unhealthy")
+	}
+	if got := name(o.Get("users", 1)); got != "replica" {
+		t.Errorf("Get() read %v after the replica recovered, want the replica", got)
+	}
+
+	// A failing query on a replica that fails its health check goes to the primary
+	o.replicas[0].db.Close()
+	if got := name(o.Get("users", 1)); got != "updated" {
+		t.Errorf("Get() read %v from a closed replica, want the primary", got)
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	dir := t.TempDir()
+	o, err := New(Config{
+		Driver:   "sqlite3",
+		Database: filepath.Join(dir, "default.db"),
+		Databases: map[string]Config{
+			"analytics": {Driver: "sqlite3", Database: filepath.Join(dir, "analytics.db")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer o.Close()
+
+	events := models.NewModel("events", models.WithDatabase("analytics"))
+	events.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	events.AddField("name", reflect.TypeOf(""))
+	if err := o.RegisterModel(events); err != nil {
+		t.Fatalf("RegisterModel() error = %v", err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatalf("CreateTables() error = %v", err)
+	}
+
+	if _, err := o.Create("events", map[string]interface{}{"name": "signup"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if row, err := o.Get("events", 1); err != nil || row["name"] != "signup" {
+		t.Errorf("Get() = %v, %v", row, err)
+	}
+	if n, err := o.Table("events").Count(); err != nil || n != 1 {
+		t.Errorf("Count() = %d, %v, want 1", n, err)
+	}
+
+	analytics, ok := o.Database("analytics")
+	if !ok {
+		t.Fatal("Database() did not find analytics")
+	}
+	if _, ok := analytics.Models()["events"]; !ok {
+		t.Error("events is not registered with the analytics database")
+	}
+	if _, ok := o.Models()["events"]; ok {
+		t.Error("events is registered with the default database")
+	}
+	if rows, err := analytics.Query("SELECT name FROM events"); err != nil || len(rows) != 1 {
+		t.Errorf("analytics events = %v, %v", rows, err)
+	}
+	if _, err := o.Query("SELECT name FROM events"); err == nil {
+		t.Error("events table exists in the default database")
+	}
+
+	// A transaction runs on one database
+	ctx := context.Background()
+	err = o.Transaction(ctx, func(tx *Tx) error {
+		if _, err := tx.Get("events", 1); !errors.Is(err, ErrCrossDatabase) {
+			t.Errorf("tx.Get() error = %v, want %v", err, ErrCrossDatabase)
+		}
+		if _, err := tx.Table("events").All(); !errors.Is(err, ErrCrossDatabase) {
+			t.Errorf("tx.Table().All() error = %v, want %v", err, ErrCrossDatabase)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = analytics.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Update("events", 1, map[string]interface{}{"name": "login"}); err != nil {
+			return err
+		}
+		_, err := tx.Table("events").Where("name", "=", "login").First()
+		return err
+	})
+	if err != nil {
+		t.Errorf("analytics Transaction() error = %v", err)
+	}
+
+	if database, ok := o.Database(DefaultDatabase); !ok || database != o {
+		t.Error("Database(DefaultDatabase) did not return the ORM itself")
+	}
+	if _, ok := o.Database("reports"); ok {
+		t.Error("Database() found an unknown database")
+	}
+
+	if err := o.RegisterModel(models.NewModel("reports", models.WithDatabase("reports"))); err == nil {
+		t.Error("RegisterModel() accepted an unknown database")
+	}
+
+	_, err = New(Config{
+		Driver:    "sqlite3",
+		Database:  filepath.Join(dir, "other.db"),
+		Databases: map[string]Config{DefaultDatabase: {Driver: "sqlite3", Database: filepath.Join(dir, "x.db")}},
+	})
+	if err == nil {
+		t.Error("New() accepted a database named default")
+	}
+}
@@ -0,0 +1,419 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// Querier is implemented by both *ORM and *Tx
+type Querier interface {
+	Get(tableName string, id interface{}) (map[string]interface{}, error)
+	Query(query string, args ...interface{}) ([]map[string]interface{}, error)
+}
+
+// timeLayouts lists the layouts tried when a time is returned as text
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ScanError describes a column value that cannot be stored in a struct field
+type ScanError struct {
+	Column string
+	Field  string
+	Value  interface{}
+	Target reflect.Type
+	Err    error
+}
+
+// Error implements the error interface
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("cannot scan column %s (%T) into field %s (%v): %v",
+		e.Column, e.Value, e.Field, e.Target, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// GetAs retrieves a record by primary key and scans it into a new T.
+// The table is the one models.FromStruct would use for T.
+func GetAs[T any](q Querier, id interface{}) (*T, error) {
+	dest := new(T)
+	row, err := q.Get(models.TableNameOf(dest), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := ScanMap(row, dest); err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+// QueryAs executes a custom query and scans every row into a T
+func QueryAs[T any](q Querier, query string, args ...interface{}) ([]T, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAll[T](rows)
+}
+
+// AllAs executes a query set and scans every row into a T
+func AllAs[T any](qs *QuerySet) ([]T, error) {
+	rows, err := qs.All()
+	if err != nil {
+		return nil, err
+	}
+	return scanAll[T](rows)
+}
+
+// scanAll scans every row into a new T
+func scanAll[T any](rows []map[string]interface{}) ([]T, error) {
+	results := make([]T, len(rows))
+	for i, row := range rows {
+		if err := ScanMap(row, &results[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// ScanMap stores the values of a result row into the fields of the struct
+// pointed to by dest. Columns are matched to fields by their `db` tag or
+// snake_case field name; columns without a matching field are ignored.
+func ScanMap(row map[string]interface{}, dest interface{}) error {
+	val := reflect.ValueOf(dest)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a non-nil pointer to a struct, got %T", dest)
+	}
+	val = val.Elem()
+
+	fields := make(map[string][]int)
+	collectFields(val.Type(), nil, fields)
+
+	for column, value := range row {
+		index, ok := fields[column]
+		if !ok {
+			index, ok = fields[strings.ToLower(column)]
+		}
+		if !ok {
+			continue
+		}
+
+		field := fieldByIndex(val, index)
+		if err := assignValue(field, value); err != nil {
+			return &ScanError{
+				Column: column,
+				Field:  val.Type().FieldByIndex(index).Name,
+				Value:  value,
+				Target: field.Type(),
+				Err:    err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectFields maps column names to the index paths of the struct fields they scan into
+func collectFields(typ reflect.Type, parent []int, fields map[string][]int) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		index := append(append([]int(nil), parent...), i)
+
+		if sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded == reflect.TypeOf(models.Model{}) {
+				continue // Skip the base model
+			}
+			if embedded.Kind() == reflect.Struct && sf.Tag.Get("db") == "" {
+				collectFields(embedded, index, fields)
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			continue // Skip unexported fields
+		}
+
+		name, ok := models.ColumnName(sf)
+		if !ok {
+			continue
+		}
+
+		// Fields declared on the outer struct take precedence over embedded ones
+		if _, exists := fields[name]; !exists || len(index) < len(fields[name]) {
+			fields[name] = index
+		}
+	}
+}
+
+// fieldByIndex returns the nested field, allocating nil embedded pointers on the way
+func fieldByIndex(val reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val
+}
+
+// assignValue converts a driver value and stores it in the field
+func assignValue(field reflect.Value, value interface{}) error {
+	if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(normalizeValue(value, field.Type()))
+	}
+
+	if value == nil {
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		return fmt.Errorf("NULL cannot be stored in a non-nullable field")
+	}
+
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := assignValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.Kind() == reflect.Interface {
+		field.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	// Preloaded relations
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}) {
+			return ScanMap(v, field.Addr().Interface())
+		}
+	case []map[string]interface{}:
+		if field.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(field.Type(), len(v), len(v))
+			for i, row := range v {
+				if err := assignValue(slice.Index(i), row); err != nil {
+					return err
+				}
+			}
+			field.Set(slice)
+			return nil
+		}
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %v", n, field.Type())
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 || field.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %v", n, field.Type())
+		}
+		field.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		if field.OverflowFloat(f) {
+			return fmt.Errorf("value %g overflows %v", f, field.Type())
+		}
+		field.SetFloat(f)
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			field.SetString(v)
+		case []byte:
+			field.SetString(string(v))
+		case time.Time:
+			field.SetString(v.Format(time.RFC3339Nano))
+		case int64, float64, bool:
+			field.SetString(fmt.Sprint(v))
+		default:
+			return fmt.Errorf("unsupported type")
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type")
+		}
+		switch v := value.(type) {
+		case []byte:
+			field.SetBytes(append([]byte(nil), v...))
+		case string:
+			field.SetBytes([]byte(v))
+		default:
+			return fmt.Errorf("unsupported type")
+		}
+	default:
+		v := reflect.ValueOf(value)
+		if !v.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("unsupported type")
+		}
+		field.Set(v.Convert(field.Type()))
+	}
+
+	return nil
+}
+
+// normalizeValue converts text values for sql.Scanner destinations whose
+// own conversion cannot parse them, such as timestamps stored as text
+func normalizeValue(value interface{}, target reflect.Type) interface{} {
+	if target == reflect.TypeOf(sql.NullTime{}) {
+		if t, err := toTime(value); err == nil && value != nil {
+			return t
+		}
+	}
+	if target == reflect.TypeOf(sql.NullBool{}) {
+		if b, err := toBool(value); err == nil && value != nil {
+			return b
+		}
+	}
+	return value
+}
+
+// toTime converts a driver value to time.Time
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case []byte:
+		return parseTime(string(v))
+	case string:
+		return parseTime(v)
+	}
+	return time.Time{}, fmt.Errorf("unsupported type")
+}
+
+// parseTime parses a timestamp in any of the supported layouts
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// toBool converts a driver value to bool
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case []byte:
+		return strconv.ParseBool(string(v))
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("unsupported type")
+}
+
+// toInt converts a driver value to int64
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		// math.MaxInt64 converts to 2^63, so it bounds the range exclusively
+		if v != math.Trunc(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+			return 0, fmt.Errorf("value %g is not an integer", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("unsupported type")
+}
+
+// toFloat converts a driver value to float64
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	n, err := toInt(value)
+	if err != nil {
+		return 0, err
+	}
+	return float64(n), nil
+}
@@ -0,0 +1,172 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// Audit is embedded by scanUser
+type Audit struct {
+	CreatedAt time.Time `db:"created_at"`
+	Note      string    `db:"note"`
+}
+
+// scanUser is the destination of the scanning tests
+type scanUser struct {
+	models.Model
+	*Audit
+	ID       int64          `db:"id,pk,autoincrement"`
+	Name     string         `db:"name,notnull"`
+	Age      uint8          `db:"age"`
+	Score    float64        `db:"score"`
+	Active   bool           `db:"active"`
+	Nickname sql.NullString `db:"nickname"`
+	Manager  *int64         `db:"manager_id"`
+	Note     string         `db:"note"` // Shadows Audit.Note
+	Data     []byte         `db:"data"`
+	Ignored  string         `db:"-"`
+}
+
+func (scanUser) TableName() string { return "people" }
+
+func TestScanMap(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	manager := int64(7)
+
+	tests := []struct {
+		name string
+		row  map[string]interface{}
+		want scanUser
+	}{
+		{
+			name: "driver types",
+			row: map[string]interface{}{
+				"id": int64(1), "name": "Ann", "age": int64(30), "score": 1.5, "active": true,
+				"nickname": "annie", "manager_id": int64(7), "note": "outer", "data": []byte("raw"),
+				"created_at": created,
+			},
+			want: scanUser{
+				Audit: &Audit{CreatedAt: created}, ID: 1, Name: "Ann", Age: 30, Score: 1.5, Active: true,
+				Nickname: sql.NullString{String: "annie", Valid: true}, Manager: &manager, Note: "outer", Data: []byte("raw"),
+			},
+		},
+		{
+			name: "text values",
+			row: map[string]interface{}{
+				"id": []byte("2"), "name": []byte("Bob"), "age": "41", "score": "2.25", "active": int64(1),
+				"created_at": "2024-05-01 12:30:00",
+			},
+			want: scanUser{Audit: &Audit{CreatedAt: created}, ID: 2, Name: "Bob", Age: 41, Score: 2.25, Active: true},
+		},
+		{
+			name: "nulls",
+			row:  map[string]interface{}{"id": int64(3), "nickname": nil, "manager_id": nil, "data": nil},
+			want: scanUser{ID: 3},
+		},
+		{
+			name: "unknown and upper case columns",
+			row:  map[string]interface{}{"ID": int64(4), "Ignored": "x", "extra": "y"},
+			want: scanUser{ID: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got scanUser
+			if err := ScanMap(tt.row, &got); err != nil {
+				t.Fatalf("ScanMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanMap() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanMapErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		row    map[string]interface{}
+		column string
+	}{
+		{name: "null into string", row: map[string]interface{}{"name": nil}, column: "name"},
+		{name: "overflow", row: map[string]interface{}{"age": int64(256)}, column: "age"},
+		{name: "negative unsigned", row: map[string]interface{}{"age": int64(-1)}, column: "age"},
+		{name: "fraction into int", row: map[string]interface{}{"id": 1.5}, column: "id"},
+		{name: "2^63 into int", row: map[string]interface{}{"id": math.Pow(2, 63)}, column: "id"},
+		{name: "invalid number", row: map[string]interface{}{"id": "one"}, column: "id"},
+		{name: "invalid bool", row: map[string]interface{}{"active": "maybe"}, column: "active"},
+		{name: "invalid time", row: map[string]interface{}{"created_at": "yesterday"}, column: "created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dest scanUser
+			err := ScanMap(tt.row, &dest)
+			var scanErr *ScanError
+			if !errors.As(err, &scanErr) {
+				t.Fatalf("ScanMap() error = %v, want a *ScanError", err)
+			}
+			if scanErr.Column != tt.column {
+				t.Errorf("ScanError.Column = %s, want %s", scanErr.Column, tt.column)
+			}
+		})
+	}
+
+	for _, dest := range []interface{}{scanUser{}, (*scanUser)(nil), new(int)} {
+		if err := ScanMap(map[string]interface{}{}, dest); err == nil {
+			t.Errorf("ScanMap() accepted %T", dest)
+		}
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	o := openTestORM(t,
+		"CREATE TABLE people (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, age INTEGER, score REAL, "+
+			"active BOOLEAN, nickname TEXT, manager_id INTEGER, note TEXT, data BLOB, created_at DATETIME)",
+		"INSERT INTO people (name, age, score, active, note, created_at) VALUES ('Ann', 30, 1.5, 1, 'a', '2024-05-01 12:30:00')",
+		"INSERT INTO people (name, age, score, active, nickname, manager_id, note, created_at) VALUES ('Bob', 41, 0, 0, 'bobby', 1, '', '2024-05-02')",
+	)
+	if err := o.RegisterModel(models.MustFromStruct(&scanUser{})); err != nil {
+		t.Fatal(err)
+	}
+
+	ann, err := GetAs[scanUser](o, 1)
+	if err != nil {
+		t.Fatalf("GetAs() error = %v", err)
+	}
+	want := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	if ann.Name != "Ann" || ann.Age != 30 || !ann.Active || ann.Note != "a" || ann.Audit == nil || !ann.Audit.CreatedAt.Equal(want) {
+		t.Errorf("GetAs() = %+v", ann)
+	}
+
+	if _, err := GetAs[scanUser](o, 99); !errors.Is(err, ErrNoRows) {
+		t.Errorf("GetAs() error = %v for a missing row, want ErrNoRows", err)
+	}
+
+	people, err := QueryAs[scanUser](o, "SELECT id, name, nickname, manager_id FROM people ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryAs() error = %v", err)
+	}
+	if len(people) != 2 || people[1].Nickname.String != "bobby" || people[1].Manager == nil || *people[1].Manager != 1 {
+		t.Errorf("QueryAs() = %+v", people)
+	}
+
+	people, err = AllAs[scanUser](o.Table("people").Where("active", "=", false))
+	if err != nil {
+		t.Fatalf("AllAs() error = %v", err)
+	}
+	if len(people) != 1 || people[0].Name != "Bob" {
+		t.Errorf("AllAs() = %+v, want Bob", people)
+	}
+
+	if _, err := QueryAs[scanUser](o, "SELECT NULL AS name"); err == nil {
+		t.Error("QueryAs() scanned NULL into a string")
+	}
+}
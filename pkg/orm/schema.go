@@ -0,0 +1,210 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// SchemaEditor is implemented by dialects whose statements for changing
+// existing tables differ from standard SQL. Dialects that do not implement it,
+// such as PostgresDialect, get the standard statements; see ORM.SchemaEditor.
+type SchemaEditor interface {
+	// HistoryTable returns the statement creating the table that records
+	// applied migrations, unless it already exists
+	HistoryTable(table string) string
+
+	// AlterColumn returns the statements changing the type, nullability,
+	// default and uniqueness of a column from previous to field. Keys are
+	// changed separately.
+	AlterColumn(table, column string, previous, field models.Field) []string
+
+	// DropColumn returns the statements dropping a column. foreignKey names the
+	// column's foreign key constraint, or is empty when it has none.
+	DropColumn(table, column, foreignKey string) []string
+
+	// DropForeignKey returns the statement dropping a foreign key constraint
+	DropForeignKey(table, constraint string) string
+
+	// DropIndex returns the statement dropping an index of the table
+	DropIndex(table, index string) string
+
+	// DropCheck returns the statement dropping a check constraint
+	DropCheck(table, check string) string
+}
+
+// TableRebuilder is implemented by dialects that cannot change constraints or
+// drop columns with ALTER TABLE, such as SQLite. Migrations make such changes
+// by creating the table anew, copying its rows and dropping the old table.
+type TableRebuilder interface {
+	// CanAddColumn reports whether ALTER TABLE ADD COLUMN can add the field,
+	// rather than rebuilding the table
+	CanAddColumn(field models.Field) bool
+
+	// DisableForeignKeys turns off foreign key enforcement on the connection
+	// running a migration and returns the function turning it back on. It is
+	// called outside of a transaction.
+	DisableForeignKeys(ctx context.Context, conn *sql.Conn) (func(), error)
+
+	// CheckForeignKeys returns ErrForeignKeyViolation if rows violate foreign
+	// keys, once the migration has run and before it is committed
+	CheckForeignKeys(ctx context.Context, tx *sql.Tx) error
+}
+
+// SchemaEditor returns the dialect's SchemaEditor, or one rendering standard
+// SQL if the dialect does not implement it
+func (o *ORM) SchemaEditor() SchemaEditor {
+	if editor, ok := o.dialect.(SchemaEditor); ok {
+		return editor
+	}
+	return standardSchemaEditor{o.dialect}
+}
+
+// TableRebuilder returns the dialect's TableRebuilder and whether the dialect
+// rebuilds tables
+func (o *ORM) TableRebuilder() (TableRebuilder, bool) {
+	rebuilder, ok := o.dialect.(TableRebuilder)
+	return rebuilder, ok
+}
+
+// standardSchemaEditor renders the standard statements, as accepted by PostgreSQL
+type standardSchemaEditor struct {
+	Dialect
+}
+
+// HistoryTable implements SchemaEditor
+func (d standardSchemaEditor) HistoryTable(table string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL)",
+		d.Quote(table))
+}
+
+// AlterColumn implements SchemaEditor
+func (d standardSchemaEditor) AlterColumn(table, column string, previous, field models.Field) []string {
+	var stmts []string
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", d.Quote(table), d.Quote(column))
+
+	oldType := d.SQLType(previous)
+	newType := d.SQLType(field)
+	if oldType != newType {
+		stmts = append(stmts, fmt.Sprintf("%s TYPE %s USING %s::%s", prefix, newType, d.Quote(column), newType))
+	}
+	if !previous.NotNull && field.NotNull {
+		stmts = append(stmts, prefix+" SET NOT NULL")
+	} else if previous.NotNull && !field.NotNull {
+		stmts = append(stmts, prefix+" DROP NOT NULL")
+	}
+	if fmt.Sprint(previous.Default) != fmt.Sprint(field.Default) {
+		if field.Default == nil {
+			stmts = append(stmts, prefix+" DROP DEFAULT")
+		} else {
+			// Reuse the DEFAULT clause of the column definition
+			definition := columnDefinition(d, column, field)
+			stmts = append(stmts, prefix+" SET"+definition[strings.Index(definition, " DEFAULT "):])
+		}
+	}
+	constraint := d.Quote(fmt.Sprintf("%s_%s_key", table, column))
+	if !previous.Unique && field.Unique {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s)", d.Quote(table), constraint, d.Quote(column)))
+	} else if previous.Unique && !field.Unique {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.Quote(table), constraint))
+	}
+	return stmts
+}
+
+// DropColumn implements SchemaEditor; the column's foreign key is dropped with it
+func (d standardSchemaEditor) DropColumn(table, column, foreignKey string) []string {
+	return []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.Quote(table), d.Quote(column))}
+}
+
+// DropForeignKey implements SchemaEditor
+func (d standardSchemaEditor) DropForeignKey(table, constraint string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.Quote(table), d.Quote(constraint))
+}
+
+// DropIndex implements SchemaEditor
+func (d standardSchemaEditor) DropIndex(table, index string) string {
+	return fmt.Sprintf("DROP INDEX %s", d.Quote(index))
+}
+
+// DropCheck implements SchemaEditor
+func (d standardSchemaEditor) DropCheck(table, check string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", d.Quote(table), d.Quote(check))
+}
+
+// HistoryTable implements SchemaEditor. DATETIME avoids the range and
+// automatic updates of MySQL's TIMESTAMP.
+func (d MySQLDialect) HistoryTable(table string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) NOT NULL PRIMARY KEY, applied_at DATETIME NOT NULL)",
+		d.Quote(table))
+}
+
+// AlterColumn implements SchemaEditor
+func (d MySQLDialect) AlterColumn(table, column string, previous, field models.Field) []string {
+	// MODIFY rewrites the full column definition; uniqueness is a separate index
+	unique := field.Unique
+	field.Unique = false
+	stmts := []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", d.Quote(table), columnDefinition(d, column, field))}
+	if !previous.Unique && unique {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE INDEX %s (%s)", d.Quote(table), d.Quote(column), d.Quote(column)))
+	} else if previous.Unique && !unique {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", d.Quote(table), d.Quote(column)))
+	}
+	return stmts
+}
+
+// DropColumn implements SchemaEditor; MySQL refuses to drop a column used by a
+// foreign key, so the key is dropped first
+func (d MySQLDialect) DropColumn(table, column, foreignKey string) []string {
+	var stmts []string
+	if foreignKey != "" {
+		stmts = append(stmts, d.DropForeignKey(table, foreignKey))
+	}
+	return append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", d.Quote(table), d.Quote(column)))
+}
+
+// DropForeignKey implements SchemaEditor
+func (d MySQLDialect) DropForeignKey(table, constraint string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", d.Quote(table), d.Quote(constraint))
+}
+
+// DropIndex implements SchemaEditor
+func (d MySQLDialect) DropIndex(table, index string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s", d.Quote(index), d.Quote(table))
+}
+
+// DropCheck implements SchemaEditor
+func (d MySQLDialect) DropCheck(table, check string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CHECK %s", d.Quote(table), d.Quote(check))
+}
+
+// CanAddColumn implements TableRebuilder; SQLite cannot add constrained
+// columns with ALTER TABLE
+func (SQLiteDialect) CanAddColumn(field models.Field) bool {
+	return field.ForeignKey == nil && !field.Unique && !field.PrimaryKey
+}
+
+// DisableForeignKeys implements TableRebuilder
+func (SQLiteDialect) DisableForeignKeys(ctx context.Context, conn *sql.Conn) (func(), error) {
+	// The pragma has no effect inside a transaction
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return nil, err
+	}
+	return func() { conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON") }, nil
+}
+
+// CheckForeignKeys implements TableRebuilder
+func (SQLiteDialect) CheckForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	violated := rows.Next()
+	rows.Close()
+	if violated {
+		return ErrForeignKeyViolation
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+func TestSchemaEditor(t *testing.T) {
+	previous := models.Field{Name: "name", Type: reflect.TypeOf(""), MaxLength: 50, Default: "a"}
+	field := models.Field{Name: "name", Type: reflect.TypeOf(""), MaxLength: 100, NotNull: true, Unique: true}
+
+	tests := []struct {
+		dialect        Dialect
+		history        string
+		alter          []string // Statements changing previous to field
+		unalter        []string // Statements changing field back to previous
+		unchanged      []string // Statements "changing" field to itself
+		dropColumn     []string
+		dropForeignKey string
+		dropIndex      string
+		dropCheck      string
+	}{
+		{
+			dialect: PostgresDialect{},
+			history: `CREATE TABLE IF NOT EXISTS "migrations" (name VARCHAR(255) NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL)`,
+			alter: []string{
+				`ALTER TABLE "users" ALTER COLUMN "name" TYPE VARCHAR(100) USING "name"::VARCHAR(100)`,
+				`ALTER TABLE "users" ALTER COLUMN "name" SET NOT NULL`,
+				`ALTER TABLE "users" ALTER COLUMN "name" DROP DEFAULT`,
+				`ALTER TABLE "users" ADD CONSTRAINT "users_name_key" UNIQUE ("name")`,
+			},
+			unalter: []string{
+				`ALTER TABLE "users" ALTER COLUMN "name" TYPE VARCHAR(50) USING "name"::VARCHAR(50)`,
+				`ALTER TABLE "users" ALTER COLUMN "name" DROP NOT NULL`,
+				`ALTER TABLE "users" ALTER COLUMN "name" SET DEFAULT 'a'`,
+				`ALTER TABLE "users" DROP CONSTRAINT "users_name_key"`,
+			},
+			dropColumn:     []string{`ALTER TABLE "users" DROP COLUMN "group_id"`},
+			dropForeignKey: `ALTER TABLE "users" DROP CONSTRAINT "fk_users_group_id"`,
+			dropIndex:      `DROP INDEX "idx_users_name"`,
+			dropCheck:      `ALTER TABLE "users" DROP CONSTRAINT "positive_age"`,
+		},
+		{
+			dialect: MySQLDialect{},
+			history: "CREATE TABLE IF NOT EXISTS `migrations` (name VARCHAR(255) NOT NULL PRIMARY KEY, applied_at DATETIME NOT NULL)",
+			alter: []string{
+				"ALTER TABLE `users` MODIFY COLUMN `name` VARCHAR(100) NOT NULL",
+				"ALTER TABLE `users` ADD UNIQUE INDEX `name` (`name`)",
+			},
+			unalter: []string{
+				"ALTER TABLE `users` MODIFY COLUMN `name` VARCHAR(50) DEFAULT 'a'",
+				"ALTER TABLE `users` DROP INDEX `name`",
+			},
+			unchanged: []string{"ALTER TABLE `users` MODIFY COLUMN `name` VARCHAR(100) NOT NULL"},
+			dropColumn: []string{
+				"ALTER TABLE `users` DROP FOREIGN KEY `fk_users_group_id`",
+				"ALTER TABLE `users` DROP COLUMN `group_id`",
+			},
+			dropForeignKey: "ALTER TABLE `users` DROP FOREIGN KEY `fk_users_group_id`",
+			dropIndex:      "DROP INDEX `idx_users_name` ON `users`",
+			dropCheck:      "ALTER TABLE `users` DROP CHECK `positive_age`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			editor := (&ORM{dialect: tt.dialect}).SchemaEditor()
+
+			if got := editor.HistoryTable("migrations"); got != tt.history {
+				t.Errorf("HistoryTable() = %s, want %s", got, tt.history)
+			}
+			if got := editor.AlterColumn("users", "name", previous, field); !reflect.DeepEqual(got, tt.alter) {
+				t.Errorf("AlterColumn() = %q, want %q", got, tt.alter)
+			}
+			if got := editor.AlterColumn("users", "name", field, previous); !reflect.DeepEqual(got, tt.unalter) {
+				t.Errorf("AlterColumn() back = %q, want %q", got, tt.unalter)
+			}
+			if got := editor.AlterColumn("users", "name", field, field); !reflect.DeepEqual(got, tt.unchanged) {
+				t.Errorf("AlterColumn() of an unchanged column = %q, want %q", got, tt.unchanged)
+			}
+			if got := editor.DropColumn("users", "group_id", "fk_users_group_id"); !reflect.DeepEqual(got, tt.dropColumn) {
+				t.Errorf("DropColumn() = %q, want %q", got, tt.dropColumn)
+			}
+			if got := editor.DropForeignKey("users", "fk_users_group_id"); got != tt.dropForeignKey {
+				t.Errorf("DropForeignKey() = %s, want %s", got, tt.dropForeignKey)
+			}
+			if got := editor.DropIndex("users", "idx_users_name"); got != tt.dropIndex {
+				t.Errorf("DropIndex() = %s, want %s", got, tt.dropIndex)
+			}
+			if got := editor.DropCheck("users", "positive_age"); got != tt.dropCheck {
+				t.Errorf("DropCheck() = %s, want %s", got, tt.dropCheck)
+			}
+		})
+	}
+}
+
+func TestTableRebuilder(t *testing.T) {
+	if _, ok := (&ORM{dialect: PostgresDialect{}}).TableRebuilder(); ok {
+		t.Error("TableRebuilder() found a rebuilder for postgres")
+	}
+
+	o := openTestORM(t,
+		"CREATE TABLE groups (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, group_id INTEGER REFERENCES groups (id))",
+	)
+	rebuilder, ok := o.TableRebuilder()
+	if !ok {
+		t.Fatal("TableRebuilder() found no rebuilder for sqlite3")
+	}
+
+	tests := []struct {
+		field models.Field
+		want  bool
+	}{
+		{field: models.Field{Name: "age", Type: reflect.TypeOf(0)}, want: true},
+		{field: models.Field{Name: "email", Type: reflect.TypeOf(""), Unique: true}},
+		{field: models.Field{Name: "group_id", Type: reflect.TypeOf(0), ForeignKey: &models.ForeignKey{Model: "groups", Field: "id"}}},
+	}
+	for _, tt := range tests {
+		if got := rebuilder.CanAddColumn(tt.field); got != tt.want {
+			t.Errorf("CanAddColumn(%s) = %v, want %v", tt.field.Name, got, tt.want)
+		}
+	}
+
+	// A row referencing a missing group is inserted with enforcement off and
+	// caught by the check
+	ctx := context.Background()
+	conn, err := o.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	enable, err := rebuilder.DisableForeignKeys(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enable()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := rebuilder.CheckForeignKeys(ctx, tx); err != nil {
+		t.Errorf("CheckForeignKeys() error = %v, want none", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO users (id, group_id) VALUES (1, 9)"); err != nil {
+		t.Fatalf("insert with foreign keys off: %v", err)
+	}
+	if err := rebuilder.CheckForeignKeys(ctx, tx); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Errorf("CheckForeignKeys() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+}
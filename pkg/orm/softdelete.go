@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// trashedScope selects which rows of a soft-deleted model a query returns
+type trashedScope int
+
+const (
+	withoutTrashed trashedScope = iota // Only rows that are not deleted (the default)
+	withTrashed                        // Deleted and not deleted rows
+	onlyTrashed                        // Only deleted rows
+)
+
+// WithTrashed includes soft-deleted rows in the query results
+func (q *QuerySet) WithTrashed() *QuerySet {
+	q.trashed = withTrashed
+	return q
+}
+
+// OnlyTrashed restricts the query to soft-deleted rows
+func (q *QuerySet) OnlyTrashed() *QuerySet {
+	q.trashed = onlyTrashed
+	return q
+}
+
+// trashedCondition returns the condition applying the soft delete scope, or nil
+// if the table does not use soft deletes or the query includes deleted rows
+func (q *QuerySet) trashedCondition() condition {
+	model, ok := q.orm.models[q.table]
+	if !ok {
+		return nil
+	}
+	column, ok := models.SoftDeleteField(model)
+	if !ok {
+		return nil
+	}
+	switch q.trashed {
+	case withoutTrashed:
+		return comparison{column: column, operator: "IS NULL"}
+	case onlyTrashed:
+		return comparison{column: column, operator: "IS NOT NULL"}
+	}
+	return nil
+}
+
+// notTrashed returns the condition excluding soft-deleted rows, prefixed with
+// AND, or an empty string if the model does not use soft deletes
+func (o *ORM) notTrashed(model models.ModelInterface) string {
+	if column, ok := models.SoftDeleteField(model); ok {
+		return fmt.Sprintf(" AND %s IS NULL", o.Quote(column))
+	}
+	return ""
+}
+
+// ForceDelete permanently deletes a record, even if its model uses soft deletes
+func (o *ORM) ForceDelete(tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.deleteRecord(context.Background(), db.db, tableName, id, true)
+}
+
+// ForceDeleteContext permanently deletes a record using the given context
+func (o *ORM) ForceDeleteContext(ctx context.Context, tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.deleteRecord(ctx, db.db, tableName, id, true)
+}
+
+// Restore undeletes a soft-deleted record. It returns ErrNoRows if no
+// soft-deleted record has the id.
+func (o *ORM) Restore(tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.restore(context.Background(), db.db, tableName, id)
+}
+
+// RestoreContext undeletes a soft-deleted record using the given context
+func (o *ORM) RestoreContext(ctx context.Context, tableName string, id interface{}) error {
+	db := o.using(tableName)
+	return db.restore(ctx, db.db, tableName, id)
+}
+
+// restore undeletes a soft-deleted record using the given executor
+func (o *ORM) restore(ctx context.Context, ex executor, tableName string, id interface{}) error {
+	model, ok := o.models[tableName]
+	if !ok {
+		return fmt.Errorf("model %s not registered", tableName)
+	}
+
+	column, ok := models.SoftDeleteField(model)
+	if !ok {
+		return fmt.Errorf("model %s does not use soft deletes", tableName)
+	}
+
+	where, args, err := o.primaryKeyCondition(model, id, 1)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s AND %s IS NOT NULL",
+		o.Quote(tableName), o.Quote(column), where, o.Quote(column))
+
+	n, err := o.exec(ctx, ex, query, args...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// openPostsORM returns a test ORM with a soft-deleted posts model
+func openPostsORM(t *testing.T) *ORM {
+	t.Helper()
+	o := openTestORM(t)
+	model := models.NewModel("posts", models.WithSoftDelete())
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("title", reflect.TypeOf(""), models.WithNotNull())
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := o.Create("posts", map[string]interface{}{"title": title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return o
+}
+
+// titles returns the titles of the posts a query returns
+func titles(t *testing.T, q *QuerySet) []string {
+	t.Helper()
+	rows, err := q.OrderBy("id").All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, row := range rows {
+		titles = append(titles, row["title"].(string))
+	}
+	return titles
+}
+
+func TestSoftDeleteToSQL(t *testing.T) {
+	o := openPostsORM(t)
+
+	tests := []struct {
+		name  string
+		query *QuerySet
+		sql   string
+	}{
+		{name: "default", query: o.Table("posts"), sql: `SELECT * FROM "posts" WHERE "deleted_at" IS NULL`},
+		{
+			name:  "conditions are grouped",
+			query: o.Table("posts").Where("id", "=", 1).OrWhere("id", "=", 2),
+			sql:   `SELECT * FROM "posts" WHERE "deleted_at" IS NULL AND ("id" = ? OR "id" = ?)`,
+		},
+		{name: "with trashed", query: o.Table("posts").WithTrashed(), sql: `SELECT * FROM "posts"`},
+		{name: "only trashed", query: o.Table("posts").OnlyTrashed(), sql: `SELECT * FROM "posts" WHERE "deleted_at" IS NOT NULL`},
+		{name: "other tables", query: o.Table("comments"), sql: `SELECT * FROM "comments"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, _, err := tt.query.ToSQL()
+			if err != nil {
+				t.Fatalf("ToSQL() error = %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("ToSQL() = %s, want %s", sql, tt.sql)
+			}
+		})
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	o := openPostsORM(t)
+
+	if err := o.Delete("posts", 2); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := o.Get("posts", 2); err != ErrNoRows {
+		t.Errorf("Get() error = %v for a deleted post, want ErrNoRows", err)
+	}
+	if got, want := titles(t, o.Table("posts")), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("posts = %q, want %q", got, want)
+	}
+	if got, want := titles(t, o.Table("posts").WithTrashed()), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("posts with trashed = %q, want %q", got, want)
+	}
+	if got, want := titles(t, o.Table("posts").OnlyTrashed()), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trashed posts = %q, want %q", got, want)
+	}
+	if n, _ := o.Table("posts").Count(); n != 2 {
+		t.Errorf("Count() = %d, want 2", n)
+	}
+
+	// Deleted posts are not updated
+	if err := o.Update("posts", 2, map[string]interface{}{"title": "x"}); err != ErrNoRows {
+		t.Fatalf("Update() error = %v for a deleted post, want ErrNoRows", err)
+	}
+	if err := o.Delete("posts", 2); err != ErrNoRows {
+		t.Errorf("Delete() error = %v for a deleted post, want ErrNoRows", err)
+	}
+	if n, err := o.BulkUpdate("posts", []map[string]interface{}{{"id": 1, "title": "a"}, {"id": 2, "title": "x"}}); err != nil || n != 1 {
+		t.Errorf("BulkUpdate() = %d, %v, want 1", n, err)
+	}
+	if row, _ := o.Table("posts").WithTrashed().Where("id", "=", 2).First(); row["title"] != "b" {
+		t.Errorf("deleted post = %v, want it unchanged", row)
+	}
+
+	if err := o.Restore("posts", 2); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if row, err := o.Get("posts", 2); err != nil || row["deleted_at"] != nil {
+		t.Errorf("Get() after Restore() = %v, %v", row, err)
+	}
+	if err := o.Restore("posts", 2); err != ErrNoRows {
+		t.Errorf("Restore() error = %v for a post that is not deleted, want ErrNoRows", err)
+	}
+	if err := o.Restore("posts", 9); err != ErrNoRows {
+		t.Errorf("Restore() error = %v for a missing post, want ErrNoRows", err)
+	}
+
+	if err := o.ForceDelete("posts", 3); err != nil {
+		t.Fatalf("ForceDelete() error = %v", err)
+	}
+	if got, want := titles(t, o.Table("posts").WithTrashed()), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("posts after ForceDelete() = %q, want %q", got, want)
+	}
+
+	if err := o.Restore("accounts", 1); err == nil {
+		t.Error("Restore() accepted an unregistered model")
+	}
+}
@@ -0,0 +1,218 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrCrossDatabase is returned when a transaction uses a table whose model is
+// registered in another database than the one the transaction runs on
+var ErrCrossDatabase = errors.New("transaction spans databases")
+
+// txContextKey is the context key under which the active transaction is stored
+type txContextKey struct{}
+
+// Tx represents a database transaction, or a savepoint inside one
+type Tx struct {
+	orm   *ORM
+	tx    *sql.Tx
+	ctx   context.Context
+	depth int
+}
+
+// Transaction runs fn inside a database transaction.
+// The transaction is committed if fn returns nil and rolled back if fn returns
+// an error or panics. If ctx already carries a transaction started by this ORM,
+// fn runs inside a savepoint of that transaction instead.
+//
+// A transaction runs on a single database: tables of models registered in a
+// named database return ErrCrossDatabase. Start their transactions on that
+// database, see Database.
+func (o *ORM) Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if parent, ok := ctx.Value(txContextKey{}).(*Tx); ok && parent.orm == o {
+		return parent.Transaction(fn)
+	}
+
+	sqlTx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	tx := &Tx{orm: o, tx: sqlTx}
+	tx.ctx = context.WithValue(ctx, txContextKey{}, tx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Transaction runs fn inside a savepoint of the current transaction.
+// The savepoint is released if fn returns nil and rolled back if fn returns
+// an error or panics; the outer transaction stays usable either way.
+func (t *Tx) Transaction(fn func(tx *Tx) error) error {
+	savepoint := fmt.Sprintf("framego_sp_%d", t.depth+1)
+
+	if _, err := t.tx.ExecContext(t.ctx, "SAVEPOINT "+savepoint); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	nested := &Tx{orm: t.orm, tx: t.tx, depth: t.depth + 1}
+	nested.ctx = context.WithValue(t.ctx, txContextKey{}, nested)
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if _, rbErr := t.tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if _, err := t.tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// Context returns the transaction's context, which carries the transaction itself
+func (t *Tx) Context() context.Context {
+	return t.ctx
+}
+
+// using returns the ORM of the database the table's model is registered in,
+// or ErrCrossDatabase if it is not the database of the transaction
+func (t *Tx) using(tableName string) (*ORM, error) {
+	db := t.orm.using(tableName)
+	if db != t.orm {
+		return nil, fmt.Errorf("%w: table %s is in another database", ErrCrossDatabase, tableName)
+	}
+	return db, nil
+}
+
+// Create inserts a new record within the transaction
+func (t *Tx) Create(tableName string, data map[string]interface{}) (int64, error) {
+	db, err := t.using(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return db.create(t.ctx, t.tx, tableName, data)
+}
+
+// Insert inserts a new record within the transaction and returns the stored row
+func (t *Tx) Insert(tableName string, data map[string]interface{}) (map[string]interface{}, error) {
+	db, err := t.using(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return db.insert(t.ctx, t.tx, tableName, data)
+}
+
+// BulkCreate inserts rows in batches within the transaction
+func (t *Tx) BulkCreate(tableName string, rows []map[string]interface{}, batchSize int) (int64, error) {
+	db, err := t.using(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return db.bulkCreate(t.ctx, t.tx, tableName, rows, batchSize)
+}
+
+// BulkUpdate updates rows by primary key within the transaction
+func (t *Tx) BulkUpdate(tableName string, rows []map[string]interface{}) (int64, error) {
+	db, err := t.using(tableName)
+	if err != nil {
+		return 0, err
+	}
+	return db.bulkUpdate(t.ctx, t.tx, tableName, rows)
+}
+
+// Upsert inserts or updates a row within the transaction
+func (t *Tx) Upsert(tableName string, row map[string]interface{}, conflictColumns, updateColumns []string) error {
+	db, err := t.using(tableName)
+	if err != nil {
+		return err
+	}
+	return db.upsert(t.ctx, t.tx, tableName, row, conflictColumns, updateColumns)
+}
+
+// Get retrieves a record within the transaction
+func (t *Tx) Get(tableName string, id interface{}) (map[string]interface{}, error) {
+	db, err := t.using(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return db.get(t.ctx, t.tx, tableName, id)
+}
+
+// Update updates a record within the transaction
+func (t *Tx) Update(tableName string, id interface{}, data map[string]interface{}) error {
+	db, err := t.using(tableName)
+	if err != nil {
+		return err
+	}
+	return db.update(t.ctx, t.tx, tableName, id, data)
+}
+
+// Delete deletes a record within the transaction
+func (t *Tx) Delete(tableName string, id interface{}) error {
+	db, err := t.using(tableName)
+	if err != nil {
+		return err
+	}
+	return db.delete(t.ctx, t.tx, tableName, id)
+}
+
+// ForceDelete permanently deletes a record within the transaction
+func (t *Tx) ForceDelete(tableName string, id interface{}) error {
+	db, err := t.using(tableName)
+	if err != nil {
+		return err
+	}
+	return db.deleteRecord(t.ctx, t.tx, tableName, id, true)
+}
+
+// Restore undeletes a soft-deleted record within the transaction
+func (t *Tx) Restore(tableName string, id interface{}) error {
+	db, err := t.using(tableName)
+	if err != nil {
+		return err
+	}
+	return db.restore(t.ctx, t.tx, tableName, id)
+}
+
+// Query executes a custom query within the transaction
+func (t *Tx) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return t.orm.query(t.ctx, t.tx, query, args...)
+}
+
+// Table starts a new query that runs within the transaction
+func (t *Tx) Table(tableName string) *QuerySet {
+	db, err := t.using(tableName)
+	if err != nil {
+		q := t.orm.table(t.ctx, t.tx, tableName)
+		q.err = err
+		return q
+	}
+	return db.table(t.ctx, t.tx, tableName)
+}
@@ -0,0 +1,227 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// openAccountsORM returns a test ORM with an accounts model
+func openAccountsORM(t *testing.T) *ORM {
+	t.Helper()
+	o := openTestORM(t)
+	model := models.NewModel("accounts")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("name", reflect.TypeOf(""), models.WithNotNull(), models.WithUnique())
+	if err := o.RegisterModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.CreateTables(); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// accountNames returns the names of every account, in insertion order
+func accountNames(t *testing.T, o *ORM) []string {
+	t.Helper()
+	rows, err := o.Table("accounts").OrderBy("id").All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, row := range rows {
+		names = append(names, row["name"].(string))
+	}
+	return names
+}
+
+func TestTransaction(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		fn   func(tx *Tx) error
+		err  error    // Expected error, nil for success
+		want []string // Accounts stored afterwards
+	}{
+		{
+			name: "commit",
+			fn: func(tx *Tx) error {
+				if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+					return err
+				}
+				_, err := tx.Create("accounts", map[string]interface{}{"name": "b"})
+				return err
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "rollback",
+			fn: func(tx *Tx) error {
+				if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+					return err
+				}
+				return errFailed
+			},
+			err: errFailed,
+		},
+		{
+			name: "savepoint rolled back",
+			fn: func(tx *Tx) error {
+				if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+					return err
+				}
+				err := tx.Transaction(func(tx *Tx) error {
+					if _, err := tx.Create("accounts", map[string]interface{}{"name": "b"}); err != nil {
+						return err
+					}
+					return errFailed
+				})
+				if !errors.Is(err, errFailed) {
+					t.Errorf("savepoint error = %v, want %v", err, errFailed)
+				}
+				_, err = tx.Create("accounts", map[string]interface{}{"name": "c"})
+				return err
+			},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "savepoints nest",
+			fn: func(tx *Tx) error {
+				return tx.Transaction(func(tx *Tx) error {
+					if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+						return err
+					}
+					tx.Transaction(func(tx *Tx) error {
+						tx.Create("accounts", map[string]interface{}{"name": "b"})
+						return errFailed
+					})
+					return tx.Transaction(func(tx *Tx) error {
+						_, err := tx.Create("accounts", map[string]interface{}{"name": "c"})
+						return err
+					})
+				})
+			},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "context carries the transaction",
+			fn: func(tx *Tx) error {
+				if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+					return err
+				}
+				// Runs in a savepoint, so the failure keeps "a"
+				tx.orm.Transaction(tx.Context(), func(tx *Tx) error {
+					if tx.depth != 1 {
+						t.Errorf("depth = %d, want 1", tx.depth)
+					}
+					tx.Create("accounts", map[string]interface{}{"name": "b"})
+					return errFailed
+				})
+				return nil
+			},
+			want: []string{"a"},
+		},
+		{
+			name: "constraint violation",
+			fn: func(tx *Tx) error {
+				if _, err := tx.Create("accounts", map[string]interface{}{"name": "a"}); err != nil {
+					return err
+				}
+				_, err := tx.Create("accounts", map[string]interface{}{"name": "a"})
+				if err == nil {
+					t.Error("duplicate name accepted")
+				}
+				return errFailed
+			},
+			err: errFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := openAccountsORM(t)
+			err := o.Transaction(context.Background(), tt.fn)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Transaction() error = %v, want %v", err, tt.err)
+			}
+			if got := accountNames(t, o); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("accounts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	o := openAccountsORM(t)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recover() = %v, want boom", p)
+			}
+		}()
+		o.Transaction(context.Background(), func(tx *Tx) error {
+			tx.Create("accounts", map[string]interface{}{"name": "a"})
+			tx.Transaction(func(tx *Tx) error {
+				tx.Create("accounts", map[string]interface{}{"name": "b"})
+				panic("boom")
+			})
+			return nil
+		})
+	}()
+
+	if got := accountNames(t, o); len(got) != 0 {
+		t.Errorf("accounts = %q after a panic, want none", got)
+	}
+}
+
+func TestTxMethods(t *testing.T) {
+	o := openAccountsORM(t)
+
+	err := o.Transaction(context.Background(), func(tx *Tx) error {
+		id, err := tx.Create("accounts", map[string]interface{}{"name": "a"})
+		if err != nil {
+			return err
+		}
+		if err := tx.Update("accounts", id, map[string]interface{}{"name": "b"}); err != nil {
+			return err
+		}
+		row, err := tx.Get("accounts", id)
+		if err != nil {
+			return err
+		}
+		if row["name"] != "b" {
+			t.Errorf("Get() = %v, want name b", row)
+		}
+		count, err := tx.Table("accounts").Where("name", "=", "b").Count()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("Count() = %d, want 1", count)
+		}
+		if err := tx.Delete("accounts", id); err != nil {
+			return err
+		}
+		rows, err := tx.Query("SELECT * FROM accounts")
+		if err != nil {
+			return err
+		}
+		if len(rows) != 0 {
+			t.Errorf("Query() = %v after Delete(), want no rows", rows)
+		}
+		_, err = tx.Create("accounts", map[string]interface{}{"name": "c"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+	if got, want := accountNames(t, o), []string{"c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts = %q, want %q", got, want)
+	}
+}
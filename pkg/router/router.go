@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+
+	"github.com/baxromov/framego/pkg/apierror"
 )
 
 // Route represents a route in the router
@@ -41,12 +43,12 @@ func New() *Router {
 
 // defaultNotFound is the default handler for 404 Not Found errors
 func defaultNotFound(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "404 Not Found", http.StatusNotFound)
+	apierror.Write(w, r, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "no route matches "+r.URL.Path))
 }
 
 // defaultMethodNotAllowed is the default handler for 405 Method Not Allowed errors
 func defaultMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+	apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method "+r.Method+" not allowed"))
 }
 
 // Use adds middleware to the router
@@ -233,7 +235,8 @@ func (g *Group) Use(middleware ...Middleware) {
 // Handle registers a new route with the group
 func (g *Group) Handle(method, pattern string, handler http.HandlerFunc, middleware ...Middleware) {
 	// Combine group and route middleware
-	allMiddleware := append(g.Middleware, middleware...)
+	// Copy so that routes never share the backing array of the group's middleware
+	allMiddleware := append(append([]Middleware{}, g.Middleware...), middleware...)
 
 	// Combine group prefix and route pattern
 	fullPattern := g.Prefix
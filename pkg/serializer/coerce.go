@@ -0,0 +1,198 @@
+package serializer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeLayouts are the layouts, tried in order, of strings accepted for
+// time.Time fields
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Coerce converts a value decoded from JSON to the given type: numbers to
+// the integer and float kinds, checking for overflow and fractions, strings
+// to time.Time, and values to named types such as `type Status string`.
+// Null stays nil, and pointer types take the converted value of their element
+// type. Errors are *FieldError without a field.
+func Coerce(value interface{}, typ reflect.Type) (interface{}, error) {
+	if value == nil || typ == nil {
+		return value, nil
+	}
+	if reflect.TypeOf(value) == typ {
+		return value, nil
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		// A pointer of the right element type, or a value to point to
+		val := reflect.ValueOf(value)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return nil, nil
+			}
+			value = val.Elem().Interface()
+		}
+		elem, err := Coerce(value, typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(reflect.ValueOf(elem))
+		return ptr.Interface(), nil
+	}
+
+	if typ == timeType {
+		return coerceTime(value)
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.Zero(typ).OverflowInt(n) {
+			return nil, Invalid(CodeOutOfRange, "%d is out of range for %v", n, typ)
+		}
+		return reflect.ValueOf(n).Convert(typ).Interface(), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || reflect.Zero(typ).OverflowUint(uint64(n)) {
+			return nil, Invalid(CodeOutOfRange, "%d is out of range for %v", n, typ)
+		}
+		return reflect.ValueOf(uint64(n)).Convert(typ).Interface(), nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.Zero(typ).OverflowFloat(f) {
+			return nil, Invalid(CodeOutOfRange, "%g is out of range for %v", f, typ)
+		}
+		return reflect.ValueOf(f).Convert(typ).Interface(), nil
+
+	case reflect.String, reflect.Bool:
+		// Only a JSON value of the same kind, possibly for a named type
+		val := reflect.ValueOf(value)
+		if val.Kind() != typ.Kind() {
+			return nil, Invalid(CodeInvalidType, "expected %v, got %v", typ, val.Type())
+		}
+		return val.Convert(typ).Interface(), nil
+	}
+
+	// Other types, such as slices and maps, must be assignable as they are
+	if val := reflect.ValueOf(value); val.Type().AssignableTo(typ) {
+		return value, nil
+	} else if val.Type().ConvertibleTo(typ) && val.Kind() == typ.Kind() {
+		return val.Convert(typ).Interface(), nil
+	}
+	return nil, Invalid(CodeInvalidType, "expected %v, got %v", typ, reflect.TypeOf(value))
+}
+
+// toInt converts a JSON number, or a Go integer, to an int64, rejecting
+// fractions
+func toInt(value interface{}) (int64, error) {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val.Uint() > math.MaxInt64 {
+			return 0, Invalid(CodeOutOfRange, "%d is out of range", val.Uint())
+		}
+		return int64(val.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return 0, Invalid(CodeInvalidType, "expected an integer, got %v", f)
+		}
+		// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, Invalid(CodeOutOfRange, "%g is out of range", f)
+		}
+		return int64(f), nil
+	}
+
+	if number, ok := value.(json.Number); ok {
+		n, err := strconv.ParseInt(string(number), 10, 64)
+		if err != nil {
+			if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+				return 0, Invalid(CodeOutOfRange, "%s is out of range", number)
+			}
+			return 0, Invalid(CodeInvalidType, "expected an integer, got %s", number)
+		}
+		return n, nil
+	}
+	return 0, Invalid(CodeInvalidType, "expected an integer, got %v", val.Type())
+}
+
+// toFloat converts a JSON number, or a Go number, to a float64
+func toFloat(value interface{}) (float64, error) {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), nil
+	}
+
+	if number, ok := value.(json.Number); ok {
+		f, err := number.Float64()
+		if err != nil {
+			return 0, Invalid(CodeInvalidType, "expected a number, got %s", number)
+		}
+		return f, nil
+	}
+	return 0, Invalid(CodeInvalidType, "expected a number, got %v", val.Type())
+}
+
+// coerceTime parses a string in one of TimeLayouts
+func coerceTime(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return nil, nil
+		}
+		return *v, nil
+	case string:
+		for _, layout := range TimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return nil, Invalid(CodeInvalid, "invalid date or time %q, expected RFC 3339 or YYYY-MM-DD", v)
+	}
+	return nil, Invalid(CodeInvalidType, "expected a date or time string, got %v", reflect.TypeOf(value))
+}
+
+// coerce converts a value to the type of the field, see Coerce
+func (f Field) coerce(name string, value interface{}) (interface{}, *FieldError) {
+	coerced, err := Coerce(value, f.Type)
+	if err != nil {
+		code := CodeInvalidType
+		if fieldErr, ok := err.(*FieldError); ok {
+			code = fieldErr.Code
+		}
+		return nil, fieldError(name, f, code, fmt.Sprintf("field %s has invalid value: %v", name, err))
+	}
+	return coerced, nil
+}
@@ -0,0 +1,111 @@
+package serializer
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoerce(t *testing.T) {
+	type status string
+	five := 5
+
+	tests := []struct {
+		name  string
+		value interface{}
+		typ   reflect.Type
+		want  interface{}
+		code  string // Expected error code, empty for success
+	}{
+		{name: "nil", value: nil, typ: reflect.TypeOf(0), want: nil},
+		{name: "same type", value: "a", typ: reflect.TypeOf(""), want: "a"},
+
+		// Integers
+		{name: "float to int", value: float64(42), typ: reflect.TypeOf(0), want: 42},
+		{name: "fraction to int", value: 1.5, typ: reflect.TypeOf(0), code: CodeInvalidType},
+		{name: "int8 max", value: float64(127), typ: reflect.TypeOf(int8(0)), want: int8(127)},
+		{name: "int8 overflow", value: float64(128), typ: reflect.TypeOf(int8(0)), code: CodeOutOfRange},
+		{name: "int8 underflow", value: float64(-129), typ: reflect.TypeOf(int8(0)), code: CodeOutOfRange},
+		{name: "int64 from 2^63 float", value: math.Pow(2, 63), typ: reflect.TypeOf(int64(0)), code: CodeOutOfRange},
+		{name: "int64 min float", value: -math.Pow(2, 63), typ: reflect.TypeOf(int64(0)), want: int64(math.MinInt64)},
+		{name: "infinity to int", value: math.Inf(1), typ: reflect.TypeOf(0), code: CodeInvalidType},
+		{name: "int64 max number", value: json.Number("9223372036854775807"), typ: reflect.TypeOf(int64(0)), want: int64(math.MaxInt64)},
+		{name: "int64 overflow number", value: json.Number("9223372036854775808"), typ: reflect.TypeOf(int64(0)), code: CodeOutOfRange},
+		{name: "fraction number to int", value: json.Number("1.5"), typ: reflect.TypeOf(0), code: CodeInvalidType},
+		{name: "uint64 above int64", value: uint64(math.MaxInt64) + 1, typ: reflect.TypeOf(int64(0)), code: CodeOutOfRange},
+		{name: "string to int", value: "42", typ: reflect.TypeOf(0), code: CodeInvalidType},
+
+		// Unsigned integers
+		{name: "uint8 max", value: float64(255), typ: reflect.TypeOf(uint8(0)), want: uint8(255)},
+		{name: "uint8 overflow", value: float64(256), typ: reflect.TypeOf(uint8(0)), code: CodeOutOfRange},
+		{name: "negative uint", value: float64(-1), typ: reflect.TypeOf(uint(0)), code: CodeOutOfRange},
+
+		// Floats
+		{name: "int to float", value: 3, typ: reflect.TypeOf(float64(0)), want: float64(3)},
+		{name: "number to float", value: json.Number("0.1"), typ: reflect.TypeOf(float64(0)), want: 0.1},
+		{name: "float32 overflow", value: 1e39, typ: reflect.TypeOf(float32(0)), code: CodeOutOfRange},
+		{name: "float32", value: 1.5, typ: reflect.TypeOf(float32(0)), want: float32(1.5)},
+		{name: "bool to float", value: true, typ: reflect.TypeOf(float64(0)), code: CodeInvalidType},
+
+		// Strings, booleans and named types
+		{name: "named string", value: "shipped", typ: reflect.TypeOf(status("")), want: status("shipped")},
+		{name: "number to string", value: float64(1), typ: reflect.TypeOf(""), code: CodeInvalidType},
+		{name: "string to bool", value: "true", typ: reflect.TypeOf(false), code: CodeInvalidType},
+
+		// Times
+		{name: "RFC 3339 time", value: "2024-01-02T03:04:05Z", typ: timeType, want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "date", value: "2024-01-02", typ: timeType, want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid time", value: "tomorrow", typ: timeType, code: CodeInvalid},
+		{name: "number to time", value: float64(1), typ: timeType, code: CodeInvalidType},
+
+		// Pointers
+		{name: "pointer", value: float64(5), typ: reflect.TypeOf(&five), want: &five},
+		{name: "pointer overflow", value: float64(300), typ: reflect.TypeOf(new(int8)), code: CodeOutOfRange},
+		{name: "nil pointer value", value: (*float64)(nil), typ: reflect.TypeOf(&five), want: nil},
+
+		// Other types must be assignable
+		{name: "slice", value: []interface{}{"a"}, typ: reflect.TypeOf([]interface{}{}), want: []interface{}{"a"}},
+		{name: "map to slice", value: map[string]interface{}{}, typ: reflect.TypeOf([]string{}), code: CodeInvalidType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Coerce(tt.value, tt.typ)
+			if tt.code != "" {
+				fieldErr, ok := err.(*FieldError)
+				if !ok {
+					t.Fatalf("Coerce() = %v, %v, want a *FieldError with code %s", got, err, tt.code)
+				}
+				if fieldErr.Code != tt.code {
+					t.Fatalf("Coerce() error code = %s (%v), want %s", fieldErr.Code, err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Coerce() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Coerce() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeCoerces(t *testing.T) {
+	s := newEventSerializer()
+
+	row, err := s.Deserialize(map[string]interface{}{"title": "Meetup", "venue": "Hall", "seats": json.Number("20")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := row.(map[string]interface{})["seats"]; got != float64(20) {
+		t.Errorf("seats = %#v, want 20.0", got)
+	}
+
+	_, err = s.Deserialize(map[string]interface{}{"title": "Meetup", "venue": "Hall", "seats": "many"})
+	if got, want := codes(t, err), []string{"seats:invalid_type"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package serializer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Error codes of FieldError. Field.ErrorMessages maps them to custom messages.
+const (
+	CodeRequired     = "required"
+	CodeInvalidType  = "invalid_type"
+	CodeInvalid      = "invalid"
+	CodeMaxLength    = "max_length"
+	CodeMinLength    = "min_length"
+	CodeOutOfRange   = "out_of_range"
+	CodeUnknownField = "unknown_field"
+	CodeDoesNotExist = "does_not_exist" // A related field references a missing row
+)
+
+// NonFieldErrors is the field of errors returned by object validators that
+// concern the data as a whole rather than one field
+const NonFieldErrors = "non_field_errors"
+
+// FieldError reports why the value of a field was rejected
+type FieldError struct {
+	Field   string
+	Code    string // Machine-readable reason, e.g. CodeRequired
+	Message string
+}
+
+// Error implements error
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Invalid returns the error of a validator, reported with the given code
+// instead of CodeInvalid, e.g. Invalid(CodeOutOfRange, "must be positive").
+// Object validators may set Field on the result to blame a field.
+func Invalid(code, format string, args ...interface{}) *FieldError {
+	return &FieldError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationErrors collects the errors of every field that failed validation
+type ValidationErrors []*FieldError
+
+// Error implements error
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of the fields
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fieldErr := range e {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// Field returns the errors of the named field
+func (e ValidationErrors) Field(name string) []*FieldError {
+	var errs []*FieldError
+	for _, fieldErr := range e {
+		if fieldErr.Field == name {
+			errs = append(errs, fieldErr)
+		}
+	}
+	return errs
+}
+
+// prefixed returns copies of the errors with their fields below path, e.g.
+// items[0].quantity
+func (e ValidationErrors) prefixed(path string) ValidationErrors {
+	errs := make(ValidationErrors, len(e))
+	for i, fieldErr := range e {
+		prefixedErr := *fieldErr
+		prefixedErr.Field = path + "." + fieldErr.Field
+		errs[i] = &prefixedErr
+	}
+	return errs
+}
+
+// err returns the errors as an error, or nil when there are none
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// fieldError returns the error of a field, with the field's custom message for
+// the code if it has one
+func fieldError(name string, field Field, code, message string) *FieldError {
+	if custom, ok := field.ErrorMessages[code]; ok {
+		message = custom
+	}
+	return &FieldError{Field: name, Code: code, Message: message}
+}
+
+// objectErrors converts the error of an object validator to field errors; an
+// error that does not name a field goes to NonFieldErrors
+func (s *Serializer) objectErrors(err error) ValidationErrors {
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return validationErrs
+	}
+
+	name, code, message := NonFieldErrors, CodeInvalid, err.Error()
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		code, message = fieldErr.Code, fieldErr.Message
+		if fieldErr.Field != "" {
+			name = fieldErr.Field
+		}
+	}
+	return ValidationErrors{fieldError(name, s.Fields[name], code, message)}
+}
+
+// sortedNames returns the keys of a map in order, so that errors are reported
+// in a stable order
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,494 @@
+package serializer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+)
+
+// Related describes a field whose value references a row of another table
+type Related struct {
+	ORM    *orm.ORM
+	Table  string // Referenced table
+	Column string // Column clients send and receive; the primary key when empty
+	Key    string // Column stored in the source field; the primary key when empty
+}
+
+// PrimaryKeyRelatedField references rows of table by primary key
+func PrimaryKeyRelatedField(db *orm.ORM, table string) *Related {
+	return &Related{ORM: db, Table: table}
+}
+
+// SlugRelatedField references rows of table by a unique column, such as the
+// SKU of a product. Clients send and receive the slug, while the source field
+// stores the primary key of the row.
+func SlugRelatedField(db *orm.ORM, table, slugColumn string) *Related {
+	return &Related{ORM: db, Table: table, Column: slugColumn}
+}
+
+// columns returns the column clients use and the column stored
+func (r *Related) columns() (column, key string) {
+	primaryKey := "id"
+	if model, ok := r.ORM.Models()[r.Table]; ok {
+		primaryKey = primaryKeyOf(model)
+	}
+	column, key = r.Column, r.Key
+	if column == "" {
+		column = primaryKey
+	}
+	if key == "" {
+		key = primaryKey
+	}
+	return column, key
+}
+
+// resolve looks up the row a client value references and returns its key, or
+// false when there is no such row
+func (r *Related) resolve(ctx context.Context, value interface{}) (interface{}, bool, error) {
+	column, key := r.columns()
+	row, err := r.ORM.Table(r.Table).WithContext(ctx).Where(column, "=", value).First()
+	if errors.Is(err, orm.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up %s: %w", r.Table, err)
+	}
+	return row[key], true, nil
+}
+
+// AddNested adds a field rendering rows of a relation of the model, named by
+// the field's source, with another serializer. Use WithMany for has-many
+// relations; their rows are also written by Create and Update unless the
+// field is read-only.
+func (s *Serializer) AddNested(name string, nested *Serializer, options ...func(*Field)) {
+	s.AddField(name, nil, append([]func(*Field){func(f *Field) { f.Nested = nested }}, options...)...)
+}
+
+// AddRelated adds a field referencing rows of another table; Validate checks
+// that the referenced row exists
+func (s *Serializer) AddRelated(name string, related *Related, options ...func(*Field)) {
+	s.AddField(name, nil, append([]func(*Field){func(f *Field) { f.Related = related }}, options...)...)
+}
+
+// WithMany sets a nested field to hold a list of rows
+func WithMany() func(*Field) {
+	return func(f *Field) {
+		f.Many = true
+	}
+}
+
+// relation returns the relation of the model with the given name, the source
+// of a nested field
+func (s *Serializer) relation(name string) (models.Relation, bool) {
+	provider, ok := s.Model.(models.RelationProvider)
+	if !ok {
+		return models.Relation{}, false
+	}
+	relation, ok := provider.GetRelations()[name]
+	return relation, ok
+}
+
+// belongsTo returns the belongs-to relation of the model through a foreign key
+func (s *Serializer) belongsTo(foreignKey string) (models.Relation, bool) {
+	provider, ok := s.Model.(models.RelationProvider)
+	if !ok {
+		return models.Relation{}, false
+	}
+	for _, relation := range provider.GetRelations() {
+		if relation.Type == models.BelongsTo && relation.ForeignKey == foreignKey {
+			return relation, true
+		}
+	}
+	return models.Relation{}, false
+}
+
+// Preloads returns the relations the serializer renders, as paths for
+// orm.QuerySet.Preload, so that lists do not query once per row
+func (s *Serializer) Preloads() []string {
+	var paths []string
+	for _, name := range sortedNames(s.Fields) {
+		field := s.Fields[name]
+		if field.WriteOnly {
+			continue
+		}
+		switch {
+		case field.Nested != nil:
+			paths = append(paths, field.SourceField)
+			for _, path := range field.Nested.Preloads() {
+				paths = append(paths, field.SourceField+"."+path)
+			}
+		case field.Related != nil:
+			// Slugs are read from the related row
+			if column, key := field.Related.columns(); column != key {
+				if relation, ok := s.belongsTo(field.SourceField); ok {
+					paths = append(paths, relation.Name)
+				}
+			}
+		case strings.Contains(field.SourceField, "."):
+			// user.email reads the preloaded user
+			path := field.SourceField[:strings.LastIndex(field.SourceField, ".")]
+			if _, ok := s.relation(strings.Split(path, ".")[0]); ok {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// serializeRelated renders the value of a related field: the stored key, or
+// the slug of the referenced row for slug related fields
+func (s *Serializer) serializeRelated(ctx context.Context, row reflect.Value, field Field, value interface{}) (interface{}, error) {
+	column, key := field.Related.columns()
+	if column == key || value == nil {
+		return value, nil
+	}
+
+	// Read the slug from the preloaded row, or look it up
+	if relation, ok := s.belongsTo(field.SourceField); ok {
+		if related, ok := valueOf(row, relation.Name); ok {
+			slug, _ := valueOf(reflect.ValueOf(related), column)
+			return slug, nil
+		}
+	}
+	related, err := field.Related.ORM.Table(field.Related.Table).WithContext(ctx).Where(key, "=", value).First()
+	if errors.Is(err, orm.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", field.Related.Table, err)
+	}
+	return related[column], nil
+}
+
+// serializeNested renders a related row, or a list of them
+func (s *Serializer) serializeNested(ctx context.Context, value interface{}, many bool) (interface{}, error) {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			break
+		}
+		val = val.Elem()
+	}
+
+	if !many {
+		if !val.IsValid() || ((val.Kind() == reflect.Ptr || val.Kind() == reflect.Map) && val.IsNil()) {
+			return nil, nil
+		}
+		return s.SerializeContext(ctx, val.Interface())
+	}
+
+	rows := []map[string]interface{}{}
+	if !val.IsValid() || val.Kind() == reflect.Ptr {
+		return rows, nil
+	}
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list of rows, got %v", val.Type())
+	}
+	for i := 0; i < val.Len(); i++ {
+		row, err := s.SerializeContext(ctx, val.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// valueOf returns the value of a column of a row, given as a map or a struct
+// whose fields are matched by json or db tag, or by name
+func valueOf(row reflect.Value, column string) (interface{}, bool) {
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		if row.IsNil() {
+			return nil, false
+		}
+		row = row.Elem()
+	}
+
+	switch row.Kind() {
+	case reflect.Map:
+		if row.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		value := row.MapIndex(reflect.ValueOf(column).Convert(row.Type().Key()))
+		if !value.IsValid() {
+			return nil, false
+		}
+		return value.Interface(), true
+	case reflect.Struct:
+		typ := row.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+				continue // Skip unexported fields and fields hidden from JSON
+			}
+			for _, tag := range []string{field.Tag.Get("json"), field.Tag.Get("db")} {
+				if name := strings.Split(tag, ",")[0]; name == column {
+					return row.Field(i).Interface(), true
+				}
+			}
+			if field.Name == column {
+				return row.Field(i).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// validateNested validates the rows of a writable nested field, reporting
+// their errors as e.g. items[0].quantity
+func (s *Serializer) validateNested(ctx context.Context, name string, field Field, value interface{}) (ValidationErrors, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	// The parent sets the foreign key of its children
+	var setByParent string
+	if relation, ok := s.relation(field.SourceField); ok && relation.Type == models.HasMany {
+		setByParent = relation.ForeignKey
+	}
+
+	validateRow := func(path string, value interface{}) (ValidationErrors, error) {
+		row, ok := value.(map[string]interface{})
+		if !ok {
+			return ValidationErrors{fieldError(path, field, CodeInvalidType, fmt.Sprintf("field %s must be an object", path))}, nil
+		}
+		errs, err := field.Nested.validate(ctx, row, false, setByParent)
+		return errs.prefixed(path), err
+	}
+
+	if !field.Many {
+		return validateRow(name, value)
+	}
+
+	rows, ok := value.([]interface{})
+	if !ok {
+		return ValidationErrors{fieldError(name, field, CodeInvalidType, fmt.Sprintf("field %s must be a list", name))}, nil
+	}
+	var errs ValidationErrors
+	for i, row := range rows {
+		rowErrs, err := validateRow(fmt.Sprintf("%s[%d]", name, i), row)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, rowErrs...)
+	}
+	return errs, nil
+}
+
+// validateRelated checks that the row a related field references exists
+func validateRelated(ctx context.Context, name string, field Field, value interface{}) (ValidationErrors, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return ValidationErrors{fieldError(name, field, CodeInvalidType,
+			fmt.Sprintf("field %s must reference a %s row", name, field.Related.Table))}, nil
+	}
+
+	_, found, err := field.Related.resolve(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		column, _ := field.Related.columns()
+		return ValidationErrors{fieldError(name, field, CodeDoesNotExist,
+			fmt.Sprintf("%s with %s %v does not exist", field.Related.Table, column, value))}, nil
+	}
+	return nil, nil
+}
+
+// buildNested converts the valid input of a nested field to rows
+func (f Field) buildNested(ctx context.Context, value interface{}) (interface{}, error) {
+	build := func(value interface{}) (map[string]interface{}, error) {
+		row, _ := value.(map[string]interface{})
+		return f.Nested.build(ctx, f.Nested.input(row), false)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	if !f.Many {
+		return build(value)
+	}
+	values, _ := value.([]interface{})
+	rows := make([]map[string]interface{}, 0, len(values))
+	for _, value := range values {
+		row, err := build(value)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Create validates data and inserts the record together with the rows of its
+// writable nested fields in one transaction. It returns the stored record.
+func (s *Serializer) Create(ctx context.Context, db *orm.ORM, data map[string]interface{}) (map[string]interface{}, error) {
+	row, err := s.deserialize(ctx, data, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored map[string]interface{}
+	err = db.Transaction(ctx, func(tx *orm.Tx) error {
+		stored, err = s.save(tx, nil, row)
+		return err
+	})
+	return stored, err
+}
+
+// Update validates data and updates the record together with the rows of its
+// writable nested fields in one transaction. Nested rows with a primary key
+// are updated, rows without one are created, and the record's other rows are
+// deleted. A partial update leaves out fields missing from data, including
+// nested ones. It returns the stored record.
+func (s *Serializer) Update(ctx context.Context, db *orm.ORM, id interface{}, data map[string]interface{}, partial bool) (map[string]interface{}, error) {
+	row, err := s.deserialize(ctx, data, partial)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored map[string]interface{}
+	err = db.Transaction(ctx, func(tx *orm.Tx) error {
+		stored, err = s.save(tx, id, row)
+		return err
+	})
+	return stored, err
+}
+
+// save inserts a row, or updates the row with the given primary key, and
+// writes the rows of its nested fields
+func (s *Serializer) save(tx *orm.Tx, id interface{}, row map[string]interface{}) (map[string]interface{}, error) {
+	tableName := s.Model.GetTableName()
+
+	columns := make(map[string]interface{}, len(row))
+	nested := make(map[string]Field)
+	for column, value := range row {
+		if name, field, ok := s.nestedField(column); ok {
+			nested[name] = field
+			continue
+		}
+		columns[column] = value
+	}
+
+	var stored map[string]interface{}
+	var err error
+	if id == nil {
+		stored, err = tx.Insert(tableName, columns)
+	} else {
+		if len(columns) > 0 {
+			if err := tx.Update(tableName, id, columns); err != nil {
+				return nil, err
+			}
+		}
+		stored, err = tx.Get(tableName, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedNames(nested) {
+		field := nested[name]
+		relation, ok := s.relation(field.SourceField)
+		if !ok || relation.Type != models.HasMany || !field.Many {
+			return nil, fmt.Errorf("nested field %s is not a has-many relation of %s and must be read-only", name, tableName)
+		}
+		rows, _ := row[field.SourceField].([]map[string]interface{})
+		if err := field.Nested.saveChildren(tx, name, relation, stored[relation.References], rows, id != nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return stored, nil
+}
+
+// saveChildren writes the rows of a has-many relation of a parent. When
+// replace is set, the parent's existing rows missing from rows are deleted.
+func (s *Serializer) saveChildren(tx *orm.Tx, name string, relation models.Relation, parentKey interface{}, rows []map[string]interface{}, replace bool) error {
+	tableName := s.Model.GetTableName()
+	primaryKey := primaryKeyOf(s.Model)
+
+	existing := make(map[string]interface{})
+	if replace {
+		current, err := tx.Table(tableName).Where(relation.ForeignKey, "=", parentKey).All()
+		if err != nil {
+			return err
+		}
+		for _, row := range current {
+			existing[fmt.Sprint(row[primaryKey])] = row[primaryKey]
+		}
+	}
+
+	var errs ValidationErrors
+	kept := make(map[string]bool)
+	for i, row := range rows {
+		child := make(map[string]interface{}, len(row)+1)
+		for column, value := range row {
+			child[column] = value
+		}
+		child[relation.ForeignKey] = parentKey
+
+		id, hasID := child[primaryKey]
+		delete(child, primaryKey)
+		if !hasID || id == nil {
+			if _, err := s.save(tx, nil, child); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := fmt.Sprint(id)
+		storedID, ok := existing[key]
+		if !ok {
+			errs = append(errs, &FieldError{
+				Field:   fmt.Sprintf("%s[%d].%s", name, i, primaryKey),
+				Code:    CodeDoesNotExist,
+				Message: fmt.Sprintf("%s with %s %v does not belong to this record", tableName, primaryKey, id),
+			})
+			continue
+		}
+		kept[key] = true
+		if _, err := s.save(tx, storedID, child); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, key := range sortedNames(existing) {
+		if !kept[key] {
+			if err := tx.Delete(tableName, existing[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nestedField returns the writable nested field stored under a source field
+func (s *Serializer) nestedField(source string) (string, Field, bool) {
+	for name, field := range s.Fields {
+		if field.Nested != nil && !field.ReadOnly && field.SourceField == source {
+			return name, field, true
+		}
+	}
+	return "", Field{}, false
+}
+
+// primaryKeyOf returns the primary key column of a model, "id" if it has none
+func primaryKeyOf(model models.ModelInterface) string {
+	for _, name := range sortedNames(model.GetFields()) {
+		if model.GetFields()[name].PrimaryKey {
+			return name
+		}
+	}
+	return "id"
+}
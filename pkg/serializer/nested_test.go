@@ -0,0 +1,344 @@
+package serializer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/baxromov/framego/pkg/models"
+	"github.com/baxromov/framego/pkg/orm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openShop returns an in-memory ORM with customers, products, orders and
+// order items, and a serializer of orders embedding their items. An order
+// cannot hold the same product twice.
+func openShop(t *testing.T) (*orm.ORM, *Serializer) {
+	t.Helper()
+	// One connection, as every connection opens a new in-memory database
+	o, err := orm.New(orm.Config{Driver: "sqlite3", Database: ":memory:", MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	for _, stmt := range []string{
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE products (id INTEGER PRIMARY KEY, sku TEXT NOT NULL UNIQUE)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER NOT NULL, note TEXT)",
+		"CREATE TABLE order_items (id INTEGER PRIMARY KEY, order_id INTEGER NOT NULL, product_id INTEGER NOT NULL, quantity INTEGER NOT NULL, UNIQUE (order_id, product_id))",
+		"INSERT INTO customers (id, name) VALUES (1, 'Ann'), (2, 'Bob')",
+		"INSERT INTO products (id, sku) VALUES (1, 'PEN'), (2, 'INK'), (3, 'PAD')",
+	} {
+		if _, err := o.DB().Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	customers := models.NewModel("customers")
+	customers.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	customers.AddField("name", reflect.TypeOf(""), models.WithNotNull())
+	products := models.NewModel("products")
+	products.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	products.AddField("sku", reflect.TypeOf(""), models.WithNotNull(), models.WithUnique())
+	orders := models.NewModel("orders")
+	orders.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	orders.AddField("customer_id", reflect.TypeOf(0), models.WithNotNull())
+	orders.AddField("note", reflect.TypeOf(""))
+	orders.BelongsTo("customer", "customers", "customer_id")
+	orders.HasMany("items", "order_items", "order_id")
+	items := models.NewModel("order_items")
+	items.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	items.AddField("order_id", reflect.TypeOf(0), models.WithNotNull())
+	items.AddField("product_id", reflect.TypeOf(0), models.WithNotNull())
+	items.AddField("quantity", reflect.TypeOf(0), models.WithNotNull())
+	items.BelongsTo("product", "products", "product_id")
+	for _, model := range []*models.Model{customers, products, orders, items} {
+		if err := o.RegisterModel(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	itemSerializer := New(items)
+	itemSerializer.AddRelated("product_id", SlugRelatedField(o, "products", "sku"), WithRequired())
+	quantity := itemSerializer.Fields["quantity"]
+	quantity.Validators = append(quantity.Validators, RangeValidator(1, 100))
+	itemSerializer.Fields["quantity"] = quantity
+
+	orderSerializer := New(orders)
+	orderSerializer.AddRelated("customer_id", PrimaryKeyRelatedField(o, "customers"), WithRequired())
+	orderSerializer.AddNested("items", itemSerializer, WithMany())
+	return o, orderSerializer
+}
+
+// orderItems returns the items of an order as product_id:quantity
+func orderItems(t *testing.T, o *orm.ORM, orderID interface{}) map[int64]int64 {
+	t.Helper()
+	rows, err := o.Table("order_items").Where("order_id", "=", orderID).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		items[row["product_id"].(int64)] = row["quantity"].(int64)
+	}
+	return items
+}
+
+// count returns the number of rows of a table
+func count(t *testing.T, o *orm.ORM, table string) int64 {
+	t.Helper()
+	n, err := o.Table(table).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNestedCreate(t *testing.T) {
+	o, s := openShop(t)
+
+	stored, err := s.Create(context.Background(), o, map[string]interface{}{
+		"customer_id": 1,
+		"note":        "gift",
+		"items": []interface{}{
+			map[string]interface{}{"product_id": "PEN", "quantity": 2},
+			map[string]interface{}{"product_id": "INK", "quantity": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored["customer_id"] != int64(1) || stored["note"] != "gift" {
+		t.Errorf("Create() = %v, want the stored order", stored)
+	}
+	if got, want := orderItems(t, o, stored["id"]), map[int64]int64{1: 2, 2: 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+}
+
+func TestNestedValidation(t *testing.T) {
+	o, s := openShop(t)
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want []string // Errors as field:code
+	}{
+		{
+			name: "invalid items",
+			data: map[string]interface{}{"customer_id": 1, "items": []interface{}{
+				map[string]interface{}{"product_id": "PEN", "quantity": 2},
+				map[string]interface{}{"product_id": "PEN", "quantity": 0},
+				map[string]interface{}{"product_id": "CUP", "quantity": 1, "colour": "red"},
+			}},
+			want: []string{"items[1].quantity:out_of_range", "items[2].colour:unknown_field", "items[2].product_id:does_not_exist"},
+		},
+		{
+			name: "missing item fields",
+			data: map[string]interface{}{"customer_id": 1, "items": []interface{}{map[string]interface{}{}}},
+			want: []string{"items[0].product_id:required", "items[0].quantity:required"},
+		},
+		{
+			name: "missing customer",
+			data: map[string]interface{}{"customer_id": 9, "items": []interface{}{}},
+			want: []string{"customer_id:does_not_exist"},
+		},
+		{
+			name: "customer given as an object",
+			data: map[string]interface{}{"customer_id": map[string]interface{}{"id": 1}},
+			want: []string{"customer_id:invalid_type"},
+		},
+		{
+			name: "items not a list",
+			data: map[string]interface{}{"customer_id": 1, "items": map[string]interface{}{"product_id": "PEN"}},
+			want: []string{"items:invalid_type"},
+		},
+		{
+			name: "item not an object",
+			data: map[string]interface{}{"customer_id": 1, "items": []interface{}{"PEN"}},
+			want: []string{"items[0]:invalid_type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Create(context.Background(), o, tt.data)
+			if got := codes(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+			if n := count(t, o, "orders"); n != 0 {
+				t.Errorf("%d orders stored, want none", n)
+			}
+		})
+	}
+}
+
+func TestNestedCreateRollback(t *testing.T) {
+	o, s := openShop(t)
+
+	// The second item breaks the unique constraint after the order and the
+	// first item are inserted
+	_, err := s.Create(context.Background(), o, map[string]interface{}{
+		"customer_id": 1,
+		"items": []interface{}{
+			map[string]interface{}{"product_id": "PEN", "quantity": 2},
+			map[string]interface{}{"product_id": "PEN", "quantity": 3},
+		},
+	})
+	if !errors.Is(err, orm.ErrUniqueViolation) {
+		t.Fatalf("Create() error = %v, want %v", err, orm.ErrUniqueViolation)
+	}
+	for _, table := range []string{"orders", "order_items"} {
+		if n := count(t, o, table); n != 0 {
+			t.Errorf("%d %s stored, want none", n, table)
+		}
+	}
+}
+
+func TestNestedUpdate(t *testing.T) {
+	ctx := context.Background()
+	o, s := openShop(t)
+	create := func(customerID int, items ...interface{}) map[string]interface{} {
+		t.Helper()
+		stored, err := s.Create(ctx, o, map[string]interface{}{"customer_id": customerID, "note": "first", "items": items})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	itemID := func(orderID interface{}, productID int) int {
+		t.Helper()
+		row, err := o.Table("order_items").Where("order_id", "=", orderID).Where("product_id", "=", productID).First()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(row["id"].(int64))
+	}
+
+	t.Run("replaces items", func(t *testing.T) {
+		order := create(1,
+			map[string]interface{}{"product_id": "PEN", "quantity": 2},
+			map[string]interface{}{"product_id": "INK", "quantity": 1},
+		)
+		stored, err := s.Update(ctx, o, order["id"], map[string]interface{}{
+			"customer_id": 2,
+			"items": []interface{}{
+				map[string]interface{}{"id": itemID(order["id"], 1), "product_id": "PEN", "quantity": 5},
+				map[string]interface{}{"product_id": "PAD", "quantity": 1},
+			},
+		}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored["customer_id"] != int64(2) {
+			t.Errorf("Update() = %v, want customer 2", stored)
+		}
+		if got, want := orderItems(t, o, order["id"]), map[int64]int64{1: 5, 3: 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("items = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("partial update keeps items", func(t *testing.T) {
+		order := create(1, map[string]interface{}{"product_id": "PEN", "quantity": 2})
+		if _, err := s.Update(ctx, o, order["id"], map[string]interface{}{"note": "second"}, true); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := orderItems(t, o, order["id"]), map[int64]int64{1: 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("items = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("item of another order rolls back", func(t *testing.T) {
+		order := create(1, map[string]interface{}{"product_id": "PEN", "quantity": 2})
+		other := create(2, map[string]interface{}{"product_id": "INK", "quantity": 1})
+
+		_, err := s.Update(ctx, o, order["id"], map[string]interface{}{
+			"note": "second",
+			"items": []interface{}{
+				map[string]interface{}{"product_id": "PAD", "quantity": 4},
+				map[string]interface{}{"id": itemID(other["id"], 2), "product_id": "INK", "quantity": 9},
+			},
+		}, true)
+		if got, want := codes(t, err), []string{"items[1].id:does_not_exist"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("errors = %q, want %q", got, want)
+		}
+
+		stored, err := o.Get("orders", order["id"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored["note"] != "first" {
+			t.Errorf("note = %v, want the update rolled back", stored["note"])
+		}
+		if got, want := orderItems(t, o, order["id"]), map[int64]int64{1: 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("items = %v, want %v", got, want)
+		}
+		if got, want := orderItems(t, o, other["id"]), map[int64]int64{2: 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("other order's items = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestNestedSerialize(t *testing.T) {
+	o, s := openShop(t)
+
+	if got, want := s.Preloads(), []string{"items", "items.product"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Preloads() = %q, want %q", got, want)
+	}
+
+	order, err := s.Create(context.Background(), o, map[string]interface{}{
+		"customer_id": 1,
+		"items":       []interface{}{map[string]interface{}{"product_id": "INK", "quantity": 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Slugs are read from preloaded rows, or looked up without them
+	preloaded, err := o.Table("orders").Preload(s.Preloads()...).Where("id", "=", order["id"]).First()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, row := range map[string]map[string]interface{}{"preloaded": preloaded, "items only": nil} {
+		t.Run(name, func(t *testing.T) {
+			if row == nil {
+				row = make(map[string]interface{}, len(order)+1)
+				for column, value := range order {
+					row[column] = value
+				}
+				row["items"] = orderItemRows(t, o, order["id"])
+			}
+			got, err := s.Serialize(row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items, _ := got["items"].([]map[string]interface{})
+			if len(items) != 1 || items[0]["product_id"] != "INK" || items[0]["quantity"] != int64(3) {
+				t.Errorf("items = %v, want the INK item", got["items"])
+			}
+			if got["customer_id"] != int64(1) {
+				t.Errorf("customer_id = %v, want 1", got["customer_id"])
+			}
+		})
+	}
+
+	// Orders read without their items render none
+	got, err := s.Serialize(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["items"]; ok {
+		t.Errorf("items = %v, want none without the relation loaded", got["items"])
+	}
+}
+
+// orderItemRows returns the item rows of an order
+func orderItemRows(t *testing.T, o *orm.ORM, orderID interface{}) []map[string]interface{} {
+	t.Helper()
+	rows, err := o.Table("order_items").Where("order_id", "=", orderID).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
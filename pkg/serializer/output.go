@@ -0,0 +1,136 @@
+package serializer
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MethodFunc computes the value of a field from the instance being
+// serialized, given the context of the request
+type MethodFunc func(ctx context.Context, instance interface{}) (interface{}, error)
+
+// Formatter formats the value of a field for output
+type Formatter func(value interface{}) interface{}
+
+// AddMethod adds a read-only field whose value is computed by method, e.g.
+// whether the order belongs to the current user. Use Value to read columns of
+// the instance.
+func (s *Serializer) AddMethod(name string, method MethodFunc, options ...func(*Field)) {
+	s.AddField(name, nil, append([]func(*Field){func(f *Field) {
+		f.Method = method
+		f.ReadOnly = true
+	}}, options...)...)
+}
+
+// WithFormat sets the function formatting the value of the field for output
+func WithFormat(format Formatter) func(*Field) {
+	return func(f *Field) {
+		f.Format = format
+	}
+}
+
+// WithTimeLayout formats time values of the field with the given layout,
+// e.g. "2006-01-02"
+func WithTimeLayout(layout string) func(*Field) {
+	return WithFormat(func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format(layout)
+		case *time.Time:
+			if v != nil {
+				return v.Format(layout)
+			}
+		}
+		return value
+	})
+}
+
+// WithDecimalPlaces renders numbers of the field with the given number of
+// decimal places, e.g. 12.5 as 12.50; they stay numbers in JSON
+func WithDecimalPlaces(places int) func(*Field) {
+	return WithFormat(func(value interface{}) interface{} {
+		val := reflect.ValueOf(value)
+		for val.Kind() == reflect.Ptr && !val.IsNil() {
+			val = val.Elem()
+		}
+		switch val.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return json.Number(strconv.FormatFloat(val.Float(), 'f', places, 64))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return json.Number(strconv.FormatFloat(float64(val.Int()), 'f', places, 64))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return json.Number(strconv.FormatFloat(float64(val.Uint()), 'f', places, 64))
+		}
+		return value
+	})
+}
+
+// format formats a value of the field for output
+func (f Field) format(value interface{}) interface{} {
+	if f.Format == nil || value == nil {
+		return value
+	}
+	return f.Format(value)
+}
+
+// Value returns the value at a path of an instance, a map or a struct, such
+// as "email" or "user.email" for a preloaded relation
+func Value(instance interface{}, path string) (interface{}, bool) {
+	return lookup(reflect.ValueOf(instance), path)
+}
+
+// lookup returns the value at a dotted path of a row
+func lookup(row reflect.Value, path string) (interface{}, bool) {
+	var value interface{}
+	for _, column := range strings.Split(path, ".") {
+		var ok bool
+		if value, ok = valueOf(row, column); !ok {
+			return nil, false
+		}
+		row = reflect.ValueOf(value)
+	}
+	return value, true
+}
+
+// selectionKey is the context key of the fields selected by SelectFields
+type selectionKey struct{}
+
+// selection lists the fields a serializer renders
+type selection struct {
+	fields  map[string]bool // Every field when empty
+	exclude map[string]bool
+}
+
+// SelectFields returns a copy of ctx in which SerializeContext renders only
+// fields, unless none are given, and none of exclude. The selection applies to
+// the top-level serializer only, and unknown names are ignored.
+func SelectFields(ctx context.Context, fields, exclude []string) context.Context {
+	set := func(names []string) map[string]bool {
+		m := make(map[string]bool, len(names))
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				m[name] = true
+			}
+		}
+		return m
+	}
+	return context.WithValue(ctx, selectionKey{}, &selection{fields: set(fields), exclude: set(exclude)})
+}
+
+// selectionFrom returns the selection of ctx, or nil for every field
+func selectionFrom(ctx context.Context) *selection {
+	selected, _ := ctx.Value(selectionKey{}).(*selection)
+	return selected
+}
+
+// includes reports whether the field is selected
+func (s *selection) includes(name string) bool {
+	if s == nil {
+		return true
+	}
+	return (len(s.fields) == 0 || s.fields[name]) && !s.exclude[name]
+}
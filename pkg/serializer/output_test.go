@@ -0,0 +1,180 @@
+package serializer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// userKey is the context key of the current user in the tests
+type userKey struct{}
+
+// newOrderSerializer returns a serializer of orders rendering their
+// customer's email, whether the current user placed the order, and its lines
+func newOrderSerializer() *Serializer {
+	orders := models.NewModel("orders")
+	orders.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	orders.AddField("customer_id", reflect.TypeOf(0), models.WithNotNull())
+	orders.AddField("total", reflect.TypeOf(0.0))
+	orders.AddField("placed_at", reflect.TypeOf(time.Time{}))
+	orders.BelongsTo("customer", "customers", "customer_id")
+	orders.HasMany("lines", "order_lines", "order_id")
+
+	lines := models.NewModel("order_lines")
+	lines.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey())
+	lines.AddField("sku", reflect.TypeOf(""))
+
+	s := New(orders)
+	s.AddField("total", reflect.TypeOf(0.0), WithDecimalPlaces(2))
+	s.AddField("placed_at", reflect.TypeOf(time.Time{}), WithTimeLayout("2006-01-02"))
+	s.AddField("email", reflect.TypeOf(""), WithSourceField("customer.email"), WithReadOnly())
+	s.AddMethod("is_mine", func(ctx context.Context, instance interface{}) (interface{}, error) {
+		customerID, _ := Value(instance, "customer_id")
+		return customerID == ctx.Value(userKey{}), nil
+	})
+	s.AddNested("lines", New(lines), WithMany(), WithReadOnly())
+	return s
+}
+
+func TestSerializeContext(t *testing.T) {
+	s := newOrderSerializer()
+	placed := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+	order := map[string]interface{}{
+		"id":          7,
+		"customer_id": 1,
+		"total":       12.5,
+		"placed_at":   placed,
+		"customer":    map[string]interface{}{"id": 1, "email": "ann@example.com"},
+		"lines":       []map[string]interface{}{{"id": 1, "sku": "PEN"}},
+	}
+	ctx := context.WithValue(context.Background(), userKey{}, 1)
+
+	got, err := s.SerializeContext(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":          7,
+		"customer_id": 1,
+		"total":       json.Number("12.50"),
+		"placed_at":   "2024-03-09",
+		"email":       "ann@example.com",
+		"is_mine":     true,
+		"lines":       []map[string]interface{}{{"id": 1, "sku": "PEN"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SerializeContext() = %v, want %v", got, want)
+	}
+
+	// Paths missing from the instance are left out
+	delete(order, "customer")
+	got, err = s.Serialize(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["email"]; ok || got["is_mine"] != false {
+		t.Errorf("Serialize() = %v, want no email and is_mine false", got)
+	}
+
+	if got, want := s.Preloads(), []string{"customer", "lines"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Preloads() = %q, want %q", got, want)
+	}
+}
+
+func TestSerializeStruct(t *testing.T) {
+	type customer struct {
+		Email string `json:"email"`
+	}
+	type order struct {
+		ID       int       `json:"id"`
+		Total    float64   `db:"total"`
+		Secret   string    `json:"-"`
+		Customer *customer `json:"customer"`
+	}
+	s := New(models.NewModel("orders"))
+	s.AddField("id", reflect.TypeOf(0))
+	s.AddField("total", reflect.TypeOf(0.0), WithDecimalPlaces(1))
+	s.AddField("Secret", reflect.TypeOf(""))
+	s.AddField("email", reflect.TypeOf(""), WithSourceField("customer.email"))
+
+	got, err := s.Serialize(&order{ID: 3, Total: 4, Secret: "hidden", Customer: &customer{Email: "bob@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"id": 3, "total": json.Number("4.0"), "email": "bob@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Serialize() = %v, want %v", got, want)
+	}
+
+	// A nil relation has no fields
+	got, err = s.Serialize(order{ID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["email"]; ok {
+		t.Errorf("email = %v, want none for a nil customer", got["email"])
+	}
+}
+
+func TestMethodFieldError(t *testing.T) {
+	s := New(models.NewModel("orders"))
+	s.AddMethod("status", func(ctx context.Context, instance interface{}) (interface{}, error) {
+		return nil, errors.New("no status")
+	})
+	if _, err := s.Serialize(map[string]interface{}{}); err == nil || err.Error() != "field status: no status" {
+		t.Errorf("Serialize() error = %v, want the method's error", err)
+	}
+	// Method fields are read-only
+	row, err := s.Deserialize(map[string]interface{}{"status": "paid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := row.(map[string]interface{}); len(got) != 0 {
+		t.Errorf("Deserialize() = %v, want no columns", got)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	s := newOrderSerializer()
+	order := map[string]interface{}{
+		"id":          7,
+		"customer_id": 1,
+		"total":       12.5,
+		"lines":       []map[string]interface{}{{"id": 1, "sku": "PEN"}},
+	}
+
+	tests := []struct {
+		name    string
+		fields  []string
+		exclude []string
+		want    []string // Names of the fields rendered
+	}{
+		{name: "none", want: []string{"customer_id", "id", "is_mine", "lines", "total"}},
+		{name: "fields", fields: []string{"id", " total", ""}, want: []string{"id", "total"}},
+		{name: "exclude", exclude: []string{"lines", "is_mine"}, want: []string{"customer_id", "id", "total"}},
+		{name: "fields and exclude", fields: []string{"id", "total"}, exclude: []string{"total"}, want: []string{"id"}},
+		{name: "unknown names are ignored", fields: []string{"id", "colour"}, exclude: []string{"size"}, want: []string{"id"}},
+		{name: "nested fields are not selected", fields: []string{"lines"}, want: []string{"lines"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.SerializeContext(SelectFields(context.Background(), tt.fields, tt.exclude), order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := sortedNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("fields = %q, want %q", names, tt.want)
+			}
+			// The selection applies to the top-level serializer only
+			if lines, ok := got["lines"].([]map[string]interface{}); ok && len(lines[0]) != 2 {
+				t.Errorf("lines = %v, want every field of the lines", lines)
+			}
+		})
+	}
+}
@@ -1,10 +1,11 @@
 package serializer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
-	"time"
 
 	"github.com/baxromov/framego/pkg/models"
 )
@@ -20,15 +21,26 @@ type Field struct {
 	Validators    []Validator
 	SourceField   string
 	ErrorMessages map[string]string
+	Nested        *Serializer // Serializes the related rows under SourceField, see AddNested
+	Many          bool        // The nested value is a list of rows
+	Related       *Related    // The value references a row of another table, see AddRelated
+	Method        MethodFunc  // Computes the value of a read-only field, see AddMethod
+	Format        Formatter   // Formats the value of the field for output
 }
 
 // Validator defines a function that validates a field value
 type Validator func(value interface{}) error
 
+// ObjectValidator validates fields against each other, e.g. that an end date
+// follows a start date. It runs once every field is valid, on the input data;
+// for a partial update, data holds only the fields supplied.
+type ObjectValidator func(data map[string]interface{}) error
+
 // Serializer represents a serializer for a model
 type Serializer struct {
-	Model  models.ModelInterface
-	Fields map[string]Field
+	Model      models.ModelInterface
+	Fields     map[string]Field
+	Validators []ObjectValidator
 }
 
 // New creates a new serializer for the given model
@@ -40,10 +52,13 @@ func New(model models.ModelInterface) *Serializer {
 
 	// Auto-generate fields from model
 	for name, modelField := range model.GetFields() {
+		// Timestamps and the deletion time are set by the ORM, not by clients
+		managed := modelField.AutoNow || modelField.AutoNowAdd || modelField.SoftDelete
 		field := Field{
 			Name:        name,
 			Type:        modelField.Type,
-			Required:    modelField.NotNull && modelField.Default == nil,
+			Required:    modelField.NotNull && modelField.Default == nil && !managed,
+			ReadOnly:    managed,
 			SourceField: name,
 		}
 
@@ -74,6 +89,12 @@ func (s *Serializer) AddField(name string, fieldType reflect.Type, options ...fu
 	s.Fields[name] = field
 }
 
+// AddValidator adds an object-level validator. An error naming a field, see
+// Invalid, is reported for that field; other errors under NonFieldErrors.
+func (s *Serializer) AddValidator(validator ObjectValidator) {
+	s.Validators = append(s.Validators, validator)
+}
+
 // WithRequired sets the field as required
 func WithRequired() func(*Field) {
 	return func(f *Field) {
@@ -116,7 +137,8 @@ func WithSourceField(sourceField string) func(*Field) {
 	}
 }
 
-// WithErrorMessages sets custom error messages for the field
+// WithErrorMessages sets custom error messages for the field, keyed by error
+// code, e.g. {"required": "please enter a name"}
 func WithErrorMessages(messages map[string]string) func(*Field) {
 	return func(f *Field) {
 		f.ErrorMessages = messages
@@ -125,6 +147,14 @@ func WithErrorMessages(messages map[string]string) func(*Field) {
 
 // Serialize converts a model instance to a map
 func (s *Serializer) Serialize(data interface{}) (map[string]interface{}, error) {
+	return s.SerializeContext(context.Background(), data)
+}
+
+// SerializeContext converts a model instance to a map for a request. Each
+// field is read from its source, a column or a dotted path such as
+// user.email, and fields missing from the instance are left out. Method
+// fields receive ctx, and SelectFields limits the fields rendered.
+func (s *Serializer) SerializeContext(ctx context.Context, data interface{}) (map[string]interface{}, error) {
 	val := reflect.ValueOf(data)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -134,152 +164,230 @@ func (s *Serializer) Serialize(data interface{}) (map[string]interface{}, error)
 		return nil, fmt.Errorf("data must be a struct, a map, or a pointer to a struct")
 	}
 
+	// The selection applies to this serializer, not to nested ones
+	selected := selectionFrom(ctx)
+	nestedCtx := ctx
+	if selected != nil {
+		nestedCtx = context.WithValue(ctx, selectionKey{}, (*selection)(nil))
+	}
+
 	result := make(map[string]interface{})
+	for _, name := range sortedNames(s.Fields) {
+		field := s.Fields[name]
+		if field.WriteOnly || !selected.includes(name) {
+			continue // Skip write-only and unselected fields
+		}
 
-	// Handle struct
-	if val.Kind() == reflect.Struct {
-		typ := val.Type()
-		for i := 0; i < val.NumField(); i++ {
-			field := typ.Field(i)
-			if field.PkgPath != "" {
-				continue // Skip unexported fields
+		if field.Method != nil {
+			value, err := field.Method(ctx, data)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
 			}
+			result[name] = field.format(value)
+			continue
+		}
 
-			// Use JSON tag if available, otherwise use field name
-			name := field.Name
-			tag := field.Tag.Get("json")
-			if tag != "" {
-				parts := strings.Split(tag, ",")
-				if parts[0] != "-" {
-					name = parts[0]
-				} else {
-					continue // Skip fields with json:"-"
-				}
-			}
+		value, ok := lookup(val, field.SourceField)
+		if !ok {
+			continue // Skip fields missing from the instance
+		}
 
-			// Check if field is in serializer fields
-			if serializerField, ok := s.Fields[name]; ok {
-				if serializerField.WriteOnly {
-					continue // Skip write-only fields
-				}
-				result[name] = val.Field(i).Interface()
+		switch {
+		case field.Nested != nil:
+			nested, err := field.Nested.serializeNested(nestedCtx, value, field.Many)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
 			}
-		}
-	} else if val.Kind() == reflect.Map {
-		// Handle map
-		for _, key := range val.MapKeys() {
-			name := key.String()
-			if serializerField, ok := s.Fields[name]; ok {
-				if serializerField.WriteOnly {
-					continue // Skip write-only fields
-				}
-				result[name] = val.MapIndex(key).Interface()
+			result[name] = nested
+		case field.Related != nil:
+			related, err := s.serializeRelated(ctx, val, field, value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
 			}
+			result[name] = field.format(related)
+		default:
+			result[name] = field.format(value)
 		}
 	}
 
 	return result, nil
 }
 
-// Deserialize converts a map to a model instance
+// Deserialize converts a map to a model instance, reporting every invalid
+// field in ValidationErrors. Fields the serializer does not know and read-only
+// fields are skipped, and slugs of related fields are resolved to keys.
 func (s *Serializer) Deserialize(data map[string]interface{}) (interface{}, error) {
-	result := make(map[string]interface{})
-
-	// Apply defaults
-	for name, field := range s.Fields {
-		if field.ReadOnly {
-			continue // Skip read-only fields
-		}
+	return s.deserialize(context.Background(), s.input(data), false)
+}
 
-		// If field has a default value and is not in data, use default
-		if field.Default != nil {
-			if _, ok := data[name]; !ok {
-				result[field.SourceField] = field.Default
-			}
-		}
+// deserialize validates data and converts it to a row, with the rows of
+// writable nested fields under their source fields
+func (s *Serializer) deserialize(ctx context.Context, data map[string]interface{}, partial bool) (map[string]interface{}, error) {
+	errs, err := s.validate(ctx, data, partial, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
+	return s.build(ctx, s.input(data), partial)
+}
 
-	// Copy data to result, validating as we go
+// input returns the fields of data that clients may write; fields read from a
+// dotted path are read-only
+func (s *Serializer) input(data map[string]interface{}) map[string]interface{} {
+	input := make(map[string]interface{}, len(data))
 	for name, value := range data {
-		field, ok := s.Fields[name]
-		if !ok {
-			continue // Skip fields not in serializer
+		if field, ok := s.Fields[name]; ok && !field.ReadOnly && !strings.Contains(field.SourceField, ".") {
+			input[name] = value
 		}
+	}
+	return input
+}
 
-		if field.ReadOnly {
-			continue // Skip read-only fields
-		}
+// build converts valid input to a row, applying defaults unless partial is set
+func (s *Serializer) build(ctx context.Context, input map[string]interface{}, partial bool) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
 
-		// Validate field
-		if err := s.validateField(name, value); err != nil {
-			return nil, err
+	// Apply defaults
+	if !partial {
+		for name, field := range s.Fields {
+			if _, ok := input[name]; !ok && field.Default != nil && !field.ReadOnly {
+				result[field.SourceField] = field.Default
+			}
 		}
-
-		result[field.SourceField] = value
 	}
 
-	// Check required fields
-	for name, field := range s.Fields {
-		if field.Required {
-			if _, ok := result[field.SourceField]; !ok {
-				return nil, fmt.Errorf("field %s is required", name)
+	for name, value := range input {
+		field := s.Fields[name]
+		switch {
+		case field.Nested != nil:
+			rows, err := field.buildNested(ctx, value)
+			if err != nil {
+				return nil, err
+			}
+			result[field.SourceField] = rows
+		case field.Related != nil && value != nil:
+			key, _, err := field.Related.resolve(ctx, value)
+			if err != nil {
+				return nil, err
 			}
+			result[field.SourceField] = key
+		default:
+			// Store the value converted to the field's type
+			coerced, err := field.coerce(name, value)
+			if err != nil {
+				return nil, err
+			}
+			result[field.SourceField] = coerced
 		}
 	}
 
 	return result, nil
 }
 
-// validateField validates a field value
-func (s *Serializer) validateField(name string, value interface{}) error {
+// validateField validates a field value and returns it converted to the
+// field's type, see Coerce
+func (s *Serializer) validateField(name string, value interface{}) (interface{}, *FieldError) {
 	field, ok := s.Fields[name]
 	if !ok {
-		return fmt.Errorf("field %s not found", name)
+		return nil, &FieldError{Field: name, Code: CodeUnknownField, Message: fmt.Sprintf("field %s not found", name)}
 	}
 
-	// Type validation
-	valueType := reflect.TypeOf(value)
-	if valueType != field.Type && value != nil {
-		// Special case for time.Time
-		if field.Type == reflect.TypeOf(time.Time{}) {
-			if _, ok := value.(string); !ok {
-				return fmt.Errorf("field %s has invalid type: expected time.Time or string, got %v", name, valueType)
-			}
-			// TODO: Parse string to time.Time
-		} else {
-			return fmt.Errorf("field %s has invalid type: expected %v, got %v", name, field.Type, valueType)
-		}
+	// Type conversion; nested and related fields have no type
+	value, err := field.coerce(name, value)
+	if err != nil {
+		return nil, err
 	}
 
 	// Run validators
 	for _, validator := range field.Validators {
 		if err := validator(value); err != nil {
-			return err
+			code := CodeInvalid
+			var validatorErr *FieldError
+			if errors.As(err, &validatorErr) && validatorErr.Code != "" {
+				code = validatorErr.Code
+			}
+			return nil, fieldError(name, field, code, err.Error())
 		}
 	}
 
-	return nil
+	return value, nil
+}
+
+// validateObject runs the object validators on data
+func (s *Serializer) validateObject(data map[string]interface{}) ValidationErrors {
+	var errs ValidationErrors
+	for _, validator := range s.Validators {
+		if err := validator(data); err != nil {
+			errs = append(errs, s.objectErrors(err)...)
+		}
+	}
+	return errs
 }
 
-// Validate validates the data against the serializer's constraints
+// Validate validates the data against the serializer's constraints, reporting
+// every invalid field in ValidationErrors
 func (s *Serializer) Validate(data map[string]interface{}) error {
-	// Check required fields
-	for name, field := range s.Fields {
-		if field.Required {
-			if _, ok := data[name]; !ok {
-				return fmt.Errorf("field %s is required", name)
+	errs, err := s.validate(context.Background(), data, false, "")
+	if err != nil {
+		return err
+	}
+	return errs.err()
+}
+
+// ValidatePartial validates the fields present in data, as supplied by a
+// partial update, without checking for required fields
+func (s *Serializer) ValidatePartial(data map[string]interface{}) error {
+	errs, err := s.validate(context.Background(), data, true, "")
+	if err != nil {
+		return err
+	}
+	return errs.err()
+}
+
+// validate validates data, checking required fields unless partial is set.
+// The column setByParent, the foreign key of a nested row, is not required.
+// The error is set when a related row could not be looked up.
+func (s *Serializer) validate(ctx context.Context, data map[string]interface{}, partial bool, setByParent string) (ValidationErrors, error) {
+	var errs ValidationErrors
+
+	// Check required fields; a default stands in for a missing value
+	if !partial {
+		for _, name := range sortedNames(s.Fields) {
+			field := s.Fields[name]
+			if _, ok := data[name]; !ok && field.Required && field.Default == nil && field.SourceField != setByParent {
+				errs = append(errs, fieldError(name, field, CodeRequired, fmt.Sprintf("field %s is required", name)))
 			}
 		}
 	}
 
 	// Validate fields
-	for name, value := range data {
-		if err := s.validateField(name, value); err != nil {
-			return err
+	for _, name := range sortedNames(data) {
+		if _, err := s.validateField(name, data[name]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		field := s.Fields[name]
+		var fieldErrs ValidationErrors
+		var err error
+		switch {
+		case field.Nested != nil && !field.ReadOnly:
+			fieldErrs, err = s.validateNested(ctx, name, field, data[name])
+		case field.Related != nil:
+			fieldErrs, err = validateRelated(ctx, name, field, data[name])
 		}
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, fieldErrs...)
 	}
 
-	return nil
+	if len(errs) == 0 {
+		errs = s.validateObject(data)
+	}
+	return errs, nil
 }
 
 // Common validators
@@ -289,10 +397,10 @@ func MaxLengthValidator(maxLength int) Validator {
 	return func(value interface{}) error {
 		str, ok := value.(string)
 		if !ok {
-			return fmt.Errorf("value is not a string")
+			return Invalid(CodeInvalidType, "value is not a string")
 		}
 		if len(str) > maxLength {
-			return fmt.Errorf("string length exceeds maximum of %d", maxLength)
+			return Invalid(CodeMaxLength, "string length exceeds maximum of %d", maxLength)
 		}
 		return nil
 	}
@@ -303,10 +411,10 @@ func MinLengthValidator(minLength int) Validator {
 	return func(value interface{}) error {
 		str, ok := value.(string)
 		if !ok {
-			return fmt.Errorf("value is not a string")
+			return Invalid(CodeInvalidType, "value is not a string")
 		}
 		if len(str) < minLength {
-			return fmt.Errorf("string length is less than minimum of %d", minLength)
+			return Invalid(CodeMinLength, "string length is less than minimum of %d", minLength)
 		}
 		return nil
 	}
@@ -317,7 +425,7 @@ func RegexValidator(pattern string) Validator {
 	return func(value interface{}) error {
 		str, ok := value.(string)
 		if !ok {
-			return fmt.Errorf("value is not a string")
+			return Invalid(CodeInvalidType, "value is not a string")
 		}
 		// TODO: Implement regex validation
 		_ = str
@@ -330,7 +438,7 @@ func EmailValidator() Validator {
 	return func(value interface{}) error {
 		str, ok := value.(string)
 		if !ok {
-			return fmt.Errorf("value is not a string")
+			return Invalid(CodeInvalidType, "value is not a string")
 		}
 		if !strings.Contains(str, "@") {
 			return fmt.Errorf("invalid email address")
@@ -369,11 +477,11 @@ func RangeValidator(min, max float64) Validator {
 		case float64:
 			num = v
 		default:
-			return fmt.Errorf("value is not a number")
+			return Invalid(CodeInvalidType, "value is not a number")
 		}
 
 		if num < min || num > max {
-			return fmt.Errorf("number must be between %f and %f", min, max)
+			return Invalid(CodeOutOfRange, "number must be between %f and %f", min, max)
 		}
 		return nil
 	}
@@ -0,0 +1,265 @@
+package serializer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baxromov/framego/pkg/models"
+)
+
+// newEventSerializer returns a serializer of an events model with a
+// length-limited title and a range-checked number of seats
+func newEventSerializer() *Serializer {
+	model := models.NewModel("events")
+	model.AddField("id", reflect.TypeOf(0), models.WithPrimaryKey(), models.WithAutoIncrement())
+	model.AddField("title", reflect.TypeOf(""), models.WithNotNull(), models.WithMaxLength(10))
+	model.AddField("venue", reflect.TypeOf(""), models.WithNotNull())
+	model.AddField("seats", reflect.TypeOf(0.0))
+	model.AddField("starts", reflect.TypeOf(""))
+	model.AddField("ends", reflect.TypeOf(""))
+
+	s := New(model)
+	seats := s.Fields["seats"]
+	seats.Validators = append(seats.Validators, RangeValidator(1, 500))
+	s.Fields["seats"] = seats
+	return s
+}
+
+// codes returns the errors of err as field:code
+func codes(t *testing.T, err error) []string {
+	t.Helper()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error = %v, want ValidationErrors", err)
+	}
+	var codes []string
+	for _, fieldErr := range errs {
+		codes = append(codes, fieldErr.Field+":"+fieldErr.Code)
+	}
+	return codes
+}
+
+// article is the model of the serializers under test
+type article struct {
+	ID        int        `db:"id,pk,autoincrement"`
+	Title     string     `db:"title,notnull,maxlen=20"`
+	Status    string     `db:"status,notnull,default='draft'"`
+	Body      *string    `db:"body"`
+	CreatedAt time.Time  `db:"created_at,notnull,auto_now_add"`
+	UpdatedAt time.Time  `db:"updated_at,notnull,auto_now"`
+	DeletedAt *time.Time `db:"deleted_at,softdelete"`
+}
+
+func (article) TableName() string { return "articles" }
+
+func TestNew(t *testing.T) {
+	s := New(models.MustFromStruct(&article{}))
+
+	tests := []struct {
+		field    string
+		required bool
+		readOnly bool
+	}{
+		{field: "id"},
+		{field: "title", required: true},
+		{field: "status"},
+		{field: "body"},
+		{field: "created_at", readOnly: true},
+		{field: "updated_at", readOnly: true},
+		{field: "deleted_at", readOnly: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			field, ok := s.Fields[tt.field]
+			if !ok {
+				t.Fatalf("field %s missing", tt.field)
+			}
+			if field.Required != tt.required {
+				t.Errorf("Required = %v, want %v", field.Required, tt.required)
+			}
+			if field.ReadOnly != tt.readOnly {
+				t.Errorf("ReadOnly = %v, want %v", field.ReadOnly, tt.readOnly)
+			}
+		})
+	}
+
+	// Timestamps are neither required nor written
+	row, err := s.Deserialize(map[string]interface{}{
+		"title":      "Hello",
+		"created_at": "2024-01-02T00:00:00Z",
+		"deleted_at": "2024-01-03T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	want := map[string]interface{}{"title": "Hello"}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("Deserialize() = %v, want %v", row, want)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	s := newEventSerializer()
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		partial bool
+		want    []string // Errors as field:code, nil when valid
+	}{
+		{name: "valid", data: map[string]interface{}{"title": "Meetup", "venue": "Hall", "seats": 20.0}},
+		{name: "missing fields", data: map[string]interface{}{}, want: []string{"title:required", "venue:required"}},
+		{
+			name: "every invalid field",
+			data: map[string]interface{}{"title": "Annual general meeting", "venue": 1, "seats": 900.0, "colour": "red"},
+			want: []string{"colour:unknown_field", "seats:out_of_range", "title:max_length", "venue:invalid_type"},
+		},
+		{
+			name: "present but invalid is not also required",
+			data: map[string]interface{}{"title": 7, "venue": "Hall"},
+			want: []string{"title:invalid_type"},
+		},
+		{name: "partial skips required fields", data: map[string]interface{}{"seats": 10.0}, partial: true},
+		{name: "partial validates given fields", data: map[string]interface{}{"seats": 0.0}, partial: true, want: []string{"seats:out_of_range"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validate := s.Validate
+			if tt.partial {
+				validate = s.ValidatePartial
+			}
+			err := validate(tt.data)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if got := codes(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Deserialize reports every invalid field as well, and unknown fields are ignored
+	_, err := s.Deserialize(map[string]interface{}{"title": "Annual general meeting", "colour": "red"})
+	if got, want := codes(t, err), []string{"venue:required", "title:max_length"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Deserialize() errors = %q, want %q", got, want)
+	}
+}
+
+func TestValidationErrorsMethods(t *testing.T) {
+	errs := ValidationErrors{
+		{Field: "title", Code: CodeRequired, Message: "title is required"},
+		{Field: "seats", Code: CodeOutOfRange, Message: "too many seats"},
+		{Field: "title", Code: CodeMaxLength, Message: "title is too long"},
+	}
+
+	if got, want := errs.Error(), "title is required; too many seats; title is too long"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got := errs.Field("title"); len(got) != 2 || got[0] != errs[0] || got[1] != errs[2] {
+		t.Errorf("Field() = %v, want the title errors", got)
+	}
+	var fieldErr *FieldError
+	if !errors.As(fmt.Errorf("create: %w", errs), &fieldErr) || fieldErr != errs[0] {
+		t.Errorf("errors.As() = %v, want the first field error", fieldErr)
+	}
+	if ValidationErrors(nil).err() != nil {
+		t.Error("err() of no errors is not nil")
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	s := newEventSerializer()
+	s.AddField("title", reflect.TypeOf(""), WithRequired(), WithValidator(MaxLengthValidator(10)),
+		WithErrorMessages(map[string]string{
+			CodeRequired:  "give the event a title",
+			CodeMaxLength: "keep the title short",
+		}))
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want string
+	}{
+		{name: "required", data: map[string]interface{}{"venue": "Hall"}, want: "give the event a title"},
+		{name: "validator code", data: map[string]interface{}{"title": "Annual general meeting", "venue": "Hall"}, want: "keep the title short"},
+		{name: "no override", data: map[string]interface{}{"title": 7, "venue": "Hall"}, want: "field title has invalid value: expected string, got int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs ValidationErrors
+			if err := s.Validate(tt.data); !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("Validate() error = %v, want one field error", err)
+			}
+			if errs[0].Message != tt.want {
+				t.Errorf("message = %q, want %q", errs[0].Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectValidators(t *testing.T) {
+	s := newEventSerializer()
+	s.AddValidator(func(data map[string]interface{}) error {
+		starts, _ := data["starts"].(string)
+		ends, _ := data["ends"].(string)
+		if starts != "" && ends != "" && ends < starts {
+			err := Invalid("before_start", "the event must end after it starts")
+			err.Field = "ends"
+			return err
+		}
+		return nil
+	})
+	s.AddValidator(func(data map[string]interface{}) error {
+		if data["venue"] == "Online" && data["seats"] != nil {
+			return errors.New("online events have no seats")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want []string
+	}{
+		{name: "valid", data: map[string]interface{}{"title": "Meetup", "venue": "Hall", "starts": "09:00", "ends": "17:00"}},
+		{
+			name: "field error",
+			data: map[string]interface{}{"title": "Meetup", "venue": "Hall", "starts": "09:00", "ends": "08:00"},
+			want: []string{"ends:before_start"},
+		},
+		{
+			name: "non-field error",
+			data: map[string]interface{}{"title": "Meetup", "venue": "Online", "seats": 10.0},
+			want: []string{"non_field_errors:invalid"},
+		},
+		{
+			name: "not run while a field is invalid",
+			data: map[string]interface{}{"venue": "Online", "seats": 10.0},
+			want: []string{"title:required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.data)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if got := codes(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}